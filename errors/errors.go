@@ -2,8 +2,11 @@ package errors
 
 import (
 	"bytes"
+	stderrors "errors"
 	"fmt"
+	"net"
 	"strings"
+	"syscall"
 )
 
 type ErrorType string
@@ -19,15 +22,76 @@ const (
 	ERROR_TYPE_SCHEDULER ErrorType = "scheduler error"
 )
 
+// ErrorKind 代表错误的种类。
+// 各种类的值本身也是错误值，可以配合 errors.Is 使用，
+// 也可以被包装在错误链中以标记错误的种类。
+type ErrorKind string
+
+func (k ErrorKind) Error() string {
+	return string(k)
+}
+
+const (
+	// 超时
+	ErrTimeout ErrorKind = "timeout"
+	// 连接被拒绝
+	ErrRefused ErrorKind = "connection refused"
+	// 非预期的响应状态码
+	ErrBadStatus ErrorKind = "bad status"
+	// 响应解析失败
+	ErrParse ErrorKind = "parse error"
+	// 条目处理失败
+	ErrPipeline ErrorKind = "pipeline error"
+	// 被有意过滤，条目处理函数可以返回它来丢弃条目
+	ErrFiltered ErrorKind = "filtered"
+	// 响应体超过大小限制
+	ErrTooLarge ErrorKind = "body too large"
+)
+
+// Stage 代表出错时所处的处理阶段。
+type Stage string
+
+const (
+	// 调度阶段
+	STAGE_SCHEDULE Stage = "schedule"
+	// 下载阶段
+	STAGE_DOWNLOAD Stage = "download"
+	// 分析阶段
+	STAGE_ANALYZE Stage = "analyze"
+	// 条目处理阶段
+	STAGE_PIPELINE Stage = "pipeline"
+)
+
+// ErrorContext 代表出错时的上下文。
+type ErrorContext struct {
+	// 出错的组件的 ID，与组件无关时为空
+	MID string
+	// 出错的请求或响应的 URL
+	URL string
+	// 出错的请求或响应的深度
+	Depth uint32
+	// 出错时所处的处理阶段
+	Stage Stage
+}
+
 type CrawlerError interface {
 	Type() ErrorType
 	Error() string
+	// 获取错误的种类，无法归类时为空
+	Kind() ErrorKind
+	// 获取出错时的上下文
+	Context() ErrorContext
+	// 获取原错误值，没有时为 nil
+	Unwrap() error
 }
 
 type myCrawlerError struct {
 	errType    ErrorType
 	errMsg     string
 	fullErrMsg string
+	kind       ErrorKind
+	ctx        ErrorContext
+	cause      error
 }
 
 func (e *myCrawlerError) Type() ErrorType {
@@ -41,9 +105,33 @@ func (e *myCrawlerError) Error() string {
 	return e.fullErrMsg
 }
 
+func (e *myCrawlerError) Kind() ErrorKind {
+	return e.kind
+}
+
+func (e *myCrawlerError) Context() ErrorContext {
+	return e.ctx
+}
+
+func (e *myCrawlerError) Unwrap() error {
+	return e.cause
+}
+
+// Is 用于支持 errors.Is，使爬虫错误值与其种类相匹配。
+func (e *myCrawlerError) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && e.kind != "" && kind == e.kind
+}
+
 // NewCrawlerErrorBy 用于根据给定的错误值创建一个新的爬虫错误值。
+// 给定的错误值会被包装为原错误值。
 func NewCrawlerErrorBy(errType ErrorType, err error) CrawlerError {
-	return NewCrawlerError(errType, err.Error())
+	return &myCrawlerError{
+		errType: errType,
+		errMsg:  strings.TrimSpace(err.Error()),
+		kind:    Classify(err),
+		cause:   err,
+	}
 }
 
 func (e *myCrawlerError) genFullErrMsg() {
@@ -61,6 +149,94 @@ func NewCrawlerError(errType ErrorType, errMsg string) CrawlerError {
 	return &myCrawlerError{errType: errType, errMsg: strings.TrimSpace(errMsg)}
 }
 
+// Annotate 用于为错误值补充出错时的上下文并把它转换为爬虫错误值。
+// 给定的错误值是爬虫错误值时只补充其中为空的上下文，
+// 否则会以 errType 为类型包装它。
+// 错误值无法归类时，分析器和条目处理管道的错误会分别被归为
+// ErrParse 和 ErrPipeline。
+func Annotate(err error, errType ErrorType, ctx ErrorContext) CrawlerError {
+	var result myCrawlerError
+	if ce, ok := err.(*myCrawlerError); ok {
+		result = myCrawlerError{
+			errType: ce.errType,
+			errMsg:  ce.errMsg,
+			kind:    ce.kind,
+			ctx:     ce.ctx,
+			cause:   ce.cause,
+		}
+	} else {
+		result = myCrawlerError{
+			errType: errType,
+			errMsg:  strings.TrimSpace(err.Error()),
+			cause:   err,
+		}
+	}
+	if result.ctx.MID == "" {
+		result.ctx.MID = ctx.MID
+	}
+	if result.ctx.URL == "" {
+		result.ctx.URL = ctx.URL
+		result.ctx.Depth = ctx.Depth
+	}
+	if result.ctx.Stage == "" {
+		result.ctx.Stage = ctx.Stage
+	}
+	if result.kind == "" && result.cause != nil {
+		result.kind = Classify(result.cause)
+	}
+	if result.kind == "" {
+		switch result.errType {
+		case ERROR_TYPE_ANALYZER:
+			result.kind = ErrParse
+		case ERROR_TYPE_PIPELINE:
+			result.kind = ErrPipeline
+		}
+	}
+	return &result
+}
+
+// StatusError 代表响应状态码不符合预期的错误类型。
+// 它与 ErrBadStatus 相匹配。
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrBadStatus
+}
+
+// Classify 用于判断错误值的种类，无法归类时返回空字符串。
+// 错误链中的 ErrorKind 优先，其次识别超时和连接被拒绝。
+func Classify(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	var kind ErrorKind
+	if stderrors.As(err, &kind) {
+		return kind
+	}
+	var se *StatusError
+	if stderrors.As(err, &se) {
+		return ErrBadStatus
+	}
+	var ce CrawlerError
+	if stderrors.As(err, &ce) && ce.Kind() != "" {
+		return ce.Kind()
+	}
+	var netErr net.Error
+	if stderrors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+	if stderrors.Is(err, syscall.ECONNREFUSED) {
+		return ErrRefused
+	}
+	return ""
+}
+
 // IllegalParameterError 代表非法的参数的错误类型。
 type IllegalParameterError struct {
 	msg string
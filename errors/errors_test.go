@@ -0,0 +1,90 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestCrawlerError(t *testing.T) {
+	cause := NewIllegalParameterError("nil request")
+	err := NewCrawlerErrorBy(ERROR_TYPE_DOWNLOADER, cause)
+	expectedMsg := "crawler error: downloader error: illegal parameter: nil request"
+	if err.Error() != expectedMsg {
+		t.Fatalf("Inconsistent error message: expected: %q, actual: %q",
+			expectedMsg, err.Error())
+	}
+	var ipe IllegalParameterError
+	if !stderrors.As(err, &ipe) || ipe != cause {
+		t.Fatal("Couldn't get the cause of crawler error!")
+	}
+	err = NewCrawlerError(ERROR_TYPE_SCHEDULER, " message ")
+	if err.Unwrap() != nil || err.Kind() != "" {
+		t.Fatalf("Inconsistent crawler error: cause: %v, kind: %q", err.Unwrap(), err.Kind())
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind ErrorKind
+	}{
+		{nil, ""},
+		{fmt.Errorf("failed"), ""},
+		{fmt.Errorf("download: %w", context.DeadlineExceeded), ErrTimeout},
+		{&os.PathError{Op: "read", Err: os.ErrDeadlineExceeded}, ErrTimeout},
+		{&os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}, ErrRefused},
+		{fmt.Errorf("%w: unexpected status code 503", ErrBadStatus), ErrBadStatus},
+		{fmt.Errorf("gave up: %w", &StatusError{StatusCode: 503}), ErrBadStatus},
+		{NewCrawlerErrorBy(ERROR_TYPE_PIPELINE, ErrFiltered), ErrFiltered},
+	}
+	for i, c := range cases {
+		if kind := Classify(c.err); kind != c.kind {
+			t.Fatalf("Inconsistent kind: expected: %q, actual: %q (index: %d)", c.kind, kind, i)
+		}
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	ctx := ErrorContext{
+		MID:   "D1|127.0.0.1:8080",
+		URL:   "http://example.com/",
+		Depth: 1,
+		Stage: STAGE_DOWNLOAD,
+	}
+	cause := &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}
+	err := Annotate(fmt.Errorf("dial: %w", cause), ERROR_TYPE_DOWNLOADER, ctx)
+	if err.Type() != ERROR_TYPE_DOWNLOADER || err.Context() != ctx {
+		t.Fatalf("Inconsistent crawler error: type: %s, context: %#v", err.Type(), err.Context())
+	}
+	if !stderrors.Is(err, ErrRefused) || stderrors.Is(err, ErrTimeout) {
+		t.Fatalf("Inconsistent kind: %q", err.Kind())
+	}
+	if !stderrors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatal("Couldn't match the cause of crawler error!")
+	}
+	var ce CrawlerError
+	if !stderrors.As(fmt.Errorf("wrapped: %w", err), &ce) || ce.Context().URL != ctx.URL {
+		t.Fatal("Couldn't get the crawler error from the error chain!")
+	}
+
+	// 已有的上下文不会被覆盖，分析器的错误默认被归为解析错误。
+	moduleErr := NewCrawlerError(ERROR_TYPE_ANALYZER, "bad html")
+	err = Annotate(moduleErr, ERROR_TYPE_SCHEDULER,
+		ErrorContext{MID: "A1", Stage: STAGE_ANALYZE})
+	err = Annotate(err, ERROR_TYPE_SCHEDULER, ctx)
+	expectedCtx := ErrorContext{MID: "A1", URL: ctx.URL, Depth: 1, Stage: STAGE_ANALYZE}
+	if err.Type() != ERROR_TYPE_ANALYZER || err.Context() != expectedCtx {
+		t.Fatalf("Inconsistent crawler error: type: %s, context: %#v", err.Type(), err.Context())
+	}
+	if err.Error() != moduleErr.Error() || !stderrors.Is(err, ErrParse) {
+		t.Fatalf("Inconsistent crawler error: %s (kind: %q)", err, err.Kind())
+	}
+	err = Annotate(fmt.Errorf("processor failed"), ERROR_TYPE_PIPELINE, ErrorContext{})
+	if err.Kind() != ErrPipeline {
+		t.Fatalf("Inconsistent kind: expected: %q, actual: %q", ErrPipeline, err.Kind())
+	}
+}
@@ -9,15 +9,18 @@ import (
 
 	lib "github.com/dokidokikoi/webcrawler/examples/finder/internal"
 	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module/local/downloader"
 	sched "github.com/dokidokikoi/webcrawler/scheduler"
 )
 
 // 命令参数
 var (
-	firstURL string
-	domains  string
-	depth    uint
-	dirPath  string
+	firstURL  string
+	domains   string
+	depth     uint
+	dirPath   string
+	cacheDir  string
+	cacheMode string
 )
 
 func init() {
@@ -38,6 +41,16 @@ func init() {
 		"dir",
 		"./pictures",
 		"The path which you want to save the image files.")
+	flag.StringVar(
+		&cacheDir,
+		"cache-dir",
+		"./cache",
+		"The path which you want to cache the HTTP responses.")
+	flag.StringVar(
+		&cacheMode,
+		"cache-mode",
+		"off",
+		"The mode of the response cache: off, read-write, read-only or refresh.")
 }
 
 func Usage() {
@@ -76,7 +89,14 @@ func main() {
 		ErrorBufferCap:       50,
 		ErrorMaxBufferNumber: 1,
 	}
-	dowloaders, err := lib.GetDownloaders(1)
+	mode, err := downloader.ParseCacheMode(cacheMode)
+	if err != nil {
+		log.L().Sugar().Fatalf("An error occurs when parsing cache mode: %s", err)
+	}
+	dowloaders, err := lib.GetDownloaders(1, downloader.CacheConfig{
+		Dir:  cacheDir,
+		Mode: mode,
+	})
 	if err != nil {
 		log.L().Sugar().Fatalf("An error occurs when creating downloaders: %s", err)
 	}
@@ -11,7 +11,8 @@ import (
 var snGen = module.NewSNGenertor(1, 0)
 
 // 获取下载器列表
-func GetDownloaders(number uint8) ([]module.Downloader, error) {
+// 缓存的模式不为 off 时，下载器会带有响应缓存
+func GetDownloaders(number uint8, cache downloader.CacheConfig) ([]module.Downloader, error) {
 	downloaders := []module.Downloader{}
 	if number == 0 {
 		return downloaders, nil
@@ -29,6 +30,10 @@ func GetDownloaders(number uint8) ([]module.Downloader, error) {
 		if err != nil {
 			return downloaders, err
 		}
+		d, err = downloader.NewCaching(d, cache)
+		if err != nil {
+			return downloaders, err
+		}
 		downloaders = append(downloaders, d)
 	}
 
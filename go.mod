@@ -2,16 +2,17 @@ module github.com/dokidokikoi/webcrawler
 
 go 1.19
 
-require go.uber.org/zap v1.24.0
+require (
+	github.com/PuerkitoBio/goquery v1.8.0
+	go.uber.org/zap v1.24.0
+)
 
 require (
-	github.com/PuerkitoBio/goquery v1.8.0 // indirect
 	github.com/andybalholm/cascadia v1.3.1 // indirect
 	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8 // indirect
 )
 
 require (
-	github.com/dokidokikoi/go-cmap v0.0.0-20221210062014-861b056eb775
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 )
@@ -1,6 +1,11 @@
 package module
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
 
 type Data interface {
 	// 数据是否有效
@@ -12,6 +17,18 @@ type Request struct {
 	httpReq *http.Request
 	// 请求的深度
 	depth uint32
+	// 请求的优先级，可由分析器设置
+	priority float64
+	// 从所属起点出发的最大爬取深度，为 nil 时使用调度器的最大深度
+	maxDepth *uint32
+	// 已进行的下载次数
+	attempt uint32
+	// 元数据，可由分析器传递给下一跳
+	meta Metadata
+	// 父请求（即发现该请求的页面）的 URL，起点请求为空
+	parentURL string
+	// 所属起点的 URL，起点请求为空
+	rootURL string
 }
 
 func (r *Request) HTTPReq() *http.Request {
@@ -22,12 +39,107 @@ func (r *Request) Depth() uint32 {
 	return r.depth
 }
 
+// Priority 用于获取请求的优先级，值越大越先被下载。
+// 仅在待爬取队列使用最佳优先策略时生效。
+func (r *Request) Priority() float64 {
+	return r.priority
+}
+
+// SetPriority 用于设置请求的优先级。
+func (r *Request) SetPriority(priority float64) {
+	r.priority = priority
+}
+
+// MaxDepth 用于获取从所属起点出发的最大爬取深度。
+// 结果值 ok 为 false 时代表未设置，即使用调度器的最大深度。
+func (r *Request) MaxDepth() (depth uint32, ok bool) {
+	if r.maxDepth == nil {
+		return 0, false
+	}
+	return *r.maxDepth, true
+}
+
+// SetMaxDepth 用于设置从所属起点出发的最大爬取深度。
+// 调度器会把它传递给由该请求的响应分析出的新请求。
+func (r *Request) SetMaxDepth(depth uint32) {
+	r.maxDepth = &depth
+}
+
+// Attempt 用于获取已进行的下载次数。
+func (r *Request) Attempt() uint32 {
+	return atomic.LoadUint32(&r.attempt)
+}
+
+// SetAttempt 用于设置已进行的下载次数。
+// 调度器会在每次下载前把它加 1，以便在失败时决定是否重试。
+func (r *Request) SetAttempt(attempt uint32) {
+	atomic.StoreUint32(&r.attempt, attempt)
+}
+
+// Meta 用于获取请求的元数据。
+// 由 NewRequest 创建的请求总是带有元数据，因此可以直接在结果值上调用 Set。
+func (r *Request) Meta() Metadata {
+	return r.meta
+}
+
+// SetMeta 用于设置请求的元数据，参数为 nil 时会设置一个空的元数据。
+func (r *Request) SetMeta(meta Metadata) {
+	if meta == nil {
+		meta = Metadata{}
+	}
+	r.meta = meta
+}
+
+// ParentURL 用于获取父请求的 URL。
+// 起点请求的结果值为空。
+func (r *Request) ParentURL() string {
+	return r.parentURL
+}
+
+// RootURL 用于获取所属起点的 URL。
+// 起点请求的结果值为其自身的 URL。
+func (r *Request) RootURL() string {
+	if r.rootURL != "" {
+		return r.rootURL
+	}
+	return requestURL(r.httpReq)
+}
+
+// SetLineage 用于设置父请求的 URL 和所属起点的 URL。
+func (r *Request) SetLineage(parentURL, rootURL string) {
+	r.parentURL = parentURL
+	r.rootURL = rootURL
+}
+
+// WithDepth 用于生成一个深度不同、其他内容都相同的请求。
+// 元数据会被共享而不会被复制。
+func (r *Request) WithDepth(depth uint32) *Request {
+	return &Request{
+		httpReq:   r.httpReq,
+		depth:     depth,
+		priority:  r.priority,
+		maxDepth:  r.maxDepth,
+		attempt:   r.Attempt(),
+		meta:      r.meta,
+		parentURL: r.parentURL,
+		rootURL:   r.rootURL,
+	}
+}
+
 func (r *Request) Valid() bool {
 	return r.httpReq != nil && r.httpReq.URL != nil
 }
 
 func NewRequest(r *http.Request, depth uint32) *Request {
-	return &Request{r, depth}
+	return &Request{httpReq: r, depth: depth, meta: Metadata{}}
+}
+
+// requestURL 用于获取 HTTP 请求的 URL 字符串。
+func requestURL(httpReq *http.Request) string {
+	if httpReq == nil || httpReq.URL == nil {
+		return ""
+	}
+	return httpReq.URL.String()
 }
 
 type Response struct {
@@ -35,6 +147,16 @@ type Response struct {
 	httpResp *http.Response
 	// 请求的深度
 	depth uint32
+	// 对应请求的元数据
+	meta Metadata
+	// 对应请求的父请求的 URL
+	parentURL string
+	// 对应请求所属起点的 URL
+	rootURL string
+	// 下载过程信息
+	trace *DownloadTrace
+	// 响应体是否因超过大小限制而被截断，为 1 时代表已截断
+	truncated uint32
 }
 
 func (r *Response) HTTPResp() *http.Response {
@@ -45,16 +167,204 @@ func (r *Response) Depth() uint32 {
 	return r.depth
 }
 
+// Meta 用于获取对应请求的元数据。
+func (r *Response) Meta() Metadata {
+	return r.meta
+}
+
+// ParentURL 用于获取对应请求的父请求的 URL。
+func (r *Response) ParentURL() string {
+	return r.parentURL
+}
+
+// RootURL 用于获取对应请求所属起点的 URL。
+// 对应请求为起点请求时，结果值为该请求的 URL。
+func (r *Response) RootURL() string {
+	if r.rootURL != "" {
+		return r.rootURL
+	}
+	if r.httpResp == nil {
+		return ""
+	}
+	return requestURL(r.httpResp.Request)
+}
+
+// FinalURL 用于获取经过重定向之后最终的 URL。
+func (r *Response) FinalURL() string {
+	if r.httpResp == nil {
+		return ""
+	}
+	return requestURL(r.httpResp.Request)
+}
+
+// Trace 用于获取下载过程信息，包括用时、读取的字节数和重定向链。
+// 响应不是由下载器生成时结果值为 nil。
+func (r *Response) Trace() *DownloadTrace {
+	return r.trace
+}
+
+// SetTrace 用于设置下载过程信息。
+func (r *Response) SetTrace(trace *DownloadTrace) {
+	r.trace = trace
+}
+
+// Truncated 用于判断响应体是否因超过大小限制而被截断。
+// 在响应体被读取完毕之前，结果值可能为 false。
+func (r *Response) Truncated() bool {
+	return atomic.LoadUint32(&r.truncated) == 1
+}
+
+// SetTruncated 用于设置响应体是否被截断，下载过程信息中的标记也会被一并设置。
+func (r *Response) SetTruncated(truncated bool) {
+	var v uint32
+	if truncated {
+		v = 1
+	}
+	atomic.StoreUint32(&r.truncated, v)
+	if r.trace != nil {
+		atomic.StoreUint32(&r.trace.truncated, v)
+	}
+}
+
 func (r *Response) Valid() bool {
 	return r.httpResp != nil && r.httpResp.Body != nil
 }
 
 func NewResponse(resp *http.Response, depth uint32) *Response {
-	return &Response{resp, depth}
+	return &Response{httpResp: resp, depth: depth, meta: Metadata{}}
 }
 
+// NewResponseByRequest 用于根据请求创建响应。
+// 请求的深度、元数据和来源信息会被传递给响应。
+func NewResponseByRequest(resp *http.Response, req *Request) *Response {
+	meta := req.meta
+	if meta == nil {
+		meta = Metadata{}
+	}
+	return &Response{
+		httpResp:  resp,
+		depth:     req.depth,
+		meta:      meta,
+		parentURL: req.parentURL,
+		rootURL:   req.RootURL(),
+	}
+}
+
+// 调度器从请求的元数据中读取下载超时时间时使用的键。
+// 值可以是 time.Duration、以纳秒表示的数字或形如 "5s" 的字符串。
+const META_KEY_TIMEOUT = "timeout"
+
+// 分析器在条目中记录来源信息时使用的键。
+// 条目中已有同名的键时不会被覆盖。
+const (
+	// 条目所在页面的 URL
+	ITEM_KEY_PAGE_URL = "_page_url"
+	// 条目所属起点的 URL
+	ITEM_KEY_ROOT_URL = "_root_url"
+)
+
 type Item map[string]interface{}
 
 func (item Item) Valid() bool {
 	return item != nil
 }
+
+// Metadata 代表请求的元数据。
+type Metadata map[string]interface{}
+
+// Get 用于获取元数据中的值。
+func (m Metadata) Get(key string) (interface{}, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+// Set 用于设置元数据中的值。
+func (m Metadata) Set(key string, value interface{}) {
+	m[key] = value
+}
+
+// String 用于获取元数据中的字符串值。
+// 值不存在或不是字符串时结果值 ok 为 false。
+func (m Metadata) String(key string) (value string, ok bool) {
+	value, ok = m[key].(string)
+	return
+}
+
+// Int 用于获取元数据中的整数值。
+// 从检查点等 JSON 数据中恢复的数字也可以被获取。
+func (m Metadata) Int(key string) (value int64, ok bool) {
+	switch v := m[key].(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint32:
+		return int64(v), true
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// Duration 用于获取元数据中的时长。
+// 值可以是 time.Duration、以纳秒表示的数字或可被 time.ParseDuration 解析的字符串。
+func (m Metadata) Duration(key string) (value time.Duration, ok bool) {
+	switch v := m[key].(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		d, err := time.ParseDuration(v)
+		return d, err == nil
+	}
+	n, ok := m.Int(key)
+	return time.Duration(n), ok
+}
+
+// Merge 用于把给定元数据中的值复制到当前元数据中，已有的键不会被覆盖。
+func (m Metadata) Merge(other Metadata) {
+	for k, v := range other {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+}
+
+// Clone 用于复制元数据，值本身不会被深度复制。
+func (m Metadata) Clone() Metadata {
+	if m == nil {
+		return nil
+	}
+	clone := make(Metadata, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
+
+// metaKey 代表在 context 中保存请求元数据的键。
+type metaKey struct{}
+
+// WithMeta 用于把请求的元数据放入 context 中。
+func WithMeta(ctx context.Context, meta Metadata) context.Context {
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+// MetaFromContext 用于从 context 中取出请求的元数据。
+func MetaFromContext(ctx context.Context) Metadata {
+	meta, _ := ctx.Value(metaKey{}).(Metadata)
+	return meta
+}
+
+// MetaOf 用于获取 HTTP 响应对应的请求的元数据。
+// 响应解析函数可以借此读取由上一跳传递下来的元数据。
+// 响应不是由本项目的分析器交给解析函数时结果值为 nil。
+func MetaOf(httpResp *http.Response) Metadata {
+	if httpResp == nil || httpResp.Request == nil {
+		return nil
+	}
+	return MetaFromContext(httpResp.Request.Context())
+}
@@ -3,7 +3,9 @@ package module
 import (
 	"net/http"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // testingReader 代表测试专用的读取器，实现了io.ReadCloser接口类型。
@@ -45,6 +47,32 @@ func TestRequest(t *testing.T) {
 		t.Fatalf("Inconsistent depth for request: expected: %d, actual: %d",
 			expectedDepth, req.Depth())
 	}
+	if req.Priority() != 0 {
+		t.Fatalf("Inconsistent priority for request: expected: %v, actual: %v",
+			0, req.Priority())
+	}
+	req.SetPriority(1.5)
+	if req.Priority() != 1.5 {
+		t.Fatalf("Inconsistent priority for request: expected: %v, actual: %v",
+			1.5, req.Priority())
+	}
+	if _, ok := req.MaxDepth(); ok {
+		t.Fatal("The max depth for request was set unexpectedly!")
+	}
+	req.SetMaxDepth(3)
+	if maxDepth, ok := req.MaxDepth(); !ok || maxDepth != 3 {
+		t.Fatalf("Inconsistent max depth for request: expected: %d, actual: %d (set: %v)",
+			3, maxDepth, ok)
+	}
+	if req.Attempt() != 0 {
+		t.Fatalf("Inconsistent attempt for request: expected: %d, actual: %d",
+			0, req.Attempt())
+	}
+	req.SetAttempt(2)
+	if req.Attempt() != 2 {
+		t.Fatalf("Inconsistent attempt for request: expected: %d, actual: %d",
+			2, req.Attempt())
+	}
 	expectedHTTPReq.URL = nil
 	req = NewRequest(expectedHTTPReq, expectedDepth)
 	expectedValidity = false
@@ -62,6 +90,100 @@ func TestRequest(t *testing.T) {
 	}
 }
 
+func TestRequestLineage(t *testing.T) {
+	seedHTTPReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	seed := NewRequest(seedHTTPReq, 0)
+	if seed.ParentURL() != "" || seed.RootURL() != "https://github.com/gopcp" {
+		t.Fatalf("Inconsistent lineage for seed: parent: %q, root: %q",
+			seed.ParentURL(), seed.RootURL())
+	}
+	seed.Meta().Set("page", 1)
+	seed.SetPriority(2)
+	childHTTPReq, _ := http.NewRequest("GET", "https://github.com/gopcp/example", nil)
+	child := NewRequest(childHTTPReq, 0)
+	child.SetMeta(seed.Meta().Clone())
+	child.Meta().Set("page", 2)
+	child.SetLineage(seedHTTPReq.URL.String(), seed.RootURL())
+	if page, ok := seed.Meta().Int("page"); !ok || page != 1 {
+		t.Fatalf("Inconsistent metadata for seed: expected: %d, actual: %d", 1, page)
+	}
+	child = child.WithDepth(1)
+	if child.Depth() != 1 || child.ParentURL() != "https://github.com/gopcp" ||
+		child.RootURL() != "https://github.com/gopcp" {
+		t.Fatalf("Inconsistent child request: depth: %d, parent: %q, root: %q",
+			child.Depth(), child.ParentURL(), child.RootURL())
+	}
+	if page, ok := child.Meta().Int("page"); !ok || page != 2 {
+		t.Fatalf("Inconsistent metadata for child: expected: %d, actual: %d", 2, page)
+	}
+	resp := NewResponseByRequest(&http.Response{Request: childHTTPReq}, child)
+	if resp.Depth() != 1 || resp.ParentURL() != child.ParentURL() ||
+		resp.RootURL() != child.RootURL() {
+		t.Fatalf("Inconsistent response: depth: %d, parent: %q, root: %q",
+			resp.Depth(), resp.ParentURL(), resp.RootURL())
+	}
+	if _, ok := resp.Meta().String("page"); ok {
+		t.Fatal("A non-string metadata value was read as a string!")
+	}
+	meta := Metadata{
+		"count":              float64(3),
+		"ratio":              0.5,
+		META_KEY_TIMEOUT:     "150ms",
+		"timeout.duration":   time.Second,
+		"timeout.nanosecond": float64(2e9),
+	}
+	for key, expected := range map[string]time.Duration{
+		META_KEY_TIMEOUT:     150 * time.Millisecond,
+		"timeout.duration":   time.Second,
+		"timeout.nanosecond": 2 * time.Second,
+	} {
+		if d, ok := meta.Duration(key); !ok || d != expected {
+			t.Fatalf("Inconsistent duration metadata: expected: %s, actual: %s (key: %s)",
+				expected, d, key)
+		}
+	}
+	if _, ok := meta.Duration("ratio"); ok {
+		t.Fatal("A fractional metadata value was read as a duration!")
+	}
+	if count, ok := meta.Int("count"); !ok || count != 3 {
+		t.Fatalf("Inconsistent integer metadata: expected: %d, actual: %d", 3, count)
+	}
+	if _, ok := meta.Int("ratio"); ok {
+		t.Fatal("A fractional metadata value was read as an integer!")
+	}
+}
+
+func TestMetaConcurrent(t *testing.T) {
+	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	req := NewRequest(httpReq, 0)
+	resp := NewResponse(&http.Response{Request: httpReq}, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := req.Meta().Duration(META_KEY_TIMEOUT); ok {
+				t.Error("Unexpected timeout metadata for request!")
+			}
+			if _, ok := resp.Meta().String("page"); ok {
+				t.Error("Unexpected metadata for response!")
+			}
+		}()
+	}
+	wg.Wait()
+	if req.Meta() == nil || resp.Meta() == nil {
+		t.Fatal("The metadata should be allocated when created!")
+	}
+	req.SetMeta(nil)
+	req.Meta().Set("page", 1)
+	if page, ok := req.Meta().Int("page"); !ok || page != 1 {
+		t.Fatalf("Inconsistent metadata: expected: %d, actual: %d", 1, page)
+	}
+	if resp := NewResponseByRequest(&http.Response{}, &Request{}); resp.Meta() == nil {
+		t.Fatal("The metadata of response should be allocated when created!")
+	}
+}
+
 func TestResponse(t *testing.T) {
 	method := "GET"
 	expectedURLStr := "https://github.com/gopcp"
@@ -4,3 +4,20 @@ import "errors"
 
 // ErrNotFoundModuleInstance 代表未找到组件实例的错误类型。
 var ErrNotFoundModuleInstance = errors.New("not found module instance")
+
+// ProcessorError 代表条目处理函数出错的错误类型。
+// 它的错误信息与原错误值相同。
+type ProcessorError struct {
+	// 出错的条目处理函数在条目处理管道中的序号
+	Index int
+	// 原错误值
+	Err error
+}
+
+func (e *ProcessorError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProcessorError) Unwrap() error {
+	return e.Err
+}
@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/dokidokikoi/webcrawler/log"
 	"github.com/dokidokikoi/webcrawler/module"
@@ -13,6 +14,8 @@ type myAnalyzer struct {
 	stub.ModuleInternal
 	// 响应解析器列表
 	respParsers []module.ParseResponse
+	// 把响应体暂存到临时文件的阈值，为 0 时总是保存在内存中
+	spillThreshold int64
 }
 
 func (analyzer *myAnalyzer) RespParsers() []module.ParseResponse {
@@ -54,11 +57,15 @@ func (a *myAnalyzer) Analyze(resp *module.Response) (dataList []module.Data, err
 	if httpResp.Body != nil {
 		defer httpResp.Body.Close()
 	}
-	multipleReader, err := reader.NewMultipleReader(httpReq.Body)
+	multipleReader, err := reader.NewSpillingMultipleReader(
+		httpResp.Body, a.SpillThreshold(), "")
 	if err != nil {
-		errorList = append(errorList, genError(err.Error()))
+		errorList = append(errorList, genErrorByError(err))
 		return
 	}
+	defer multipleReader.Close()
+	// 把请求的元数据放入 HTTP 请求的 context 中，以便解析函数通过 module.MetaOf 读取
+	httpResp.Request = httpReq.WithContext(module.WithMeta(httpReq.Context(), resp.Meta()))
 	dataList = []module.Data{}
 	for _, respParser := range a.respParsers {
 		httpResp.Body = multipleReader.Reader()
@@ -68,7 +75,7 @@ func (a *myAnalyzer) Analyze(resp *module.Response) (dataList []module.Data, err
 				if pData == nil {
 					continue
 				}
-				dataList = appendDataList(dataList, pData, respDepth)
+				dataList = appendDataList(dataList, pData, resp)
 			}
 		}
 		if pErrorList != nil {
@@ -87,19 +94,50 @@ func (a *myAnalyzer) Analyze(resp *module.Response) (dataList []module.Data, err
 }
 
 // appendDataList 用于添加请求值或条目值到列表。
-func appendDataList(dataList []module.Data, data module.Data, respDepth uint32) []module.Data {
+// 新请求和条目会被标记上响应所在的页面和所属的起点，
+// 新请求还会继承响应对应的请求的元数据，新请求中已有的键不会被覆盖。
+func appendDataList(dataList []module.Data, data module.Data, resp *module.Response) []module.Data {
 	if data == nil {
 		return dataList
 	}
-	req, ok := data.(*module.Request)
-	if !ok {
-		return append(dataList, data)
+	pageURL := resp.HTTPResp().Request.URL.String()
+	rootURL := resp.RootURL()
+	switch d := data.(type) {
+	case *module.Request:
+		newDepth := resp.Depth() + 1
+		if d.Depth() != newDepth {
+			d = d.WithDepth(newDepth)
+		}
+		d.SetLineage(pageURL, rootURL)
+		if parentMeta := resp.Meta(); len(parentMeta) > 0 {
+			if d.Meta() == nil {
+				d.SetMeta(nil)
+			}
+			d.Meta().Merge(parentMeta)
+		}
+		return append(dataList, d)
+	case module.Item:
+		if _, ok := d[module.ITEM_KEY_PAGE_URL]; !ok {
+			d[module.ITEM_KEY_PAGE_URL] = pageURL
+		}
+		if _, ok := d[module.ITEM_KEY_ROOT_URL]; !ok {
+			d[module.ITEM_KEY_ROOT_URL] = rootURL
+		}
 	}
-	newDepth := respDepth + 1
-	if req.Depth() != newDepth {
-		req = module.NewRequest(req.HTTPReq(), newDepth)
+	return append(dataList, data)
+}
+
+// SpillThreshold 用于获取把响应体暂存到临时文件的阈值。
+func (a *myAnalyzer) SpillThreshold() int64 {
+	return atomic.LoadInt64(&a.spillThreshold)
+}
+
+// SetSpillThreshold 用于设置把响应体暂存到临时文件的阈值，负数会被视为 0。
+func (a *myAnalyzer) SetSpillThreshold(threshold int64) {
+	if threshold < 0 {
+		threshold = 0
 	}
-	return append(dataList, req)
+	atomic.StoreInt64(&a.spillThreshold, threshold)
 }
 
 func New(mid module.MID,
@@ -122,5 +160,8 @@ func New(mid module.MID,
 		}
 		innerParsers = append(innerParsers, parser)
 	}
-	return &myAnalyzer{moduleBase, innerParsers}, nil
+	return &myAnalyzer{
+		ModuleInternal: moduleBase,
+		respParsers:    innerParsers,
+	}, nil
 }
@@ -94,7 +94,11 @@ func TestAnalyze(t *testing.T) {
 		if d == nil {
 			t.Fatalf("nil datum! (index: %d)", i)
 		}
-		if _, ok := d.(*module.Request); ok {
+		if req, ok := d.(*module.Request); ok {
+			if req.ParentURL() != expectedURL || req.RootURL() != expectedURL {
+				t.Errorf("Inconsistent lineage: parent: %s, root: %s (index: %d)",
+					req.ParentURL(), req.RootURL(), i)
+			}
 			continue
 		}
 		item, ok := d.(module.Item)
@@ -102,6 +106,10 @@ func TestAnalyze(t *testing.T) {
 			t.Errorf("Inconsistent datum type: expected: %T, actual: %T (index: %d)",
 				module.Item{}, d, i)
 		}
+		if item[module.ITEM_KEY_PAGE_URL] != expectedURL {
+			t.Errorf("Inconsistent page URL: expected: %s, actual: %s (index: %d)",
+				expectedURL, item[module.ITEM_KEY_PAGE_URL], i)
+		}
 		if item["url"] != expectedURL {
 			t.Errorf("Inconsistent URL: expected: %s, actual: %s (index: %d)",
 				expectedURL, item["url"], i)
@@ -163,6 +171,82 @@ func TestAnalyze(t *testing.T) {
 	}
 }
 
+func TestAnalyzeSpill(t *testing.T) {
+	mid := module.MID("D1|127.0.0.1:8080")
+	parsers := []module.ParseResponse{genTestingRespParser(false), genTestingRespParser(false)}
+	m, _ := New(mid, parsers, nil)
+	a, ok := m.(module.SpillingAnalyzer)
+	if !ok {
+		t.Fatalf("The analyzer %T should implement module.SpillingAnalyzer!", m)
+	}
+	if a.SpillThreshold() != 0 {
+		t.Fatalf("Inconsistent spill threshold: expected: %d, actual: %d", 0, a.SpillThreshold())
+	}
+	a.SetSpillThreshold(-1)
+	if a.SpillThreshold() != 0 {
+		t.Fatalf("Inconsistent spill threshold: expected: %d, actual: %d", 0, a.SpillThreshold())
+	}
+	// 响应体总会超过阈值，每个解析函数都应读到完整的内容。
+	a.SetSpillThreshold(1)
+	resps := getTestingResps(3, "GET", "https://github.com/gopcp", 0, t)
+	for i, resp := range resps {
+		dataList, errs := a.Analyze(resp)
+		if len(errs) != 0 {
+			t.Fatalf("An error occurs when parsing response: %s (index: %d)", errs[0], i)
+		}
+		var items int
+		for _, d := range dataList {
+			if item, ok := d.(module.Item); ok {
+				items++
+				if item["index"] != i {
+					t.Fatalf("Inconsistent index: expected: %d, actual: %v", i, item["index"])
+				}
+			}
+		}
+		if items != len(parsers) {
+			t.Fatalf("Inconsistent item number: expected: %d, actual: %d", len(parsers), items)
+		}
+	}
+}
+
+func TestAnalyzeMeta(t *testing.T) {
+	mid := module.MID("D1|127.0.0.1:8080")
+	parser := func(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+		meta := module.MetaOf(httpResp)
+		category, _ := meta.String("category")
+		childHTTPReq, _ := http.NewRequest("GET", "https://github.com/gopcp/"+category, nil)
+		child := module.NewRequest(childHTTPReq, respDepth)
+		child.Meta().Set("page", 2)
+		return []module.Data{child, module.Item{"category": category}}, nil
+	}
+	a, _ := New(mid, []module.ParseResponse{parser}, nil)
+	httpReq, _ := http.NewRequest("GET", "https://github.com/gopcp", nil)
+	req := module.NewRequest(httpReq, 0)
+	req.Meta().Set("category", "book")
+	req.Meta().Set("page", 1)
+	resp := module.NewResponseByRequest(&http.Response{
+		Request: httpReq,
+		Body:    testingReader{strings.NewReader("")},
+	}, req)
+	dataList, errs := a.Analyze(resp)
+	if len(errs) != 0 || len(dataList) != 2 {
+		t.Fatalf("Inconsistent analyzed result: data: %v, errors: %v", dataList, errs)
+	}
+	if item := dataList[1].(module.Item); item["category"] != "book" {
+		t.Fatalf("The parser should read the metadata of the response: %v", item)
+	}
+	child := dataList[0].(*module.Request)
+	if category, _ := child.Meta().String("category"); category != "book" {
+		t.Fatalf("Inconsistent inherited metadata: expected: %s, actual: %s", "book", category)
+	}
+	if page, _ := child.Meta().Int("page"); page != 2 {
+		t.Fatalf("The metadata set by the parser should be kept: expected: %d, actual: %d", 2, page)
+	}
+	if page, _ := req.Meta().Int("page"); page != 1 {
+		t.Fatalf("The metadata of the parent should not be changed: expected: %d, actual: %d", 1, page)
+	}
+}
+
 func TestCount(t *testing.T) {
 	mid := module.MID("D1|127.0.0.1:8080")
 	// 测试初始化后的计数。
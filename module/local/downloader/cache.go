@@ -0,0 +1,645 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/module/stub"
+)
+
+// CacheMode 代表响应缓存的模式。
+type CacheMode uint8
+
+// 响应缓存的模式
+const (
+	// 不使用缓存
+	CACHE_MODE_OFF CacheMode = iota
+	// 优先使用缓存，未命中时下载并写入缓存
+	CACHE_MODE_READ_WRITE
+	// 只使用缓存，未命中时返回错误而不访问网络，适用于离线开发
+	CACHE_MODE_READ_ONLY
+	// 总是下载并覆盖缓存
+	CACHE_MODE_REFRESH
+)
+
+// cacheModeNames 代表各缓存模式的名称。
+var cacheModeNames = map[CacheMode]string{
+	CACHE_MODE_OFF:        "off",
+	CACHE_MODE_READ_WRITE: "read-write",
+	CACHE_MODE_READ_ONLY:  "read-only",
+	CACHE_MODE_REFRESH:    "refresh",
+}
+
+func (mode CacheMode) String() string {
+	if name, ok := cacheModeNames[mode]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(mode))
+}
+
+// ParseCacheMode 用于根据名称获取缓存模式。
+// 除了 String 方法给出的名称之外，还接受 rw、offline 和 ro。
+func ParseCacheMode(name string) (CacheMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "off":
+		return CACHE_MODE_OFF, nil
+	case "read-write", "rw":
+		return CACHE_MODE_READ_WRITE, nil
+	case "read-only", "ro", "offline":
+		return CACHE_MODE_READ_ONLY, nil
+	case "refresh":
+		return CACHE_MODE_REFRESH, nil
+	}
+	return CACHE_MODE_OFF, genParameterError(fmt.Sprintf("unknown cache mode %q", name))
+}
+
+// CacheConfig 代表响应缓存的配置。
+type CacheConfig struct {
+	// 缓存所在的目录
+	Dir string
+	// 缓存的模式
+	Mode CacheMode
+	// 是否遵循 HTTP 的缓存语义
+	// 为 true 时，只在缓存条目仍然新鲜时直接使用它，
+	// 过期后会依据 ETag 或 Last-Modified 发送条件请求进行再验证，
+	// 并且不会缓存带有 no-store 指令的请求和响应
+	// 为 false 时，在读写模式下总是使用已有的缓存条目
+	HTTPSemantics bool
+}
+
+// cacheEntry 代表缓存条目的元信息，以一行 JSON 的形式保存在缓存文件的开头。
+type cacheEntry struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	FinalURL   string      `json:"final_url"`
+	Redirects  []string    `json:"redirects,omitempty"`
+	Status     string      `json:"status"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	StoredAt   time.Time   `json:"stored_at"`
+	// 响应体的字节数，不保存
+	size int64
+}
+
+// fresh 用于按 HTTP 的缓存语义判断缓存条目在给定的时间是否仍然新鲜。
+// 未给出 max-age 或 Expires 的条目总是被视为已过期。
+func (entry *cacheEntry) fresh(now time.Time) bool {
+	directives := parseCacheControl(entry.Header.Get("Cache-Control"))
+	if _, ok := directives["no-cache"]; ok {
+		return false
+	}
+	age := now.Sub(entry.StoredAt)
+	if seconds, err := strconv.ParseInt(entry.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		age += time.Duration(seconds) * time.Second
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.ParseInt(maxAge, 10, 64)
+		if err != nil {
+			return false
+		}
+		return age < time.Duration(seconds)*time.Second
+	}
+	if expires := entry.Header.Get("Expires"); expires != "" {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			return false
+		}
+		date, err := http.ParseTime(entry.Header.Get("Date"))
+		if err != nil {
+			date = entry.StoredAt
+		}
+		return age < expiresTime.Sub(date)
+	}
+	return false
+}
+
+// update 用于以再验证时收到的 304 响应的头部更新缓存条目。
+func (entry *cacheEntry) update(header http.Header, now time.Time) {
+	if entry.Header == nil {
+		entry.Header = http.Header{}
+	}
+	for key, values := range header {
+		switch key {
+		case "Content-Length", "Transfer-Encoding":
+			continue
+		}
+		entry.Header[key] = values
+	}
+	entry.StoredAt = now
+}
+
+// parseCacheControl 用于解析 Cache-Control 头部中的指令。
+// 指令名称会被转为小写，没有值的指令对应空字符串。
+func parseCacheControl(value string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return directives
+}
+
+// requestKey 用于以请求的方法和 URL 生成键，空的方法等同于 GET。
+func requestKey(method string, url string) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return method + " " + url
+}
+
+// cacheKey 用于生成请求的指纹，作为缓存条目的键。
+func cacheKey(httpReq *http.Request) string {
+	sum := sha256.Sum256([]byte(requestKey(httpReq.Method, httpReq.URL.String())))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachingDownloader 代表带有响应缓存的下载器。
+type cachingDownloader struct {
+	module.Downloader
+	// 缓存所在的目录
+	dir string
+	// 缓存的模式
+	mode CacheMode
+	// 是否遵循 HTTP 的缓存语义
+	httpSemantics bool
+	// 直接使用缓存条目的次数
+	hits uint64
+	// 未使用缓存条目的次数
+	misses uint64
+	// 经再验证后使用缓存条目的次数
+	revalidated uint64
+	// 写入缓存条目的次数
+	stored uint64
+}
+
+// NewCaching 用于创建带有响应缓存的下载器。
+// 缓存以请求的指纹为键保存在本地磁盘上，只有 GET 和 HEAD 请求会使用缓存。
+// 缓存的模式为 CACHE_MODE_OFF 时直接返回原下载器。
+func NewCaching(downloader module.Downloader, config CacheConfig) (module.Downloader, error) {
+	if downloader == nil {
+		return nil, genParameterError("nil downloader")
+	}
+	if _, ok := cacheModeNames[config.Mode]; !ok {
+		return nil, genParameterError(fmt.Sprintf("unknown cache mode %s", config.Mode))
+	}
+	if config.Mode == CACHE_MODE_OFF {
+		return downloader, nil
+	}
+	if config.Dir == "" {
+		return nil, genParameterError("empty cache directory")
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, genError(fmt.Sprintf("cannot create cache directory: %s", err))
+	}
+	return &cachingDownloader{
+		Downloader:    downloader,
+		dir:           config.Dir,
+		mode:          config.Mode,
+		httpSemantics: config.HTTPSemantics,
+	}, nil
+}
+
+func (d *cachingDownloader) Download(req *module.Request) (*module.Response, error) {
+	return d.DownloadContext(context.Background(), req)
+}
+
+func (d *cachingDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
+	if !d.cacheable(req) {
+		return module.DownloadContext(ctx, d.Downloader, req)
+	}
+	key := cacheKey(req.HTTPReq())
+	if d.mode == CACHE_MODE_REFRESH {
+		atomic.AddUint64(&d.misses, 1)
+		return d.fetch(ctx, req, key)
+	}
+	entry, body, err := d.load(key)
+	if err != nil {
+		log.L().Sugar().Warnf("Ignore the broken cache entry: %s (URL: %s)", err, req.HTTPReq().URL)
+	}
+	if entry == nil {
+		atomic.AddUint64(&d.misses, 1)
+		if d.mode == CACHE_MODE_READ_ONLY {
+			d.count(false)
+			return nil, genError(fmt.Sprintf("no cached response in read-only mode (URL: %s)",
+				req.HTTPReq().URL))
+		}
+		return d.fetch(ctx, req, key)
+	}
+	if d.mode == CACHE_MODE_READ_ONLY || !d.httpSemantics ||
+		(entry.fresh(time.Now()) && !d.noCache(req.HTTPReq())) {
+		atomic.AddUint64(&d.hits, 1)
+		d.count(true)
+		return d.serve(ctx, req, entry, body), nil
+	}
+	return d.revalidate(ctx, req, key, entry, body)
+}
+
+// cacheable 用于判断请求是否可以使用缓存。
+func (d *cachingDownloader) cacheable(req *module.Request) bool {
+	if req == nil || req.HTTPReq() == nil || req.HTTPReq().URL == nil {
+		return false
+	}
+	httpReq := req.HTTPReq()
+	if httpReq.Method != "" && httpReq.Method != http.MethodGet && httpReq.Method != http.MethodHead {
+		return false
+	}
+	if d.httpSemantics {
+		if _, ok := parseCacheControl(httpReq.Header.Get("Cache-Control"))["no-store"]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// noCache 用于判断请求是否要求再验证缓存条目。
+func (d *cachingDownloader) noCache(httpReq *http.Request) bool {
+	_, ok := parseCacheControl(httpReq.Header.Get("Cache-Control"))["no-cache"]
+	return ok
+}
+
+// storable 用于判断响应是否可以被缓存。
+// 服务端错误和部分内容的响应不会被缓存。
+func (d *cachingDownloader) storable(httpResp *http.Response) bool {
+	if httpResp == nil || httpResp.Body == nil {
+		return false
+	}
+	if httpResp.StatusCode >= 500 || httpResp.StatusCode == http.StatusPartialContent {
+		return false
+	}
+	if d.httpSemantics {
+		if _, ok := parseCacheControl(httpResp.Header.Get("Cache-Control"))["no-store"]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// count 用于在没有访问网络时更新原下载器的计数。
+func (d *cachingDownloader) count(completed bool) {
+	internal, ok := d.Downloader.(stub.ModuleInternal)
+	if !ok {
+		return
+	}
+	internal.IncrCalledCount()
+	internal.IncrAcceptedCount()
+	if completed {
+		internal.IncrCompletedCount()
+	}
+}
+
+// fetch 用于下载请求，并在读取响应体的同时写入缓存。
+func (d *cachingDownloader) fetch(ctx context.Context, req *module.Request, key string) (*module.Response, error) {
+	resp, err := module.DownloadContext(ctx, d.Downloader, req)
+	if err != nil {
+		return nil, err
+	}
+	d.store(key, req, resp)
+	return resp, nil
+}
+
+// revalidate 用于以条件请求再验证已过期的缓存条目。
+// 条目没有验证器或请求已自带条件时会直接下载。
+func (d *cachingDownloader) revalidate(
+	ctx context.Context,
+	req *module.Request,
+	key string,
+	entry *cacheEntry,
+	body io.ReadCloser) (*module.Response, error) {
+	httpReq := req.HTTPReq()
+	etag := entry.Header.Get("ETag")
+	lastModified := entry.Header.Get("Last-Modified")
+	if (etag == "" && lastModified == "") ||
+		httpReq.Header.Get("If-None-Match") != "" ||
+		httpReq.Header.Get("If-Modified-Since") != "" {
+		body.Close()
+		atomic.AddUint64(&d.misses, 1)
+		return d.fetch(ctx, req, key)
+	}
+	if httpReq.Header == nil {
+		httpReq.Header = http.Header{}
+	}
+	if etag != "" {
+		httpReq.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		httpReq.Header.Set("If-Modified-Since", lastModified)
+	}
+	resp, err := module.DownloadContext(ctx, d.Downloader, req)
+	// 请求在重试时会被再次使用，所以要去掉条件
+	httpReq.Header.Del("If-None-Match")
+	httpReq.Header.Del("If-Modified-Since")
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp.StatusCode != http.StatusNotModified {
+		body.Close()
+		atomic.AddUint64(&d.misses, 1)
+		d.store(key, req, resp)
+		return resp, nil
+	}
+	httpResp.Body.Close()
+	atomic.AddUint64(&d.revalidated, 1)
+	entry.update(httpResp.Header, time.Now())
+	if err := d.rewrite(key, entry, body); err != nil {
+		log.L().Sugar().Warnf("An error occurs when updating the cache entry: %s (URL: %s)", err, httpReq.URL)
+	}
+	entry, body, err = d.load(key)
+	if err != nil || entry == nil {
+		return nil, genError(fmt.Sprintf("cannot reload the cache entry: %v (URL: %s)", err, httpReq.URL))
+	}
+	return d.serve(ctx, req, entry, body), nil
+}
+
+// serve 用于以缓存条目生成响应。
+func (d *cachingDownloader) serve(
+	ctx context.Context,
+	req *module.Request,
+	entry *cacheEntry,
+	body io.ReadCloser) *module.Response {
+	return newStoredResponse(ctx, d.ID(), req, storedResponse{
+		finalURL:   entry.FinalURL,
+		redirects:  entry.Redirects,
+		status:     entry.Status,
+		statusCode: entry.StatusCode,
+		header:     entry.Header,
+		body:       body,
+		size:       entry.size,
+	})
+}
+
+// storedResponse 代表保存在本地的响应。
+type storedResponse struct {
+	// 经过重定向之后最终的 URL
+	finalURL string
+	// 依次经过的被重定向的 URL
+	redirects  []string
+	status     string
+	statusCode int
+	header     http.Header
+	body       io.ReadCloser
+	// 响应体的字节数，未知时为 -1
+	size int64
+}
+
+// newStoredResponse 用于以保存在本地的响应生成响应，不会访问网络。
+func newStoredResponse(
+	ctx context.Context,
+	mid module.MID,
+	req *module.Request,
+	stored storedResponse) *module.Response {
+	trace := &module.DownloadTrace{
+		MID:       mid,
+		Attempt:   req.Attempt(),
+		StartTime: time.Now(),
+		Redirects: append([]string(nil), stored.redirects...),
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	httpReq := req.HTTPReq().WithContext(module.WithTrace(ctx, trace))
+	if finalURL, err := url.Parse(stored.finalURL); err == nil && stored.finalURL != "" {
+		httpReq.URL = finalURL
+	}
+	header := stored.header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	httpResp := &http.Response{
+		Status:        stored.status,
+		StatusCode:    stored.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          module.NewTracedBody(stored.body, trace),
+		ContentLength: stored.size,
+		Request:       httpReq,
+	}
+	resp := module.NewResponseByRequest(httpResp, req)
+	resp.SetTrace(trace)
+	return resp
+}
+
+// path 用于获取缓存条目对应的文件的路径。
+func (d *cachingDownloader) path(key string) string {
+	return filepath.Join(d.dir, key[:2], key+".cache")
+}
+
+// load 用于读取缓存条目，条目不存在时结果值均为 nil。
+// 返回的响应体需要由调用方关闭。
+func (d *cachingDownloader) load(key string) (*cacheEntry, io.ReadCloser, error) {
+	file, err := os.Open(d.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(file)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	entry.size = info.Size() - int64(len(line))
+	return &entry, &cacheBody{reader, file}, nil
+}
+
+// create 用于创建写有缓存条目元信息的临时文件。
+func (d *cachingDownloader) create(key string, entry *cacheEntry) (*os.File, error) {
+	dir := filepath.Dir(d.path(key))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(dir, key+"-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+// commit 用于关闭临时文件并以它替换缓存条目。
+func (d *cachingDownloader) commit(key string, file *os.File) error {
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), d.path(key)); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	atomic.AddUint64(&d.stored, 1)
+	return nil
+}
+
+// rewrite 用于以新的元信息和原有的响应体替换缓存条目。
+// 会关闭原有的响应体。
+func (d *cachingDownloader) rewrite(key string, entry *cacheEntry, body io.ReadCloser) error {
+	defer body.Close()
+	file, err := d.create(key, entry)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	return d.commit(key, file)
+}
+
+// store 用于在读取响应体的同时把响应写入缓存。
+// 只有响应体被完整读取后才会生成缓存条目。
+func (d *cachingDownloader) store(key string, req *module.Request, resp *module.Response) {
+	httpResp := resp.HTTPResp()
+	if !d.storable(httpResp) {
+		return
+	}
+	httpReq := req.HTTPReq()
+	entry := &cacheEntry{
+		Method:     httpReq.Method,
+		URL:        httpReq.URL.String(),
+		FinalURL:   resp.FinalURL(),
+		Status:     httpResp.Status,
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+		StoredAt:   time.Now(),
+	}
+	if entry.Method == "" {
+		entry.Method = http.MethodGet
+	}
+	if trace := resp.Trace(); trace != nil {
+		entry.Redirects = trace.Redirects
+	}
+	file, err := d.create(key, entry)
+	if err != nil {
+		log.L().Sugar().Warnf("An error occurs when creating the cache entry: %s (URL: %s)", err, httpReq.URL)
+		return
+	}
+	httpResp.Body = &storingBody{
+		ReadCloser: httpResp.Body,
+		file:       file,
+		commit: func(file *os.File) {
+			if err := d.commit(key, file); err != nil {
+				log.L().Sugar().Warnf("An error occurs when storing the cache entry: %s (URL: %s)", err, httpReq.URL)
+			}
+		},
+	}
+}
+
+// cacheBody 代表从缓存文件中读取的响应体。
+type cacheBody struct {
+	*bufio.Reader
+	file *os.File
+}
+
+func (b *cacheBody) Close() error {
+	return b.file.Close()
+}
+
+// storingBody 代表会把读到的内容写入临时文件的响应体。
+// 读到末尾时提交临时文件，在此之前被关闭或写入出错时则放弃它。
+type storingBody struct {
+	io.ReadCloser
+	file   *os.File
+	commit func(file *os.File)
+}
+
+func (b *storingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.file != nil {
+		if n > 0 {
+			if _, werr := b.file.Write(p[:n]); werr != nil {
+				b.discard()
+			}
+		}
+		if err == io.EOF && b.file != nil {
+			file := b.file
+			b.file = nil
+			b.commit(file)
+		}
+	}
+	return n, err
+}
+
+func (b *storingBody) Close() error {
+	b.discard()
+	return b.ReadCloser.Close()
+}
+
+// discard 用于放弃临时文件。
+func (b *storingBody) discard() {
+	if b.file == nil {
+		return
+	}
+	b.file.Close()
+	os.Remove(b.file.Name())
+	b.file = nil
+}
+
+// cacheSummaryStruct 代表响应缓存额外信息的摘要类型。
+type cacheSummaryStruct struct {
+	Mode          string      `json:"mode"`
+	Dir           string      `json:"dir"`
+	HTTPSemantics bool        `json:"http_semantics"`
+	Hits          uint64      `json:"hits"`
+	Misses        uint64      `json:"misses"`
+	Revalidated   uint64      `json:"revalidated"`
+	Stored        uint64      `json:"stored"`
+	Extra         interface{} `json:"extra,omitempty"`
+}
+
+func (d *cachingDownloader) Summary() module.SummaryStruct {
+	summary := d.Downloader.Summary()
+	summary.Extra = cacheSummaryStruct{
+		Mode:          d.mode.String(),
+		Dir:           d.dir,
+		HTTPSemantics: d.httpSemantics,
+		Hits:          atomic.LoadUint64(&d.hits),
+		Misses:        atomic.LoadUint64(&d.misses),
+		Revalidated:   atomic.LoadUint64(&d.revalidated),
+		Stored:        atomic.LoadUint64(&d.stored),
+		Extra:         summary.Extra,
+	}
+	return summary
+}
@@ -0,0 +1,218 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// genCacheServer 用于生成测试响应缓存用的 HTTP 服务器。
+// 结果值中的计数器记录服务器收到的请求数。
+func genCacheServer() (*httptest.Server, *uint64) {
+	var hits uint64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddUint64(&hits, 1)
+		fmt.Fprintf(w, "page %d", n)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&hits, 1)
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/etag", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddUint64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, "etag %d", n)
+	})
+	mux.HandleFunc("/fresh", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddUint64(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		fmt.Fprintf(w, "fresh %d", n)
+	})
+	mux.HandleFunc("/nostore", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddUint64(&hits, 1)
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprintf(w, "nostore %d", n)
+	})
+	return httptest.NewServer(mux), &hits
+}
+
+// genCachingDownloader 用于生成测试用的带有响应缓存的下载器。
+func genCachingDownloader(t *testing.T, config CacheConfig) module.Downloader {
+	d, err := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a downloader: %s", err)
+	}
+	cd, err := NewCaching(d, config)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a caching downloader: %s (config: %#v)",
+			err, config)
+	}
+	return cd
+}
+
+// fetchBody 用于下载给定的 URL 并读取整个响应体。
+func fetchBody(t *testing.T, d module.Downloader, url string) (*module.Response, string) {
+	httpReq, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := d.Download(module.NewRequest(httpReq, 0))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s (url: %s)", err, url)
+	}
+	body, err := io.ReadAll(resp.HTTPResp().Body)
+	resp.HTTPResp().Body.Close()
+	if err != nil {
+		t.Fatalf("An error occurs when reading HTTP response body: %s (url: %s)", err, url)
+	}
+	return resp, string(body)
+}
+
+func TestNewCaching(t *testing.T) {
+	d, _ := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+	cd, err := NewCaching(d, CacheConfig{})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a caching downloader: %s", err)
+	}
+	if cd != d {
+		t.Fatal("The downloader should be returned as is when the cache is off!")
+	}
+	if _, err := NewCaching(nil, CacheConfig{Mode: CACHE_MODE_READ_WRITE, Dir: t.TempDir()}); err == nil {
+		t.Fatal("No error when creating a caching downloader with nil downloader!")
+	}
+	if _, err := NewCaching(d, CacheConfig{Mode: CACHE_MODE_READ_WRITE}); err == nil {
+		t.Fatal("No error when creating a caching downloader with empty directory!")
+	}
+	if _, err := NewCaching(d, CacheConfig{Mode: CacheMode(9), Dir: t.TempDir()}); err == nil {
+		t.Fatal("No error when creating a caching downloader with unknown mode!")
+	}
+	for name, expected := range map[string]CacheMode{
+		"":           CACHE_MODE_OFF,
+		"rw":         CACHE_MODE_READ_WRITE,
+		"Read-Only":  CACHE_MODE_READ_ONLY,
+		"offline":    CACHE_MODE_READ_ONLY,
+		"refresh":    CACHE_MODE_REFRESH,
+		"read-write": CACHE_MODE_READ_WRITE,
+	} {
+		mode, err := ParseCacheMode(name)
+		if err != nil {
+			t.Fatalf("An error occurs when parsing cache mode %q: %s", name, err)
+		}
+		if mode != expected {
+			t.Fatalf("Inconsistent cache mode for %q: expected: %s, actual: %s",
+				name, expected, mode)
+		}
+	}
+	if _, err := ParseCacheMode("always"); err == nil {
+		t.Fatal("No error when parsing unknown cache mode!")
+	}
+}
+
+func TestCachingDownload(t *testing.T) {
+	server, hits := genCacheServer()
+	defer server.Close()
+	dir := t.TempDir()
+	d := genCachingDownloader(t, CacheConfig{Dir: dir, Mode: CACHE_MODE_READ_WRITE})
+	url := server.URL + "/moved"
+	_, first := fetchBody(t, d, url)
+	resp, second := fetchBody(t, d, url)
+	if first != second {
+		t.Fatalf("Inconsistent cached body: expected: %q, actual: %q", first, second)
+	}
+	if n := atomic.LoadUint64(hits); n != 2 {
+		t.Fatalf("Inconsistent server hits: expected: %d, actual: %d", 2, n)
+	}
+	if resp.FinalURL() != server.URL+"/page" {
+		t.Fatalf("Inconsistent final URL: expected: %s, actual: %s",
+			server.URL+"/page", resp.FinalURL())
+	}
+	if trace := resp.Trace(); trace == nil || len(trace.Redirects) != 1 || trace.Redirects[0] != url {
+		t.Fatalf("Inconsistent redirects of cached response: %#v", trace)
+	}
+	if d.CalledCount() != 2 || d.CompletedCount() != 2 {
+		t.Fatalf("Inconsistent counts: called: %d, completed: %d",
+			d.CalledCount(), d.CompletedCount())
+	}
+	extra := d.Summary().Extra.(cacheSummaryStruct)
+	if extra.Hits != 1 || extra.Misses != 1 || extra.Stored != 1 {
+		t.Fatalf("Inconsistent cache summary: %#v", extra)
+	}
+	// 只读模式下不会访问网络
+	d = genCachingDownloader(t, CacheConfig{Dir: dir, Mode: CACHE_MODE_READ_ONLY})
+	_, body := fetchBody(t, d, url)
+	if body != first {
+		t.Fatalf("Inconsistent cached body: expected: %q, actual: %q", first, body)
+	}
+	httpReq, _ := http.NewRequest(http.MethodGet, server.URL+"/page", nil)
+	if _, err := d.Download(module.NewRequest(httpReq, 0)); err == nil {
+		t.Fatal("No error when the cache misses in read-only mode!")
+	}
+	if n := atomic.LoadUint64(hits); n != 2 {
+		t.Fatalf("Inconsistent server hits in read-only mode: expected: %d, actual: %d", 2, n)
+	}
+	// 刷新模式下总是下载并覆盖缓存
+	d = genCachingDownloader(t, CacheConfig{Dir: dir, Mode: CACHE_MODE_REFRESH})
+	_, refreshed := fetchBody(t, d, url)
+	if refreshed == first {
+		t.Fatalf("The body %q should be refreshed!", refreshed)
+	}
+	d = genCachingDownloader(t, CacheConfig{Dir: dir, Mode: CACHE_MODE_READ_ONLY})
+	if _, body := fetchBody(t, d, url); body != refreshed {
+		t.Fatalf("Inconsistent refreshed body: expected: %q, actual: %q", refreshed, body)
+	}
+	// 未读完的响应体不会被缓存
+	d = genCachingDownloader(t, CacheConfig{Dir: t.TempDir(), Mode: CACHE_MODE_READ_WRITE})
+	httpReq, _ = http.NewRequest(http.MethodGet, server.URL+"/page", nil)
+	resp, err := d.Download(module.NewRequest(httpReq, 0))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s", err)
+	}
+	resp.HTTPResp().Body.Close()
+	if extra := d.Summary().Extra.(cacheSummaryStruct); extra.Stored != 0 {
+		t.Fatalf("The unread body should not be cached: %#v", extra)
+	}
+}
+
+func TestCachingHTTPSemantics(t *testing.T) {
+	server, hits := genCacheServer()
+	defer server.Close()
+	d := genCachingDownloader(t, CacheConfig{
+		Dir:           t.TempDir(),
+		Mode:          CACHE_MODE_READ_WRITE,
+		HTTPSemantics: true,
+	})
+	_, first := fetchBody(t, d, server.URL+"/etag")
+	resp, second := fetchBody(t, d, server.URL+"/etag")
+	if first != second {
+		t.Fatalf("Inconsistent revalidated body: expected: %q, actual: %q", first, second)
+	}
+	if resp.HTTPResp().StatusCode != http.StatusOK {
+		t.Fatalf("Inconsistent status code of revalidated response: expected: %d, actual: %d",
+			http.StatusOK, resp.HTTPResp().StatusCode)
+	}
+	if n := atomic.LoadUint64(hits); n != 2 {
+		t.Fatalf("Inconsistent server hits: expected: %d, actual: %d", 2, n)
+	}
+	extra := d.Summary().Extra.(cacheSummaryStruct)
+	if extra.Revalidated != 1 {
+		t.Fatalf("Inconsistent revalidated count: expected: %d, actual: %d", 1, extra.Revalidated)
+	}
+	fetchBody(t, d, server.URL+"/fresh")
+	fetchBody(t, d, server.URL+"/fresh")
+	if n := atomic.LoadUint64(hits); n != 3 {
+		t.Fatalf("Inconsistent server hits for fresh entry: expected: %d, actual: %d", 3, n)
+	}
+	_, first = fetchBody(t, d, server.URL+"/nostore")
+	_, second = fetchBody(t, d, server.URL+"/nostore")
+	if first == second {
+		t.Fatalf("The no-store response %q should not be cached!", first)
+	}
+}
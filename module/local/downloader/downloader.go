@@ -1,7 +1,12 @@
 package downloader
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
 
 	"github.com/dokidokikoi/webcrawler/log"
 	"github.com/dokidokikoi/webcrawler/module"
@@ -15,6 +20,11 @@ type myDownloader struct {
 }
 
 func (d *myDownloader) Download(req *module.Request) (*module.Response, error) {
+	return d.DownloadContext(context.Background(), req)
+}
+
+// DownloadContext 会用给定的上下文替换 HTTP 请求原有的上下文。
+func (d *myDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
 	d.ModuleInternal.IncrHandlingNumber()
 	defer d.ModuleInternal.DecrHandlingNumber()
 
@@ -28,12 +38,71 @@ func (d *myDownloader) Download(req *module.Request) (*module.Response, error) {
 	}
 	d.ModuleInternal.IncrAcceptedCount()
 	log.L().Sugar().Infof("Do the request (URL: %s, depth: %d)... \n", httpReq.URL, req.Depth())
-	httpResp, err := d.httpClient.Do(httpReq)
+	trace := &module.DownloadTrace{
+		MID:       d.ID(),
+		Attempt:   req.Attempt(),
+		StartTime: time.Now(),
+	}
+	clientTrace := &httptrace.ClientTrace{
+		// 发生重定向时会被多次调用，最终保留的是最后一个响应的时长
+		GotFirstResponseByte: func() {
+			trace.TTFB = time.Since(trace.StartTime)
+		},
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = httptrace.WithClientTrace(module.WithTrace(ctx, trace), clientTrace)
+	httpResp, err := d.httpClient.Do(withReferer(httpReq.WithContext(ctx), req.ParentURL()))
 	if err != nil {
 		return nil, err
 	}
+	httpResp.Body = module.NewTracedBody(httpResp.Body, trace)
 	d.ModuleInternal.IncrCompletedCount()
-	return module.NewResponse(httpResp, req.Depth()), nil
+	resp := module.NewResponseByRequest(httpResp, req)
+	resp.SetTrace(trace)
+	return resp, nil
+}
+
+// withReferer 用于在 HTTP 请求中设置 Referer 请求头。
+// 请求头会先被复制，以免修改被检查点、死信记录和重试共享的原请求。
+func withReferer(httpReq *http.Request, parentURL string) *http.Request {
+	if parentURL == "" || httpReq.Header.Get("Referer") != "" {
+		return httpReq
+	}
+	// 与浏览器一致，不从 HTTPS 页面向 HTTP 页面发送 Referer
+	if strings.HasPrefix(parentURL, "https:") && httpReq.URL.Scheme == "http" {
+		return httpReq
+	}
+	header := httpReq.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Referer", parentURL)
+	httpReq.Header = header
+	return httpReq
+}
+
+// checkRedirect 用于在遵循重定向之前记录被重定向的 URL。
+// 是否遵循重定向仍由 HTTP 客户端原有的策略决定。
+func checkRedirect(policy func(req *http.Request, via []*http.Request) error) func(
+	req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		var err error
+		if policy != nil {
+			err = policy(req, via)
+		} else if len(via) >= 10 {
+			// 与 HTTP 客户端默认的策略一致
+			err = errors.New("stopped after 10 redirects")
+		}
+		if err != nil {
+			return err
+		}
+		if trace := module.TraceFromContext(req.Context()); trace != nil {
+			trace.Redirects = append(trace.Redirects, via[len(via)-1].URL.String())
+		}
+		return nil
+	}
 }
 
 func New(mid module.MID, client *http.Client, scoreCalculator module.CalculateScore) (module.Downloader, error) {
@@ -44,5 +113,7 @@ func New(mid module.MID, client *http.Client, scoreCalculator module.CalculateSc
 	if client == nil {
 		return nil, genParameterError("nil http client")
 	}
-	return &myDownloader{moduleBase, *client}, nil
+	httpClient := *client
+	httpClient.CheckRedirect = checkRedirect(client.CheckRedirect)
+	return &myDownloader{moduleBase, httpClient}, nil
 }
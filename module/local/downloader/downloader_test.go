@@ -2,8 +2,13 @@ package downloader
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/dokidokikoi/webcrawler/module"
 	"github.com/dokidokikoi/webcrawler/module/stub"
@@ -205,3 +210,170 @@ func TestCount(t *testing.T) {
 			0, di.HandlingNumber())
 	}
 }
+
+func TestReferer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Referer()))
+	}))
+	defer server.Close()
+	d, _ := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+	cases := []struct {
+		parentURL string
+		referer   string
+		expected  string
+	}{
+		{"", "", ""},
+		{server.URL + "/parent", "", server.URL + "/parent"},
+		{server.URL + "/parent", "http://example.com/", "http://example.com/"},
+		{"https://example.com/parent", "", ""},
+	}
+	for _, c := range cases {
+		httpReq, _ := http.NewRequest("GET", server.URL+"/child", nil)
+		if c.referer != "" {
+			httpReq.Header.Set("Referer", c.referer)
+		}
+		req := module.NewRequest(httpReq, 1)
+		req.SetLineage(c.parentURL, server.URL)
+		resp, err := d.Download(req)
+		if err != nil {
+			t.Fatalf("An error occurs when downloading content: %s", err)
+		}
+		line, _, _ := bufio.NewReader(resp.HTTPResp().Body).ReadLine()
+		resp.HTTPResp().Body.Close()
+		if string(line) != c.expected {
+			t.Fatalf("Inconsistent referer: expected: %q, actual: %q (parent: %q)",
+				c.expected, line, c.parentURL)
+		}
+		if httpReq.Header.Get("Referer") != c.referer {
+			t.Fatalf("The header of the original request should not be changed: %q",
+				httpReq.Header.Get("Referer"))
+		}
+		if resp.ParentURL() != c.parentURL || resp.RootURL() != server.URL {
+			t.Fatalf("Inconsistent lineage for response: parent: %q, root: %q",
+				resp.ParentURL(), resp.RootURL())
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	body := "final content"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+		default:
+			w.Write([]byte(body))
+		}
+	}))
+	defer server.Close()
+	mid := module.MID("D1|127.0.0.1:8080")
+	d, _ := New(mid, &http.Client{}, nil)
+	httpReq, _ := http.NewRequest("GET", server.URL+"/a", nil)
+	req := module.NewRequest(httpReq, 0)
+	req.SetAttempt(2)
+	resp, err := d.Download(req)
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s", err)
+	}
+	trace := resp.Trace()
+	if trace == nil {
+		t.Fatal("Couldn't get the download trace!")
+	}
+	if module.TraceOf(resp.HTTPResp()) != trace {
+		t.Fatal("Inconsistent download trace for HTTP response!")
+	}
+	if trace.MID != mid || trace.Attempt != 2 {
+		t.Fatalf("Inconsistent download trace: MID: %s, attempt: %d", trace.MID, trace.Attempt)
+	}
+	if resp.FinalURL() != server.URL+"/c" {
+		t.Fatalf("Inconsistent final URL: expected: %s, actual: %s",
+			server.URL+"/c", resp.FinalURL())
+	}
+	expectedRedirects := []string{server.URL + "/a", server.URL + "/b"}
+	if len(trace.Redirects) != len(expectedRedirects) ||
+		trace.Redirects[0] != expectedRedirects[0] ||
+		trace.Redirects[1] != expectedRedirects[1] {
+		t.Fatalf("Inconsistent redirects: expected: %v, actual: %v",
+			expectedRedirects, trace.Redirects)
+	}
+	if trace.TTFB <= 0 || trace.StartTime.IsZero() {
+		t.Fatalf("Inconsistent timing: start: %s, TTFB: %s", trace.StartTime, trace.TTFB)
+	}
+	if !trace.EndTime().IsZero() || trace.Duration() != 0 {
+		t.Fatal("The download was finished before reading the body!")
+	}
+	line, _, _ := bufio.NewReader(resp.HTTPResp().Body).ReadLine()
+	resp.HTTPResp().Body.Close()
+	if string(line) != body || trace.BytesRead() != int64(len(body)) {
+		t.Fatalf("Inconsistent bytes read: expected: %d, actual: %d",
+			len(body), trace.BytesRead())
+	}
+	if trace.EndTime().IsZero() || trace.Duration() < trace.TTFB {
+		t.Fatalf("Inconsistent duration: %s (TTFB: %s)", trace.Duration(), trace.TTFB)
+	}
+
+	// 不遵循重定向时不记录重定向链。
+	d, _ = New(mid, &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil)
+	httpReq, _ = http.NewRequest("GET", server.URL+"/a", nil)
+	resp, err = d.Download(module.NewRequest(httpReq, 0))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s", err)
+	}
+	resp.HTTPResp().Body.Close()
+	if resp.HTTPResp().StatusCode != http.StatusFound || len(resp.Trace().Redirects) != 0 {
+		t.Fatalf("Inconsistent response: status: %d, redirects: %v",
+			resp.HTTPResp().StatusCode, resp.Trace().Redirects)
+	}
+}
+
+func TestDownloadContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+	d, _ := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+	if _, ok := d.(module.ContextDownloader); !ok {
+		t.Fatalf("The downloader %T should implement module.ContextDownloader!", d)
+	}
+
+	// 已取消的上下文。
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	httpReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if _, err := module.DownloadContext(ctx, d, module.NewRequest(httpReq, 0)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Inconsistent error for canceled context: %v", err)
+	}
+
+	// 超时会中止响应体的读取。
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	httpReq, _ = http.NewRequest("GET", server.URL+"/slow", nil)
+	resp, err := module.DownloadContext(ctx, d, module.NewRequest(httpReq, 0))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s", err)
+	}
+	defer resp.HTTPResp().Body.Close()
+	begin := time.Now()
+	_, err = io.ReadAll(resp.HTTPResp().Body)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Inconsistent error when reading body: %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("The body reading was not aborted in time! (elapsed: %s)", elapsed)
+	}
+}
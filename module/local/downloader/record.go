@@ -0,0 +1,587 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/module/stub"
+	"github.com/dokidokikoi/webcrawler/toolkit/har"
+	"github.com/dokidokikoi/webcrawler/toolkit/warc"
+)
+
+// 录制文件的格式
+const (
+	RECORD_FORMAT_HAR  = "har"
+	RECORD_FORMAT_WARC = "warc"
+)
+
+// 每个响应体默认最多录制的字节数
+const DEFAULT_MAX_RECORD_BODY_SIZE = 10 << 20
+
+// recordFormat 用于根据文件的扩展名判断录制文件的格式。
+// .har 对应 HAR，.warc 和 .warc.gz 对应 WARC，后者的每条记录会被单独压缩。
+func recordFormat(path string) (format string, compress bool, err error) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".har"):
+		return RECORD_FORMAT_HAR, false, nil
+	case strings.HasSuffix(lower, ".warc"):
+		return RECORD_FORMAT_WARC, false, nil
+	case strings.HasSuffix(lower, ".warc.gz"):
+		return RECORD_FORMAT_WARC, true, nil
+	}
+	return "", false, genParameterError(fmt.Sprintf("unknown record format of %q", path))
+}
+
+// exchange 代表一对录制下来的请求和响应。
+type exchange struct {
+	method        string
+	url           string
+	requestHeader http.Header
+	startTime     time.Time
+	ttfb          time.Duration
+	duration      time.Duration
+	// 经过重定向之后最终的 URL
+	finalURL string
+	// 依次经过的被重定向的 URL
+	redirects  []string
+	proto      string
+	status     string
+	statusCode int
+	header     http.Header
+	body       []byte
+	// 响应体被截断的原因，为空时代表未被截断
+	truncated string
+}
+
+// exchangeWriter 代表录制文件的写入器。
+type exchangeWriter interface {
+	write(ex *exchange) error
+	close() error
+}
+
+// harWriter 代表 HAR 格式的录制文件的写入器。
+// 每对请求和响应会被立即写为一个条目，关闭时写入文件的结尾。
+type harWriter struct {
+	file   *os.File
+	writer *har.Writer
+}
+
+func (w *harWriter) write(ex *exchange) error {
+	query := []har.NameValue{}
+	if u, err := url.Parse(ex.url); err == nil {
+		query = har.Headers(http.Header(u.Query()))
+	}
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	content := har.NewContent(ex.body, ex.header.Get("Content-Type"))
+	content.Truncated = ex.truncated != ""
+	return w.writer.WriteEntry(har.Entry{
+		StartedDateTime: ex.startTime.Format(time.RFC3339Nano),
+		Time:            ms(ex.duration),
+		Request: har.Request{
+			Method:      ex.method,
+			URL:         ex.url,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []har.NameValue{},
+			Headers:     har.Headers(ex.requestHeader),
+			QueryString: query,
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: har.Response{
+			Status:      ex.statusCode,
+			StatusText:  strings.TrimSpace(strings.TrimPrefix(ex.status, fmt.Sprint(ex.statusCode))),
+			HTTPVersion: ex.proto,
+			Cookies:     []har.NameValue{},
+			Headers:     har.Headers(ex.header),
+			Content:     content,
+			RedirectURL: ex.header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    int64(len(ex.body)),
+		},
+		Timings: har.Timings{
+			Send:    0,
+			Wait:    ms(ex.ttfb),
+			Receive: ms(ex.duration - ex.ttfb),
+		},
+		FinalURL:  ex.finalURL,
+		Redirects: ex.redirects,
+	})
+}
+
+func (w *harWriter) close() error {
+	if err := w.writer.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// warcWriter 代表 WARC 格式的录制文件的写入器。
+// 每对请求和响应会被写为一条 request 记录和一条 response 记录。
+type warcWriter struct {
+	file   *os.File
+	writer *warc.Writer
+}
+
+func (w *warcWriter) write(ex *exchange) error {
+	records, err := exchangeRecords(ex)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, err := w.writer.WriteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *warcWriter) close() error {
+	return w.file.Close()
+}
+
+// exchangeRecords 用于把一对请求和响应转换为 WARC 记录。
+func exchangeRecords(ex *exchange) ([]*warc.Record, error) {
+	warcExchange := &warc.Exchange{
+		Date:          ex.startTime,
+		Method:        ex.method,
+		URL:           ex.url,
+		RequestHeader: ex.requestHeader,
+		FinalURL:      ex.finalURL,
+		Proto:         ex.proto,
+		Status:        ex.status,
+		Header:        ex.header,
+		Body:          ex.body,
+		Truncated:     ex.truncated,
+	}
+	return warcExchange.Records()
+}
+
+// Recorder 代表会把请求和响应录制到文件中的下载器。
+type Recorder interface {
+	module.Downloader
+	// 写入尚未写入的内容并关闭录制文件
+	// 关闭之后的下载不会再被录制
+	Close() error
+}
+
+// RecordConfig 代表录制的配置。
+type RecordConfig struct {
+	// 录制文件的路径，格式由扩展名决定，可以是 .har、.warc 或 .warc.gz
+	Path string
+	// 每个响应体最多录制的字节数，为 0 时使用 DEFAULT_MAX_RECORD_BODY_SIZE
+	// 超过的部分不会被录制，录制下来的响应会被标记为已截断
+	MaxBodySize int64
+}
+
+// recordingDownloader 代表录制请求和响应的下载器。
+type recordingDownloader struct {
+	module.Downloader
+	// 录制文件的写入器
+	writer exchangeWriter
+	// 每个响应体最多录制的字节数
+	maxBodySize int64
+	// 是否已关闭
+	closed bool
+	// 保护写入器的互斥锁
+	lock sync.Mutex
+}
+
+// NewRecorder 用于创建会把请求和响应录制到给定文件中的下载器。
+// 只有响应体被读完或被关闭时才会录制，录制的是已被读取的部分，
+// 没有读完的响应会被标记为已截断。每对请求和响应会被立即写入文件。
+func NewRecorder(downloader module.Downloader, config RecordConfig) (Recorder, error) {
+	if downloader == nil {
+		return nil, genParameterError("nil downloader")
+	}
+	if config.MaxBodySize < 0 {
+		return nil, genParameterError(fmt.Sprintf("illegal max record body size: %d", config.MaxBodySize))
+	}
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = DEFAULT_MAX_RECORD_BODY_SIZE
+	}
+	format, compress, err := recordFormat(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(config.Path)
+	if err != nil {
+		return nil, genError(fmt.Sprintf("cannot create record file: %s", err))
+	}
+	var writer exchangeWriter
+	switch format {
+	case RECORD_FORMAT_HAR:
+		writer = &harWriter{file, har.NewWriter(file, "webcrawler")}
+	case RECORD_FORMAT_WARC:
+		writer = &warcWriter{file, warc.NewWriter(file, compress)}
+	}
+	return &recordingDownloader{
+		Downloader:  downloader,
+		writer:      writer,
+		maxBodySize: config.MaxBodySize,
+	}, nil
+}
+
+func (d *recordingDownloader) Download(req *module.Request) (*module.Response, error) {
+	return d.DownloadContext(context.Background(), req)
+}
+
+func (d *recordingDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
+	startTime := time.Now()
+	resp, err := module.DownloadContext(ctx, d.Downloader, req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq := req.HTTPReq()
+	httpResp := resp.HTTPResp()
+	if httpResp == nil || httpResp.Body == nil {
+		return resp, nil
+	}
+	ex := &exchange{
+		method:        httpReq.Method,
+		url:           httpReq.URL.String(),
+		requestHeader: httpReq.Header.Clone(),
+		startTime:     startTime,
+		finalURL:      resp.FinalURL(),
+		proto:         httpResp.Proto,
+		status:        httpResp.Status,
+		statusCode:    httpResp.StatusCode,
+		header:        httpResp.Header.Clone(),
+	}
+	if ex.method == "" {
+		ex.method = http.MethodGet
+	}
+	if ex.proto == "" {
+		ex.proto = "HTTP/1.1"
+	}
+	if ex.requestHeader == nil {
+		ex.requestHeader = http.Header{}
+	}
+	trace := resp.Trace()
+	httpResp.Body = &recordingBody{
+		ReadCloser: httpResp.Body,
+		limit:      d.maxBodySize,
+		size:       httpResp.ContentLength,
+		done: func(body []byte, truncated string) {
+			ex.body = body
+			ex.truncated = truncated
+			ex.duration = time.Since(startTime)
+			if trace != nil {
+				ex.startTime = trace.StartTime
+				ex.ttfb = trace.TTFB
+				ex.redirects = trace.Redirects
+			}
+			if err := d.write(ex); err != nil {
+				log.L().Sugar().Warnf("An error occurs when recording the response: %s (URL: %s)", err, ex.url)
+			}
+		},
+	}
+	return resp, nil
+}
+
+// write 用于把一对请求和响应写入录制文件。
+func (d *recordingDownloader) write(ex *exchange) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return fmt.Errorf("the recorder has been closed")
+	}
+	return d.writer.write(ex)
+}
+
+func (d *recordingDownloader) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	return d.writer.close()
+}
+
+// recordingBody 代表会保存读到的内容的响应体。
+// 读到末尾或被关闭时会以保存的内容以及截断的原因调用 done，且只调用一次。
+type recordingBody struct {
+	io.ReadCloser
+	buf bytes.Buffer
+	// 最多保存的字节数
+	limit int64
+	// 响应体的长度，为 -1 时代表未知
+	size int64
+	// 已读到的字节数
+	read int64
+	// 是否有内容因超过 limit 而未被保存
+	exceeded bool
+	done     func(body []byte, truncated string)
+	once     sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	kept := int64(n)
+	if remaining := b.limit - int64(b.buf.Len()); kept > remaining {
+		kept = remaining
+		b.exceeded = true
+	}
+	b.buf.Write(p[:kept])
+	if err == io.EOF {
+		b.finish(true)
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.finish(false)
+	return b.ReadCloser.Close()
+}
+
+// finish 用于在读到末尾或被关闭时调用 done。
+func (b *recordingBody) finish(eof bool) {
+	b.once.Do(func() {
+		truncated := ""
+		switch {
+		case b.exceeded:
+			truncated = warc.TRUNCATED_LENGTH
+		case !eof && (b.size < 0 || b.read < b.size):
+			// 读取方没有读完响应体
+			truncated = warc.TRUNCATED_UNSPECIFIED
+		}
+		b.done(b.buf.Bytes(), truncated)
+	})
+}
+
+// replayDownloader 代表以录制文件中的响应回放下载的下载器。
+type replayDownloader struct {
+	stub.ModuleInternal
+	// 以方法和 URL 为键的录制下来的请求和响应
+	exchanges map[string][]*exchange
+	// 各个键下一次使用的序号
+	next map[string]int
+	// 保护序号的互斥锁
+	lock sync.Mutex
+}
+
+// NewReplay 用于创建以录制文件中的响应回放下载的下载器，它不会访问网络。
+// 同一请求被录制多次时会依次回放，用完之后重复回放最后一次的响应。
+func NewReplay(mid module.MID, path string, scoreCalculator module.CalculateScore) (module.Downloader, error) {
+	moduleBase, err := stub.NewModuleInternal(mid, scoreCalculator)
+	if err != nil {
+		return nil, err
+	}
+	exchanges, err := loadExchanges(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &replayDownloader{
+		ModuleInternal: moduleBase,
+		exchanges:      map[string][]*exchange{},
+		next:           map[string]int{},
+	}
+	for _, ex := range exchanges {
+		key := requestKey(ex.method, ex.url)
+		d.exchanges[key] = append(d.exchanges[key], ex)
+	}
+	return d, nil
+}
+
+func (d *replayDownloader) Download(req *module.Request) (*module.Response, error) {
+	return d.DownloadContext(context.Background(), req)
+}
+
+func (d *replayDownloader) DownloadContext(ctx context.Context, req *module.Request) (*module.Response, error) {
+	d.ModuleInternal.IncrHandlingNumber()
+	defer d.ModuleInternal.DecrHandlingNumber()
+
+	d.ModuleInternal.IncrCalledCount()
+	if req == nil {
+		return nil, genParameterError("nil request")
+	}
+	httpReq := req.HTTPReq()
+	if httpReq == nil {
+		return nil, genParameterError("nil HTTP request")
+	}
+	d.ModuleInternal.IncrAcceptedCount()
+	if ctx != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	ex := d.take(requestKey(httpReq.Method, httpReq.URL.String()))
+	if ex == nil {
+		return nil, genError(fmt.Sprintf("no recorded response (method: %s, URL: %s)",
+			httpReq.Method, httpReq.URL))
+	}
+	d.ModuleInternal.IncrCompletedCount()
+	return newStoredResponse(ctx, d.ID(), req, storedResponse{
+		finalURL:   ex.finalURL,
+		redirects:  ex.redirects,
+		status:     ex.status,
+		statusCode: ex.statusCode,
+		header:     ex.header,
+		body:       io.NopCloser(bytes.NewReader(ex.body)),
+		size:       int64(len(ex.body)),
+	}), nil
+}
+
+// take 用于获取给定的键下一次回放的请求和响应。
+func (d *replayDownloader) take(key string) *exchange {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	exchanges := d.exchanges[key]
+	if len(exchanges) == 0 {
+		return nil
+	}
+	i := d.next[key]
+	if i < len(exchanges)-1 {
+		d.next[key] = i + 1
+	}
+	return exchanges[i]
+}
+
+// loadExchanges 用于从录制文件中读取请求和响应。
+func loadExchanges(path string) ([]*exchange, error) {
+	format, _, err := recordFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, genError(fmt.Sprintf("cannot open record file: %s", err))
+	}
+	defer file.Close()
+	var exchanges []*exchange
+	switch format {
+	case RECORD_FORMAT_HAR:
+		exchanges, err = readHARExchanges(file)
+	case RECORD_FORMAT_WARC:
+		exchanges, err = readWARCExchanges(file)
+	}
+	if err != nil {
+		return nil, genError(fmt.Sprintf("cannot read record file %q: %s", path, err))
+	}
+	return exchanges, nil
+}
+
+// readHARExchanges 用于从 HAR 文件中读取请求和响应。
+func readHARExchanges(r io.Reader) ([]*exchange, error) {
+	content, err := har.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	exchanges := make([]*exchange, 0, len(content.Log.Entries))
+	for _, entry := range content.Log.Entries {
+		body, err := entry.Response.Content.Body()
+		if err != nil {
+			return nil, err
+		}
+		startTime, _ := time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+		ex := &exchange{
+			method:        entry.Request.Method,
+			url:           entry.Request.URL,
+			requestHeader: har.HTTPHeader(entry.Request.Headers),
+			startTime:     startTime,
+			finalURL:      entry.FinalURL,
+			redirects:     entry.Redirects,
+			proto:         entry.Response.HTTPVersion,
+			status:        strings.TrimSpace(fmt.Sprintf("%d %s", entry.Response.Status, entry.Response.StatusText)),
+			statusCode:    entry.Response.Status,
+			header:        har.HTTPHeader(entry.Response.Headers),
+			body:          body,
+		}
+		if ex.finalURL == "" {
+			ex.finalURL = ex.url
+		}
+		exchanges = append(exchanges, ex)
+	}
+	return exchanges, nil
+}
+
+// readWARCExchanges 用于从 WARC 文件中读取请求和响应。
+// response 记录通过 WARC-Concurrent-To 字段与 request 记录对应，
+// 没有对应的 request 记录时视为以 GET 方法请求其目标 URI。
+func readWARCExchanges(r io.Reader) ([]*exchange, error) {
+	reader, err := warc.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	requests := map[string]*http.Request{}
+	requestURLs := map[string]string{}
+	var exchanges []*exchange
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch record.Type() {
+		case warc.TYPE_REQUEST:
+			httpReq, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(record.Block)))
+			if err != nil {
+				return nil, err
+			}
+			requests[record.ID()] = httpReq
+			requestURLs[record.ID()] = record.Get(warc.FIELD_TARGET_URI)
+		case warc.TYPE_RESPONSE:
+			httpResp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(record.Block)), nil)
+			if err != nil {
+				return nil, err
+			}
+			body, err := io.ReadAll(httpResp.Body)
+			if err != nil {
+				return nil, err
+			}
+			date, _ := warc.ParseDate(record.Get(warc.FIELD_DATE))
+			ex := &exchange{
+				method:        http.MethodGet,
+				url:           record.Get(warc.FIELD_TARGET_URI),
+				requestHeader: http.Header{},
+				startTime:     date,
+				finalURL:      record.Get(warc.FIELD_TARGET_URI),
+				proto:         httpResp.Proto,
+				status:        httpResp.Status,
+				statusCode:    httpResp.StatusCode,
+				header:        httpResp.Header,
+				body:          body,
+			}
+			concurrentTo := record.Get(warc.FIELD_CONCURRENT_TO)
+			if httpReq, ok := requests[concurrentTo]; ok {
+				ex.method = httpReq.Method
+				ex.url = requestURLs[concurrentTo]
+				ex.requestHeader = httpReq.Header
+			}
+			exchanges = append(exchanges, ex)
+		}
+	}
+	return exchanges, nil
+}
+
+// replaySummaryStruct 代表回放下载器额外信息的摘要类型。
+type replaySummaryStruct struct {
+	// 录制下来的请求和响应的对数
+	Recorded int `json:"recorded"`
+}
+
+func (d *replayDownloader) Summary() module.SummaryStruct {
+	summary := d.ModuleInternal.Summary()
+	recorded := 0
+	for _, exchanges := range d.exchanges {
+		recorded += len(exchanges)
+	}
+	summary.Extra = replaySummaryStruct{Recorded: recorded}
+	return summary
+}
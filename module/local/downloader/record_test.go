@@ -0,0 +1,143 @@
+package downloader
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/warc"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	for _, name := range []string{"crawl.har", "crawl.warc", "crawl.warc.gz"} {
+		server, _ := genCacheServer()
+		path := filepath.Join(t.TempDir(), name)
+		d, _ := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+		recorder, err := NewRecorder(d, RecordConfig{Path: path})
+		if err != nil {
+			t.Fatalf("An error occurs when creating a recorder: %s (path: %s)", err, path)
+		}
+		urls := []string{
+			server.URL + "/moved",
+			server.URL + "/page?q=1",
+			server.URL + "/page?q=1",
+			server.URL + "/etag",
+		}
+		expected := map[string][]string{}
+		for _, url := range urls {
+			_, body := fetchBody(t, recorder, url)
+			expected[url] = append(expected[url], body)
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatalf("An error occurs when closing the recorder: %s (path: %s)", err, path)
+		}
+		server.Close()
+
+		replay, err := NewReplay(module.MID("D2|127.0.0.1:8080"), path, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a replay downloader: %s (path: %s)", err, path)
+		}
+		for _, url := range urls {
+			resp, body := fetchBody(t, replay, url)
+			if body != expected[url][0] {
+				t.Fatalf("Inconsistent replayed body for %s: expected: %q, actual: %q (path: %s)",
+					url, expected[url][0], body, path)
+			}
+			if len(expected[url]) > 1 {
+				expected[url] = expected[url][1:]
+			}
+			if resp.HTTPResp().StatusCode != http.StatusOK {
+				t.Fatalf("Inconsistent replayed status code: expected: %d, actual: %d (path: %s)",
+					http.StatusOK, resp.HTTPResp().StatusCode, path)
+			}
+		}
+		// 用完之后重复回放最后一次的响应
+		_, body := fetchBody(t, replay, urls[1])
+		if body != expected[urls[1]][0] {
+			t.Fatalf("Inconsistent replayed body after the last one: expected: %q, actual: %q",
+				expected[urls[1]][0], body)
+		}
+		resp, _ := fetchBody(t, replay, urls[0])
+		if resp.FinalURL() != server.URL+"/page" {
+			t.Fatalf("Inconsistent replayed final URL: expected: %s, actual: %s (path: %s)",
+				server.URL+"/page", resp.FinalURL(), path)
+		}
+		if resp.HTTPResp().Header.Get("Content-Type") == "" {
+			t.Fatalf("Missing replayed header: %v (path: %s)", resp.HTTPResp().Header, path)
+		}
+		httpReq, _ := http.NewRequest(http.MethodGet, server.URL+"/missing", nil)
+		if _, err := replay.Download(module.NewRequest(httpReq, 0)); err == nil {
+			t.Fatalf("No error when replaying an unrecorded request! (path: %s)", path)
+		}
+		if replay.CompletedCount() != uint64(len(urls)+2) {
+			t.Fatalf("Inconsistent completed count: expected: %d, actual: %d",
+				len(urls)+2, replay.CompletedCount())
+		}
+	}
+	if _, err := NewRecorder(nil, RecordConfig{Path: "crawl.har"}); err == nil {
+		t.Fatal("No error when creating a recorder with nil downloader!")
+	}
+	d, _ := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+	if _, err := NewRecorder(d, RecordConfig{Path: filepath.Join(t.TempDir(), "crawl.txt")}); err == nil {
+		t.Fatal("No error when creating a recorder with unknown format!")
+	}
+	if _, err := NewRecorder(d, RecordConfig{Path: filepath.Join(t.TempDir(), "crawl.har"), MaxBodySize: -1}); err == nil {
+		t.Fatal("No error when creating a recorder with negative max body size!")
+	}
+	if _, err := NewReplay(module.MID("D2|127.0.0.1:8080"), filepath.Join(t.TempDir(), "none.har"), nil); err == nil {
+		t.Fatal("No error when creating a replay downloader with missing file!")
+	}
+}
+
+func TestRecordTruncated(t *testing.T) {
+	server, _ := genCacheServer()
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "crawl.warc")
+	d, _ := New(module.MID("D1|127.0.0.1:8080"), &http.Client{}, nil)
+	recorder, err := NewRecorder(d, RecordConfig{Path: path, MaxBodySize: 4})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a recorder: %s", err)
+	}
+	// 读完但超过上限的响应体
+	if _, body := fetchBody(t, recorder, server.URL+"/page"); body != "page 1" {
+		t.Fatalf("The body read by the caller should not be truncated: %q", body)
+	}
+	// 没有读完就被关闭的响应体
+	httpReq, _ := http.NewRequest(http.MethodGet, server.URL+"/fresh", nil)
+	resp, err := recorder.Download(module.NewRequest(httpReq, 0))
+	if err != nil {
+		t.Fatalf("An error occurs when downloading content: %s", err)
+	}
+	resp.HTTPResp().Body.Read(make([]byte, 2))
+	resp.HTTPResp().Body.Close()
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the recorder: %s", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("An error occurs when opening the record file: %s", err)
+	}
+	defer file.Close()
+	reader, _ := warc.NewReader(file)
+	expected := []string{warc.TRUNCATED_LENGTH, warc.TRUNCATED_UNSPECIFIED}
+	var actual []string
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("An error occurs when reading WARC record: %s", err)
+		}
+		if record.Type() == warc.TYPE_RESPONSE {
+			actual = append(actual, record.Get(warc.FIELD_TRUNCATED))
+		}
+	}
+	if len(actual) != len(expected) || actual[0] != expected[0] || actual[1] != expected[1] {
+		t.Fatalf("Inconsistent truncation: expected: %v, actual: %v", expected, actual)
+	}
+}
@@ -37,10 +37,10 @@ func (p *myPipeline) Send(item module.Item) []error {
 	p.ModuleInternal.IncrAcceptedCount()
 	log.L().Sugar().Infof("Process item %+v... \n", item)
 	var currentItem = item
-	for _, processor := range p.itemProcessors {
+	for i, processor := range p.itemProcessors {
 		processedItem, err := processor(currentItem)
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, &module.ProcessorError{Index: i, Err: err})
 			if p.failFast {
 				break
 			}
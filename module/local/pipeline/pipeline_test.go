@@ -107,6 +107,13 @@ func TestSend(t *testing.T) {
 		t.Fatalf("Inconsistent error number after Send(): expected: %d, actual: %d",
 			expectedErrs, len(errs))
 	}
+	for i, err := range errs {
+		var pe *module.ProcessorError
+		if !errors.As(err, &pe) || pe.Index != i*3 {
+			t.Fatalf("Inconsistent processor index in error: expected: %d, actual: %#v",
+				i*3, err)
+		}
+	}
 	// 测试把快速失败标记设置为true的情况。
 	p.SetFailFast(true)
 	errs = p.Send(item)
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/warc"
+)
+
+// 保存待归档的请求和响应的条目的键，对应的值的类型为 *warc.Exchange
+const ITEM_KEY_WARC = "_warc"
+
+// NewWARCItem 用于以 HTTP 响应和读到的响应体生成待归档的条目。
+// 响应经过重定向时，归档的是最后一次请求及其响应。
+// 响应体因超过大小限制而被截断时，归档的响应会被标记为已截断。
+func NewWARCItem(httpResp *http.Response, body []byte) module.Item {
+	ex := newExchange(httpResp)
+	ex.Body = body
+	return module.Item{ITEM_KEY_WARC: ex}
+}
+
+// newExchange 用于以 HTTP 响应生成不含响应体的待归档的请求和响应。
+func newExchange(httpResp *http.Response) *warc.Exchange {
+	ex := &warc.Exchange{
+		Date:   time.Now(),
+		Proto:  httpResp.Proto,
+		Status: httpResp.Status,
+		Header: httpResp.Header,
+	}
+	if httpReq := httpResp.Request; httpReq != nil {
+		ex.Method = httpReq.Method
+		ex.RequestHeader = httpReq.Header
+		if httpReq.URL != nil {
+			ex.URL = httpReq.URL.String()
+			ex.FinalURL = ex.URL
+		}
+	}
+	if trace := module.TraceOf(httpResp); trace != nil {
+		ex.Date = trace.StartTime
+		if trace.Truncated() {
+			ex.Truncated = warc.TRUNCATED_LENGTH
+		}
+	}
+	return ex
+}
+
+// 待归档的响应体在内存中保存的最大字节数，超出的部分会被截断
+const maxWARCBodySize = 16 << 20
+
+// ParseForWARC 是会读取整个响应体并生成待归档的条目的响应解析函数。
+// 把它加入分析器的响应解析函数列表，并把 NewWARCProcessor 生成的条目处理器
+// 加入条目处理管道，即可把下载到的所有响应写入 WARC 文件。
+// 响应体保存在条目中，因此条目在到达该条目处理器之前被丢弃时不会遗留任何文件。
+// 响应体最多保存 16 MiB，超出的部分会被截断，归档的响应也会被标记为已截断。
+func ParseForWARC(httpResp *http.Response, respDepth uint32) ([]module.Data, []error) {
+	if httpResp == nil {
+		return nil, []error{genParameterError("nil HTTP response")}
+	}
+	ex := newExchange(httpResp)
+	if httpResp.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxWARCBodySize+1))
+		if err != nil {
+			return nil, []error{genError(fmt.Sprintf("cannot read the response body: %s", err))}
+		}
+		if len(body) > maxWARCBodySize {
+			body = body[:maxWARCBodySize]
+			ex.Truncated = warc.TRUNCATED_LENGTH
+		}
+		ex.Body = body
+	}
+	return []module.Data{module.Item{ITEM_KEY_WARC: ex}}, nil
+}
+
+// NewWARCProcessor 用于生成把条目中待归档的请求和响应写入 WARC 文件的条目处理器。
+// 不含待归档内容的条目会被原样返回，写入之后的条目则不再包含待归档内容，
+// 以免后续的条目处理器重复处理响应体。
+func NewWARCProcessor(writer *warc.FileWriter) (module.ProcessItem, error) {
+	if writer == nil {
+		return nil, genParameterError("nil WARC file writer")
+	}
+	return func(item module.Item) (module.Item, error) {
+		v, ok := item[ITEM_KEY_WARC]
+		if !ok {
+			return item, nil
+		}
+		ex, ok := v.(*warc.Exchange)
+		if !ok || ex == nil {
+			return nil, genError(fmt.Sprintf("incorrect WARC exchange type: %T", v))
+		}
+		records, err := ex.Records()
+		if err == nil {
+			err = writer.WriteRecords(records...)
+		}
+		if err != nil {
+			return nil, genError(fmt.Sprintf("cannot write WARC records: %s (URL: %s)", err, ex.URL))
+		}
+		result := make(module.Item, len(item))
+		for k, v := range item {
+			if k != ITEM_KEY_WARC {
+				result[k] = v
+			}
+		}
+		return result, nil
+	}, nil
+}
@@ -0,0 +1,181 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/warc"
+)
+
+func TestWARCProcessor(t *testing.T) {
+	if _, err := NewWARCProcessor(nil); err == nil {
+		t.Fatal("No error when creating a WARC processor with nil writer!")
+	}
+	writer, err := warc.NewFileWriter(warc.FileConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a WARC file writer: %s", err)
+	}
+	processor, err := NewWARCProcessor(writer)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a WARC processor: %s", err)
+	}
+	var rest []module.Item
+	p, err := New(module.MID("P1|127.0.0.1:8080"), []module.ProcessItem{
+		processor,
+		func(item module.Item) (module.Item, error) {
+			rest = append(rest, item)
+			return item, nil
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	httpReq, _ := http.NewRequest(http.MethodGet, "http://example.com/page", nil)
+	trace := &module.DownloadTrace{StartTime: time.Now()}
+	httpReq = httpReq.WithContext(module.WithTrace(context.Background(), trace))
+	body := "<html>archived</html>"
+	httpResp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Request:    httpReq,
+	}
+	resp := module.NewResponse(httpResp, 0)
+	resp.SetTrace(trace)
+	resp.SetTruncated(true)
+	dataList, errs := ParseForWARC(httpResp, 0)
+	if len(errs) != 0 || len(dataList) != 1 {
+		t.Fatalf("Inconsistent parsed result: data: %v, errors: %v", dataList, errs)
+	}
+	if ex := dataList[0].(module.Item)[ITEM_KEY_WARC].(*warc.Exchange); ex.BodyFile != "" || string(ex.Body) != body {
+		t.Fatalf("The response body should be kept in memory: %q (file: %q)", ex.Body, ex.BodyFile)
+	}
+	for _, item := range []module.Item{dataList[0].(module.Item), {"name": "other"}} {
+		if errs := p.Send(item); len(errs) != 0 {
+			t.Fatalf("An error occurs when sending item: %v", errs)
+		}
+	}
+	if len(rest) != 2 {
+		t.Fatalf("Inconsistent item number: expected: %d, actual: %d", 2, len(rest))
+	}
+	if _, ok := rest[0][ITEM_KEY_WARC]; ok {
+		t.Fatal("The archived exchange should be removed from the item!")
+	}
+	if rest[1]["name"] != "other" {
+		t.Fatalf("The item without exchange should be kept: %v", rest[1])
+	}
+	if errs := p.Send(module.Item{ITEM_KEY_WARC: "illegal"}); len(errs) == 0 {
+		t.Fatal("No error when sending an item with illegal exchange!")
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the WARC file writer: %s", err)
+	}
+	files := writer.Files()
+	if len(files) != 1 {
+		t.Fatalf("Inconsistent WARC file number: expected: %d, actual: %d", 1, len(files))
+	}
+	file, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("An error occurs when opening WARC file: %s", err)
+	}
+	defer file.Close()
+	reader, _ := warc.NewReader(file)
+	types := []string{}
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("An error occurs when reading WARC record: %s", err)
+		}
+		types = append(types, record.Type())
+		if record.Type() != warc.TYPE_RESPONSE {
+			continue
+		}
+		if record.Get(warc.FIELD_TARGET_URI) != httpReq.URL.String() {
+			t.Fatalf("Inconsistent target URI: expected: %s, actual: %s",
+				httpReq.URL, record.Get(warc.FIELD_TARGET_URI))
+		}
+		if record.Get(warc.FIELD_PAYLOAD_DIGEST) != warc.Digest([]byte(body)) {
+			t.Fatalf("Inconsistent payload digest: %s", record.Get(warc.FIELD_PAYLOAD_DIGEST))
+		}
+		if record.Get(warc.FIELD_DATE) == "" {
+			t.Fatal("Missing WARC date!")
+		}
+		if record.Get(warc.FIELD_TRUNCATED) != warc.TRUNCATED_LENGTH {
+			t.Fatalf("Inconsistent truncation: %q", record.Get(warc.FIELD_TRUNCATED))
+		}
+		if !bytes.HasSuffix(record.Block, []byte("\r\n\r\n"+body)) {
+			t.Fatalf("Inconsistent response block: %q", record.Block)
+		}
+	}
+	expectedTypes := []string{warc.TYPE_WARCINFO, warc.TYPE_REQUEST, warc.TYPE_RESPONSE}
+	if len(types) != len(expectedTypes) {
+		t.Fatalf("Inconsistent record types: expected: %v, actual: %v", expectedTypes, types)
+	}
+	for i, recordType := range types {
+		if recordType != expectedTypes[i] {
+			t.Fatalf("Inconsistent record types: expected: %v, actual: %v", expectedTypes, types)
+		}
+	}
+}
+
+func TestParseForWARCLargeBody(t *testing.T) {
+	httpReq, _ := http.NewRequest(http.MethodGet, "http://example.com/large", nil)
+	httpResp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(make([]byte, maxWARCBodySize+10))),
+		Request:    httpReq,
+	}
+	dataList, errs := ParseForWARC(httpResp, 0)
+	if len(errs) != 0 || len(dataList) != 1 {
+		t.Fatalf("Inconsistent parsed result: data: %v, errors: %v", dataList, errs)
+	}
+	// 超过上限的响应体会被截断，而不是写入临时文件
+	ex := dataList[0].(module.Item)[ITEM_KEY_WARC].(*warc.Exchange)
+	if len(ex.Body) != maxWARCBodySize || ex.BodyFile != "" {
+		t.Fatalf("Inconsistent body size: expected: %d, actual: %d (file: %q)",
+			maxWARCBodySize, len(ex.Body), ex.BodyFile)
+	}
+	if ex.Truncated != warc.TRUNCATED_LENGTH {
+		t.Fatalf("Inconsistent truncation: %q", ex.Truncated)
+	}
+}
+
+func TestNewWARCItemRedirected(t *testing.T) {
+	httpReq, _ := http.NewRequest(http.MethodGet, "http://example.com/new", nil)
+	httpReq.Header.Set("Referer", "http://example.com/")
+	trace := &module.DownloadTrace{
+		StartTime: time.Now(),
+		Redirects: []string{"http://example.com/old"},
+	}
+	httpReq = httpReq.WithContext(module.WithTrace(context.Background(), trace))
+	httpResp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{},
+		Request:    httpReq,
+	}
+	// 请求记录的 URL、方法与请求头应都来自最后一次请求
+	ex := NewWARCItem(httpResp, nil)[ITEM_KEY_WARC].(*warc.Exchange)
+	if ex.URL != httpReq.URL.String() || ex.FinalURL != ex.URL {
+		t.Fatalf("Inconsistent URL: expected: %s, actual: %s (final: %s)",
+			httpReq.URL, ex.URL, ex.FinalURL)
+	}
+	if ex.RequestHeader.Get("Referer") != "http://example.com/" {
+		t.Fatalf("Inconsistent request header: %v", ex.RequestHeader)
+	}
+}
@@ -1,6 +1,9 @@
 package module
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // Counts 代表用于汇集组件内部计数的类型。
 type Counts struct {
@@ -59,6 +62,24 @@ type Downloader interface {
 	Download(req *Request) (*Response, error)
 }
 
+// 可在给定的上下文中下载请求的下载器接口
+// 下载器可以选择实现该接口，以便调度器在停止或超时时中止下载
+type ContextDownloader interface {
+	Downloader
+	// 在给定的上下文中下载请求
+	// 上下文被取消或超时时会中止下载，包括之后对响应体的读取
+	DownloadContext(ctx context.Context, req *Request) (*Response, error)
+}
+
+// DownloadContext 用于在给定的上下文中下载请求。
+// 下载器未实现 ContextDownloader 接口时会调用其 Download 方法，此时上下文不起作用。
+func DownloadContext(ctx context.Context, d Downloader, req *Request) (*Response, error) {
+	if cd, ok := d.(ContextDownloader); ok {
+		return cd.DownloadContext(ctx, req)
+	}
+	return d.Download(req)
+}
+
 // 用于解析 HTTP 响应的函数类型
 type ParseResponse func(httpResp *http.Response, respDepth uint32) ([]Data, []error)
 
@@ -73,6 +94,17 @@ type Analyzer interface {
 	Analyze(resp *Response) ([]Data, []error)
 }
 
+// 可把较大的响应体暂存到临时文件的分析器接口
+// 分析器可以选择实现该接口，调度器会在注册时按参数设置阈值
+type SpillingAnalyzer interface {
+	Analyzer
+	// 返回把响应体暂存到临时文件的阈值
+	// 响应体超过该字节数时不再全部保存在内存中，为 0 时总是保存在内存中
+	SpillThreshold() int64
+	// 设置把响应体暂存到临时文件的阈值
+	SetSpillThreshold(threshold int64)
+}
+
 // 用于处理条目的函数类型
 type ProcessItem func(item Item) (result Item, err error)
 
@@ -90,11 +90,8 @@ func (r *myRegistrar) Get(moduleType Type) (Module, error) {
 	var selectedModule Module
 	for _, module := range modules {
 		SetScore(module)
-		if err != nil {
-			return nil, err
-		}
 		score := module.Score()
-		if minScore == 0 || score < minScore {
+		if selectedModule == nil || score < minScore {
 			selectedModule = module
 			minScore = score
 		}
@@ -228,6 +228,41 @@ func TestModuleGet(t *testing.T) {
 	}
 }
 
+func TestModuleGetByScore(t *testing.T) {
+	registrar := NewRegistrar()
+	mt := TYPE_DOWNLOADER
+	idle := &fakeDownloader{
+		fakeModule: fakeModule{
+			mid:             MID(fmt.Sprintf("D%d", DefaultSNGen.Get())),
+			scoreCalculator: CalculateScoreSimple,
+		},
+	}
+	busy := &fakeDownloader{
+		fakeModule: fakeModule{
+			mid:             MID(fmt.Sprintf("D%d", DefaultSNGen.Get())),
+			count:           100,
+			scoreCalculator: CalculateScoreSimple,
+		},
+	}
+	for _, m := range []Module{busy, idle} {
+		if _, err := registrar.Register(m); err != nil {
+			t.Fatalf("An error occurs when registering module instance: %s (mid: %s)",
+				err, m.ID())
+		}
+	}
+	// 无论遍历顺序如何，都应该选中评分最低的组件实例。
+	for i := 0; i < 100; i++ {
+		m, err := registrar.Get(mt)
+		if err != nil {
+			t.Fatalf("An error occurs when getting module instance: %s", err)
+		}
+		if m.ID() != idle.ID() {
+			t.Fatalf("Inconsistent MID: expected: %s, actual: %s",
+				idle.ID(), m.ID())
+		}
+	}
+}
+
 func TestModuleAllInParallel(t *testing.T) {
 	baseSize := 1000
 	basePort := 8000
@@ -23,6 +23,9 @@ type ModuleInternal interface {
 	DecrHandlingNumber()
 	// 清空所有计数
 	Clear()
+	// 按给定的计数恢复调用、接受和成功计数
+	// 实时处理数不会被恢复
+	RestoreCounts(counts module.Counts)
 }
 
 type myModule struct {
@@ -127,6 +130,12 @@ func (m *myModule) Clear() {
 	atomic.StoreUint64(&m.handlingNumber, 0)
 }
 
+func (m *myModule) RestoreCounts(counts module.Counts) {
+	atomic.StoreUint64(&m.calledCount, counts.CalledCount)
+	atomic.StoreUint64(&m.acceptedCount, counts.AcceptedCount)
+	atomic.StoreUint64(&m.completedCount, counts.CompletedCount)
+}
+
 func NewModuleInternal(mid module.MID, scoreCalculator module.CalculateScore) (ModuleInternal, error) {
 	parts, err := module.SplitMID(mid)
 	if err != nil {
@@ -258,3 +258,21 @@ func TestAllInParallel(t *testing.T) {
 		}
 	})
 }
+
+func TestRestoreCounts(t *testing.T) {
+	mi, _ := NewModuleInternal(mid, nil)
+	mi.IncrHandlingNumber()
+	counts := module.Counts{
+		CalledCount:    3,
+		AcceptedCount:  2,
+		CompletedCount: 1,
+		HandlingNumber: 10,
+	}
+	mi.RestoreCounts(counts)
+	expected := counts
+	expected.HandlingNumber = 1
+	if actual := mi.Counts(); actual != expected {
+		t.Fatalf("Inconsistent counts for internal module: expected: %#v, actual: %#v",
+			expected, actual)
+	}
+}
@@ -0,0 +1,114 @@
+package module
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DownloadTrace 代表一次下载的过程信息，由下载器填充。
+type DownloadTrace struct {
+	// 进行下载的下载器的 ID
+	MID MID
+	// 该次下载是对应请求的第几次下载
+	Attempt uint32
+	// 开始下载的时间
+	StartTime time.Time
+	// 从开始下载到收到最终响应首字节的时长
+	TTFB time.Duration
+	// 依次经过的被重定向的 URL，不包含最终的 URL
+	Redirects []string
+	// 已读取的响应体字节数
+	bytesRead int64
+	// 响应体读取完毕或被关闭的时间，以纳秒表示
+	endTime int64
+	// 响应体是否因超过大小限制而被截断，为 1 时代表已截断
+	truncated uint32
+}
+
+// BytesRead 用于获取已读取的响应体字节数。
+func (t *DownloadTrace) BytesRead() int64 {
+	return atomic.LoadInt64(&t.bytesRead)
+}
+
+// EndTime 用于获取响应体读取完毕或被关闭的时间。
+// 尚未结束时结果值为零值。
+func (t *DownloadTrace) EndTime() time.Time {
+	end := atomic.LoadInt64(&t.endTime)
+	if end == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, end)
+}
+
+// Duration 用于获取整个下载过程的时长。
+// 尚未结束时结果值为 0。
+func (t *DownloadTrace) Duration() time.Duration {
+	end := t.EndTime()
+	if end.IsZero() {
+		return 0
+	}
+	return end.Sub(t.StartTime)
+}
+
+// Truncated 用于判断响应体是否因超过大小限制而被截断。
+// 响应解析函数无法获得响应本身，可以借此判断读到的响应体是否完整。
+func (t *DownloadTrace) Truncated() bool {
+	return atomic.LoadUint32(&t.truncated) == 1
+}
+
+// finish 用于记录下载结束的时间，只有第一次调用有效。
+func (t *DownloadTrace) finish() {
+	atomic.CompareAndSwapInt64(&t.endTime, 0, time.Now().UnixNano())
+}
+
+// traceKey 代表在 context 中保存下载过程信息的键。
+type traceKey struct{}
+
+// WithTrace 用于把下载过程信息放入 context 中。
+func WithTrace(ctx context.Context, trace *DownloadTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFromContext 用于从 context 中取出下载过程信息。
+func TraceFromContext(ctx context.Context) *DownloadTrace {
+	trace, _ := ctx.Value(traceKey{}).(*DownloadTrace)
+	return trace
+}
+
+// TraceOf 用于获取 HTTP 响应的下载过程信息。
+// 响应解析函数可以借此获得下载用时、重定向链等信息。
+// 响应不是由本项目的下载器下载时结果值为 nil。
+func TraceOf(httpResp *http.Response) *DownloadTrace {
+	if httpResp == nil || httpResp.Request == nil {
+		return nil
+	}
+	return TraceFromContext(httpResp.Request.Context())
+}
+
+// NewTracedBody 用于包装响应体，以便统计读取的字节数和结束时间。
+func NewTracedBody(body io.ReadCloser, trace *DownloadTrace) io.ReadCloser {
+	return &tracedBody{body, trace}
+}
+
+// tracedBody 代表会记录下载过程信息的响应体。
+type tracedBody struct {
+	io.ReadCloser
+	trace *DownloadTrace
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.trace.bytesRead, int64(n))
+	if err == io.EOF {
+		b.trace.finish()
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	b.trace.finish()
+	return b.ReadCloser.Close()
+}
@@ -1,6 +1,17 @@
 package scheduler
 
-import "github.com/dokidokikoi/webcrawler/module"
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/dedup"
+	"github.com/dokidokikoi/webcrawler/toolkit/domain"
+	"github.com/dokidokikoi/webcrawler/toolkit/frontier"
+	"github.com/dokidokikoi/webcrawler/toolkit/urlfilter"
+)
 
 // 容器的接口类型
 type Args interface {
@@ -11,18 +22,59 @@ type Args interface {
 
 // 请求相关参数
 type RequestArgs struct {
-	// 可以接受的 URL 的主域名列表
+	// 可以接受的 URL 的主域名列表，仅在主域名范围模式下使用
 	// URL 主域名不在列表中的请求都会被忽略
 	AcceptedDomains []string `json:"accepted_primary_domains"`
+	// 爬取范围相关参数
+	Scope ScopeArgs `json:"scope"`
 	// 需要爬取的最大深度
 	// 实际深度大于此值的请求都会被忽略
 	MaxDepth uint32 `json:"max_depth"`
+	// 针对单个主机的访问限制
+	Politeness PolitenessArgs `json:"politeness"`
+	// robots.txt 相关参数
+	Robots RobotsArgs `json:"robots"`
+	// 待爬取队列相关参数
+	Frontier FrontierArgs `json:"frontier"`
+	// URL 规范化相关参数
+	Canonical CanonicalArgs `json:"canonical"`
+	// 下载失败时的重试相关参数
+	Retry RetryArgs `json:"retry"`
+	// 每次下载的超时时间，为 0 时不限制
+	// 只计算下载以及读取响应体的时间，不包括响应在缓冲池中等待分析的时间
+	// 请求元数据中的 module.META_KEY_TIMEOUT 优先
+	Timeout time.Duration `json:"timeout"`
+	// 响应体大小相关参数
+	BodyLimit BodyLimitArgs `json:"body_limit"`
+	// 按顺序匹配的 URL 允许/拒绝规则，首个匹配的规则生效，没有规则匹配时允许
+	URLRules []urlfilter.Rule `json:"url_rules"`
 }
 
 func (args *RequestArgs) Check() error {
 	if args.AcceptedDomains == nil {
 		return genError("nil accepted primary domain list")
 	}
+	if err := args.Scope.Check(); err != nil {
+		return err
+	}
+	if err := args.Politeness.Check(); err != nil {
+		return err
+	}
+	if err := args.Frontier.Check(); err != nil {
+		return err
+	}
+	if err := args.Retry.Check(); err != nil {
+		return err
+	}
+	if args.Timeout < 0 {
+		return genError("negative download timeout")
+	}
+	if err := args.BodyLimit.Check(); err != nil {
+		return err
+	}
+	if _, err := urlfilter.New(args.URLRules); err != nil {
+		return genError(err.Error())
+	}
 	return nil
 }
 
@@ -34,6 +86,38 @@ func (args *RequestArgs) Same(another *RequestArgs) bool {
 	if another.MaxDepth != args.MaxDepth {
 		return false
 	}
+	if !another.Scope.Same(&args.Scope) {
+		return false
+	}
+	if another.Politeness != args.Politeness {
+		return false
+	}
+	if another.Robots != args.Robots {
+		return false
+	}
+	if !another.Frontier.Same(&args.Frontier) {
+		return false
+	}
+	if !another.Canonical.Same(&args.Canonical) {
+		return false
+	}
+	if !another.Retry.Same(&args.Retry) {
+		return false
+	}
+	if another.Timeout != args.Timeout {
+		return false
+	}
+	if !another.BodyLimit.Same(&args.BodyLimit) {
+		return false
+	}
+	if len(another.URLRules) != len(args.URLRules) {
+		return false
+	}
+	for i, rule := range another.URLRules {
+		if !rule.Same(&args.URLRules[i]) {
+			return false
+		}
+	}
 	anotherDomains := another.AcceptedDomains
 	anotherDomainsLen := len(anotherDomains)
 	if anotherDomainsLen != len(args.AcceptedDomains) {
@@ -49,9 +133,250 @@ func (args *RequestArgs) Same(another *RequestArgs) bool {
 	return true
 }
 
+// 爬取范围相关参数
+type ScopeArgs struct {
+	// 范围模式，可选值见 SCOPE_* 常量，为空时使用主域名模式
+	Mode string `json:"mode"`
+	// 可以接受的主机名列表，在主域名以外的范围模式下使用
+	Hosts []string `json:"hosts"`
+}
+
+func (args *ScopeArgs) Check() error {
+	switch args.mode() {
+	case SCOPE_PRIMARY_DOMAIN, SCOPE_SUBDOMAINS, SCOPE_EXACT_HOST:
+	case SCOPE_HOST_LIST:
+		if len(args.Hosts) == 0 {
+			return genError("empty host list in host list scope mode")
+		}
+	default:
+		return genError(fmt.Sprintf("unknown scope mode %q", args.Mode))
+	}
+	for _, host := range args.Hosts {
+		if _, err := domain.Hostname(host); err != nil {
+			return genError(fmt.Sprintf("illegal scope host %q: %s", host, err))
+		}
+	}
+	return nil
+}
+
+// Same 用于判断两个爬取范围相关的参数容器是否相同。
+func (args *ScopeArgs) Same(another *ScopeArgs) bool {
+	if args.Mode != another.Mode || len(args.Hosts) != len(another.Hosts) {
+		return false
+	}
+	for i, host := range args.Hosts {
+		if host != another.Hosts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 针对单个主机的访问限制参数
+// 各项均为 0 时不做任何限制
+type PolitenessArgs struct {
+	// 是否按主域名而非主机名归并限制
+	ByPrimaryDomain bool `json:"by_primary_domain"`
+	// 每秒最多发往同一主机的请求数
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	// 两次请求同一主机之间的最小间隔
+	MinDelay time.Duration `json:"min_delay"`
+	// 同一主机上同时进行的最大请求数，请求在其响应体被关闭之前都算作正在进行
+	MaxInFlight uint32 `json:"max_in_flight"`
+}
+
+func (args *PolitenessArgs) Check() error {
+	if args.RequestsPerSecond < 0 {
+		return genError("negative requests per second")
+	}
+	if args.MinDelay < 0 {
+		return genError("negative min delay")
+	}
+	return nil
+}
+
+// robots.txt 相关参数
+type RobotsArgs struct {
+	// 是否遵守 robots.txt
+	// 启用后会通过已注册的下载器获取并缓存各站点的 robots.txt
+	Enabled bool `json:"enabled"`
+	// 用于匹配规则组的用户代理，也会作为获取 robots.txt 时的 User-Agent
+	UserAgent string `json:"user_agent"`
+}
+
+// 待爬取队列相关参数
+type FrontierArgs struct {
+	// 内置的优先级策略，可选值见 frontier 包中的 POLICY_* 常量
+	// 为空时先进先出
+	Policy string `json:"policy"`
+	// 是否在请求所属的主机之间轮流下载
+	HostFair bool `json:"host_fair"`
+	// 自定义的优先级函数，设置后会忽略 Policy
+	// 该函数不会被写入检查点，恢复时需通过 ResumeFrom 的参数重新设置
+	Priority frontier.PriorityFunc `json:"-"`
+}
+
+func (args *FrontierArgs) Check() error {
+	if args.Priority != nil {
+		return nil
+	}
+	if _, err := frontier.GetPolicy(args.Policy); err != nil {
+		return genError(err.Error())
+	}
+	return nil
+}
+
+// Same 用于判断两个待爬取队列相关的参数容器是否相同。
+// 自定义的优先级函数只比较是否设置。
+func (args *FrontierArgs) Same(another *FrontierArgs) bool {
+	return args.Policy == another.Policy &&
+		args.HostFair == another.HostFair &&
+		(args.Priority == nil) == (another.Priority == nil)
+}
+
+// 获取生效的优先级策略的名称
+func (args *FrontierArgs) policyName() string {
+	if args.Priority != nil {
+		return "custom"
+	}
+	if args.Policy == "" {
+		return frontier.POLICY_FIFO
+	}
+	return args.Policy
+}
+
+// 下载失败时的重试相关参数
+// 超时、连接被重置等暂时性的错误以及特定状态码的响应会被重试
+type RetryArgs struct {
+	// 每个请求最多的下载次数，不大于 1 时不重试
+	MaxAttempts uint32 `json:"max_attempts"`
+	// 首次重试前等待的时间，之后每次加倍，为 0 时使用默认值
+	BaseDelay time.Duration `json:"base_delay"`
+	// 重试前等待的最长时间，为 0 时使用默认值
+	// 响应中的 Retry-After 不受此限制
+	MaxDelay time.Duration `json:"max_delay"`
+	// 随机减少等待时间的最大比例，取值范围为 [0, 1]
+	Jitter float64 `json:"jitter"`
+	// 需要重试的响应状态码，为 nil 时使用 429、502、503 与 504
+	StatusCodes []int `json:"status_codes"`
+}
+
+func (args *RetryArgs) Check() error {
+	if args.BaseDelay < 0 {
+		return genError("negative retry base delay")
+	}
+	if args.MaxDelay < 0 {
+		return genError("negative retry max delay")
+	}
+	if args.Jitter < 0 || args.Jitter > 1 {
+		return genError(fmt.Sprintf("illegal retry jitter: %v", args.Jitter))
+	}
+	return nil
+}
+
+// Same 用于判断两个重试相关的参数容器是否相同。
+func (args *RetryArgs) Same(another *RetryArgs) bool {
+	if args.MaxAttempts != another.MaxAttempts ||
+		args.BaseDelay != another.BaseDelay ||
+		args.MaxDelay != another.MaxDelay ||
+		args.Jitter != another.Jitter ||
+		(args.StatusCodes == nil) != (another.StatusCodes == nil) ||
+		len(args.StatusCodes) != len(another.StatusCodes) {
+		return false
+	}
+	for i, code := range args.StatusCodes {
+		if code != another.StatusCodes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 响应体大小相关参数
+// 响应体超过限制时会被截断并标记，或者整个响应被丢弃
+type BodyLimitArgs struct {
+	// 响应体的最大字节数，为 0 时不限制
+	MaxSize int64 `json:"max_size"`
+	// 按内容类型设置的最大字节数，优先于 MaxSize
+	// 键为不含参数的 MIME 类型，如 text/html，也可以是 image/* 这样的通配形式
+	MaxSizeByType map[string]int64 `json:"max_size_by_type"`
+	// 超过限制时是否丢弃整个响应，否则截断响应体
+	Discard bool `json:"discard"`
+	// 分析时把响应体暂存到临时文件的阈值，仅对实现了 module.SpillingAnalyzer 的分析器生效
+	// 为 0 时保持分析器自身的设置
+	SpillThreshold int64 `json:"spill_threshold"`
+}
+
+func (args *BodyLimitArgs) Check() error {
+	if args.MaxSize < 0 {
+		return genError("negative max body size")
+	}
+	if args.SpillThreshold < 0 {
+		return genError("negative spill threshold")
+	}
+	for contentType, size := range args.MaxSizeByType {
+		if size < 0 {
+			return genError(fmt.Sprintf("negative max body size for %s", contentType))
+		}
+		parts := strings.Split(contentType, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[0] == "*" || parts[1] == "" ||
+			strings.ContainsAny(contentType, "; ") {
+			return genError(fmt.Sprintf("illegal content type for max body size: %q", contentType))
+		}
+	}
+	return nil
+}
+
+// Same 用于判断两个响应体大小相关的参数容器是否相同。
+func (args *BodyLimitArgs) Same(another *BodyLimitArgs) bool {
+	if args.MaxSize != another.MaxSize ||
+		args.Discard != another.Discard ||
+		args.SpillThreshold != another.SpillThreshold ||
+		(args.MaxSizeByType == nil) != (another.MaxSizeByType == nil) ||
+		len(args.MaxSizeByType) != len(another.MaxSizeByType) {
+		return false
+	}
+	for contentType, size := range args.MaxSizeByType {
+		if anotherSize, ok := another.MaxSizeByType[contentType]; !ok || anotherSize != size {
+			return false
+		}
+	}
+	return true
+}
+
+// URL 规范化相关参数
+// 启用后，会在去重前把 URL 的协议和主机名转为小写、去掉默认端口并解析路径中的 . 和 ..
+type CanonicalArgs struct {
+	// 是否在去重前规范化 URL
+	Enabled bool `json:"enabled"`
+	// 是否保留片段（# 之后的部分）
+	KeepFragment bool `json:"keep_fragment"`
+	// 是否保留查询参数的原有顺序，否则按参数名排序
+	KeepQueryOrder bool `json:"keep_query_order"`
+	// 需要去掉的查询参数名，以 * 结尾时按前缀匹配，如 utm_*
+	StripParams []string `json:"strip_params"`
+}
+
+// Same 用于判断两个 URL 规范化相关的参数容器是否相同。
+func (args *CanonicalArgs) Same(another *CanonicalArgs) bool {
+	if args.Enabled != another.Enabled ||
+		args.KeepFragment != another.KeepFragment ||
+		args.KeepQueryOrder != another.KeepQueryOrder ||
+		len(args.StripParams) != len(another.StripParams) {
+		return false
+	}
+	for i, p := range args.StripParams {
+		if p != another.StripParams[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // 数据相关参数
 type DataArgs struct {
 	// 请求缓冲器的容量
+	// 待爬取队列的容量为它与请求缓冲器的最大数量之积
 	ReqBufferCap uint32 `json:"req_buffer_cap"`
 	// 请求缓冲器的最大数量
 	ReqMaxBufferNumber uint32 `json:"req_max_buffer_number"`
@@ -67,6 +392,26 @@ type DataArgs struct {
 	ErrorBufferCap uint32 `json:"error_buffer_cap"`
 	// 错误缓冲器的最大数量
 	ErrorMaxBufferNumber uint32 `json:"error_max_buffer_number"`
+	// 下载阶段的工作协程数量，为 0 时只启用一个
+	DownloaderWorkerNumber uint32 `json:"downloader_worker_number"`
+	// 分析阶段的工作协程数量，为 0 时只启用一个
+	AnalyzerWorkerNumber uint32 `json:"analyzer_worker_number"`
+	// 条目处理阶段的工作协程数量，为 0 时只启用一个
+	PipelineWorkerNumber uint32 `json:"pipeline_worker_number"`
+	// 检查点目录，为空时不生成检查点
+	CheckpointDir string `json:"checkpoint_dir"`
+	// 定期生成检查点的间隔，为 0 时只在调度器停止时生成
+	CheckpointInterval time.Duration `json:"checkpoint_interval"`
+	// URL 去重存储的类型，可选 memory、bloom 与 disk，为空时使用 memory
+	DedupType string `json:"dedup_type"`
+	// Bloom 过滤器的初始容量，为 0 时使用默认值
+	DedupCapacity uint64 `json:"dedup_capacity"`
+	// Bloom 过滤器的误判率上限，为 0 时使用默认值
+	DedupFalsePositiveRate float64 `json:"dedup_false_positive_rate"`
+	// 磁盘去重存储所在的目录，为空时使用系统的临时目录
+	DedupDir string `json:"dedup_dir"`
+	// 死信文件的路径，最终失败的请求与处理失败的条目会被追加到其中，为空时不记录
+	DeadLetterFile string `json:"dead_letter_file"`
 }
 
 func (args *DataArgs) Check() error {
@@ -76,6 +421,11 @@ func (args *DataArgs) Check() error {
 	if args.ReqMaxBufferNumber == 0 {
 		return genError("zero max request buffer number")
 	}
+	// 待爬取队列的容量为两者之积，不能超出 uint32 的范围
+	if uint64(args.ReqBufferCap)*uint64(args.ReqMaxBufferNumber) > math.MaxUint32 {
+		return genError(fmt.Sprintf("too large request buffer capacity: %d * %d",
+			args.ReqBufferCap, args.ReqMaxBufferNumber))
+	}
 	if args.RespBufferCap == 0 {
 		return genError("zero response buffer capacity")
 	}
@@ -94,6 +444,18 @@ func (args *DataArgs) Check() error {
 	if args.ErrorMaxBufferNumber == 0 {
 		return genError("zero max error buffer number")
 	}
+	if args.CheckpointInterval < 0 {
+		return genError("negative checkpoint interval")
+	}
+	switch args.DedupType {
+	case "", dedup.TYPE_MEMORY, dedup.TYPE_BLOOM, dedup.TYPE_DISK:
+	default:
+		return genError(fmt.Sprintf("unknown dedup type %q", args.DedupType))
+	}
+	if args.DedupFalsePositiveRate < 0 || args.DedupFalsePositiveRate >= 1 {
+		return genError(fmt.Sprintf("illegal dedup false positive rate: %v",
+			args.DedupFalsePositiveRate))
+	}
 	return nil
 }
 
@@ -92,6 +92,9 @@ func TestArgsData(t *testing.T) {
 		dataArgsList = append(
 			dataArgsList, genDataArgsByDetail(values))
 	}
+	// 待爬取队列的容量超出范围
+	dataArgsList = append(dataArgsList,
+		genDataArgsByDetail([8]uint32{1 << 16, 1 << 16, 2, 2, 2, 2, 2, 2}))
 	for _, dataArgs := range dataArgsList {
 		if err := dataArgs.Check(); err == nil {
 			t.Fatalf("No error when check data arguments! (dataArgs: %#v)",
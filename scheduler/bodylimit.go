@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// bodyLimiter 代表响应体大小的限制器。
+type bodyLimiter struct {
+	// 响应体的最大字节数，为 0 时不限制
+	maxSize int64
+	// 按内容类型设置的最大字节数，键已被转为小写
+	maxSizeByType map[string]int64
+	// 超过限制时是否丢弃整个响应
+	discard bool
+}
+
+// 初始化响应体大小的限制器，没有任何限制时为 nil
+func (sched *myScheduler) initBodyLimiter(args BodyLimitArgs) {
+	sched.spillThreshold = args.SpillThreshold
+	log.L().Sugar().Infof("-- Spill threshold: %d", args.SpillThreshold)
+	if args.MaxSize == 0 && len(args.MaxSizeByType) == 0 {
+		sched.bodyLimiter = nil
+		log.L().Sugar().Info("-- Body limit: disabled")
+		return
+	}
+	limiter := &bodyLimiter{
+		maxSize:       args.MaxSize,
+		maxSizeByType: make(map[string]int64, len(args.MaxSizeByType)),
+		discard:       args.Discard,
+	}
+	for contentType, size := range args.MaxSizeByType {
+		limiter.maxSizeByType[strings.ToLower(contentType)] = size
+	}
+	sched.bodyLimiter = limiter
+	log.L().Sugar().Infof("-- Body limit: max size: %d, by type: %v, discard: %v",
+		args.MaxSize, args.MaxSizeByType, args.Discard)
+}
+
+// limit 用于获取给定内容类型的响应体的最大字节数，为 0 时不限制
+// 精确的类型优先于通配的类型
+func (limiter *bodyLimiter) limit(contentType string) int64 {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		return limiter.maxSize
+	}
+	if size, ok := limiter.maxSizeByType[mediaType]; ok {
+		return size
+	}
+	if i := strings.Index(mediaType, "/"); i > 0 {
+		if size, ok := limiter.maxSizeByType[mediaType[:i]+"/*"]; ok {
+			return size
+		}
+	}
+	return limiter.maxSize
+}
+
+// 按限制处理响应体
+// 声明的长度已超过限制且需要丢弃时会关闭响应体并返回错误
+// 否则响应体在被读取时才受到限制
+func (sched *myScheduler) limitBody(resp *module.Response) error {
+	limiter := sched.bodyLimiter
+	if limiter == nil || resp == nil || resp.HTTPResp() == nil || resp.HTTPResp().Body == nil {
+		return nil
+	}
+	httpResp := resp.HTTPResp()
+	limit := limiter.limit(httpResp.Header.Get("Content-Type"))
+	if limit <= 0 {
+		return nil
+	}
+	if httpResp.ContentLength > limit {
+		if limiter.discard {
+			httpResp.Body.Close()
+			return genTooLargeError(httpResp.ContentLength, limit)
+		}
+		resp.SetTruncated(true)
+	}
+	httpResp.Body = &limitedBody{
+		ReadCloser: httpResp.Body,
+		remaining:  limit,
+		limit:      limit,
+		discard:    limiter.discard,
+		resp:       resp,
+	}
+	return nil
+}
+
+// genTooLargeError 用于生成响应体超过大小限制的错误值。
+// size 为负数时代表实际长度未知。
+func genTooLargeError(size int64, limit int64) error {
+	if size < 0 {
+		return fmt.Errorf("%w: exceeds the limit %d", errors.ErrTooLarge, limit)
+	}
+	return fmt.Errorf("%w: %d bytes exceeds the limit %d", errors.ErrTooLarge, size, limit)
+}
+
+// limitedBody 代表受大小限制的响应体。
+type limitedBody struct {
+	io.ReadCloser
+	// 还可以读取的字节数
+	remaining int64
+	// 最大字节数
+	limit int64
+	// 超过限制时是否返回错误，否则截断
+	discard bool
+	// 所属的响应
+	resp *module.Response
+	// 读满最大字节数后的结果
+	err error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		if b.err == nil {
+			b.err = b.exceed()
+		}
+		return 0, b.err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// 判断响应体是否还有剩余时，允许连续读到 0 个字节的最大次数，与 io.ReadAtLeast 相同
+const maxEmptyProbes = 100
+
+// exceed 用于在读满最大字节数后判断响应体是否还有剩余
+// 连续多次读不到任何字节时视为没有剩余
+func (b *limitedBody) exceed() error {
+	var probe [1]byte
+	for i := 0; ; i++ {
+		if i >= maxEmptyProbes {
+			return io.EOF
+		}
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if b.discard {
+		return genTooLargeError(-1, b.limit)
+	}
+	b.resp.SetTruncated(true)
+	return io.EOF
+}
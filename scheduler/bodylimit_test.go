@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	stderrors "errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+func TestBodyLimitArgs(t *testing.T) {
+	legal := BodyLimitArgs{
+		MaxSize:       1024,
+		MaxSizeByType: map[string]int64{"text/html": 10, "image/*": 0},
+	}
+	if err := legal.Check(); err != nil {
+		t.Fatalf("An error occurs when checking body limit arguments: %s", err)
+	}
+	illegalArgs := []BodyLimitArgs{
+		{MaxSize: -1},
+		{SpillThreshold: -1},
+		{MaxSizeByType: map[string]int64{"text/html": -1}},
+		{MaxSizeByType: map[string]int64{"html": 1}},
+		{MaxSizeByType: map[string]int64{"*/*": 1}},
+		{MaxSizeByType: map[string]int64{"text/html; charset=utf-8": 1}},
+	}
+	for i, args := range illegalArgs {
+		if err := args.Check(); err == nil {
+			t.Fatalf("No error when checking illegal body limit arguments: %#v (index: %d)", args, i)
+		}
+	}
+	another := legal
+	another.MaxSizeByType = map[string]int64{"text/html": 10, "image/*": 1}
+	if legal.Same(&another) {
+		t.Fatal("Different body limit arguments were considered the same!")
+	}
+}
+
+func TestSpillThreshold(t *testing.T) {
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.BodyLimit.SpillThreshold = 1024
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	a, ok := moduleArgs.Analyzers[0].(module.SpillingAnalyzer)
+	if !ok {
+		t.Fatalf("The analyzer %T should implement module.SpillingAnalyzer!", moduleArgs.Analyzers[0])
+	}
+	if a.SpillThreshold() != requestArgs.BodyLimit.SpillThreshold {
+		t.Fatalf("Inconsistent spill threshold: expected: %d, actual: %d",
+			requestArgs.BodyLimit.SpillThreshold, a.SpillThreshold())
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	sched := &myScheduler{}
+	sched.initBodyLimiter(BodyLimitArgs{
+		MaxSize:       8,
+		MaxSizeByType: map[string]int64{"Text/HTML": 4, "image/*": 2, "text/plain": 0},
+	})
+	limiter := sched.bodyLimiter
+	limits := map[string]int64{
+		"text/html; charset=utf-8": 4,
+		"image/png":                2,
+		"text/plain":               0,
+		"application/json":         8,
+		"":                         8,
+	}
+	for contentType, expected := range limits {
+		if limit := limiter.limit(contentType); limit != expected {
+			t.Fatalf("Inconsistent limit: expected: %d, actual: %d (content type: %q)",
+				expected, limit, contentType)
+		}
+	}
+
+	cases := []struct {
+		body          string
+		contentLength int64
+		discard       bool
+		expected      string
+		truncated     bool
+		tooLarge      bool
+	}{
+		{"0123456789", -1, false, "01234567", true, false},
+		{"0123456789", 10, false, "01234567", true, false},
+		{"01234567", -1, false, "01234567", false, false},
+		{"0123456789", -1, true, "", false, true},
+		{"0123456789", 10, true, "", false, true},
+	}
+	for i, c := range cases {
+		limiter.discard = c.discard
+		httpResp := &http.Response{
+			Header:        http.Header{},
+			Body:          io.NopCloser(strings.NewReader(c.body)),
+			ContentLength: c.contentLength,
+		}
+		resp := module.NewResponse(httpResp, 0)
+		err := sched.limitBody(resp)
+		var content []byte
+		if err == nil {
+			content, err = io.ReadAll(httpResp.Body)
+		}
+		if c.tooLarge {
+			if !stderrors.Is(err, errors.ErrTooLarge) {
+				t.Fatalf("Inconsistent error: expected: %v, actual: %v (index: %d)",
+					errors.ErrTooLarge, err, i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("An error occurs when reading body: %s (index: %d)", err, i)
+		}
+		if string(content) != c.expected || resp.Truncated() != c.truncated {
+			t.Fatalf("Inconsistent body: expected: %q (truncated: %v), actual: %q (truncated: %v) (index: %d)",
+				c.expected, c.truncated, content, resp.Truncated(), i)
+		}
+	}
+}
+
+// emptyReader 代表读满给定内容后总是读到 0 个字节且不返回错误的读取器。
+type emptyReader struct {
+	content *strings.Reader
+}
+
+func (r *emptyReader) Read(p []byte) (int, error) {
+	if r.content.Len() == 0 {
+		return 0, nil
+	}
+	return r.content.Read(p)
+}
+
+func TestBodyLimitEmptyReads(t *testing.T) {
+	sched := &myScheduler{}
+	sched.initBodyLimiter(BodyLimitArgs{MaxSize: 4, Discard: true})
+	httpResp := &http.Response{
+		Header:        http.Header{},
+		Body:          io.NopCloser(&emptyReader{content: strings.NewReader("0123")}),
+		ContentLength: -1,
+	}
+	resp := module.NewResponse(httpResp, 0)
+	if err := sched.limitBody(resp); err != nil {
+		t.Fatalf("An error occurs when limiting body: %s", err)
+	}
+	// 一直读不到更多字节时不应无限重试，也不应视为超过限制
+	content, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		t.Fatalf("An error occurs when reading body: %s", err)
+	}
+	if string(content) != "0123" || resp.Truncated() {
+		t.Fatalf("Inconsistent body: expected: %q, actual: %q (truncated: %v)",
+			"0123", content, resp.Truncated())
+	}
+}
@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"net/url"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/toolkit/urlnorm"
+)
+
+// 初始化 URL 规范化器
+func (sched *myScheduler) initNormalizer(args CanonicalArgs) {
+	sched.normalizer = nil
+	if !args.Enabled {
+		log.L().Sugar().Info("-- URL canonicalization: disabled")
+		return
+	}
+	sched.normalizer = urlnorm.New(urlnorm.Rules{
+		KeepFragment:   args.KeepFragment,
+		KeepQueryOrder: args.KeepQueryOrder,
+		StripParams:    args.StripParams,
+	})
+	log.L().Sugar().Infof("-- URL canonicalization: keep fragment: %v, keep query order: %v, strip params: %v",
+		args.KeepFragment, args.KeepQueryOrder, args.StripParams)
+}
+
+// 获取 URL 用于去重的键
+// 未启用 URL 规范化时即为 URL 本身
+func (sched *myScheduler) urlKey(u *url.URL) string {
+	if sched.normalizer == nil {
+		return u.String()
+	}
+	return sched.normalizer.Normalize(u).String()
+}
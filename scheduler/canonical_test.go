@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// countAccepted 用于统计给定的 URL 中被调度器接受的数量。
+func countAccepted(canonical CanonicalArgs, urls []string, t *testing.T) int {
+	requestArgs := genRequestArgs([]string{"a.com"}, 0)
+	requestArgs.Canonical = canonical
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	mySched := sched.(*myScheduler)
+	accepted := 0
+	for _, url := range urls {
+		httpReq, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("An error occurs when creating a HTTP request: %s (url: %s)",
+				err, url)
+		}
+		if mySched.sendReq(module.NewRequest(httpReq, 0)) {
+			accepted++
+		}
+	}
+	return accepted
+}
+
+func TestCanonicalDedup(t *testing.T) {
+	urls := []string{
+		"http://a.com/x?b=1&a=2",
+		"http://A.com:80/x?a=2&b=1",
+		"http://a.com/x?b=1&a=2#frag",
+		"http://a.com/y/../x?a=2&b=1&utm_source=feed",
+	}
+	canonical := CanonicalArgs{
+		Enabled:     true,
+		StripParams: []string{"utm_*"},
+	}
+	if n := countAccepted(canonical, urls, t); n != 1 {
+		t.Fatalf("Inconsistent accepted request number: expected: %d, actual: %d",
+			1, n)
+	}
+	canonical.KeepFragment = true
+	if n := countAccepted(canonical, urls, t); n != 2 {
+		t.Fatalf("Inconsistent accepted request number: expected: %d, actual: %d",
+			2, n)
+	}
+	// 未启用时只按原样去重。
+	urls = []string{
+		"http://a.com/x?b=1&a=2",
+		"http://a.com/x?a=2&b=1",
+		"http://a.com/x?b=1&a=2#frag",
+		"http://a.com/x?b=1&a=2",
+	}
+	if n := countAccepted(CanonicalArgs{}, urls, t); n != 3 {
+		t.Fatalf("Inconsistent accepted request number: expected: %d, actual: %d",
+			3, n)
+	}
+}
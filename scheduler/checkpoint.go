@@ -0,0 +1,400 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/dedup"
+	"github.com/dokidokikoi/webcrawler/toolkit/frontier"
+)
+
+// 检查点文件的名称
+const checkpointFileName = "checkpoint.json"
+
+// 检查点文件格式的版本
+const checkpointVersion = 1
+
+// 已处理的 URL 集合文件的名称前缀与后缀
+// 每次生成检查点都会写入一个新的文件，旧的文件会在检查点写入后删除
+const (
+	seenFilePrefix = "seen-"
+	seenFileSuffix = ".dat"
+)
+
+// 检查点中的待处理请求
+type checkpointRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	// 请求体，JSON 中以 Base64 编码
+	Body  []byte `json:"body,omitempty"`
+	Depth uint32 `json:"depth"`
+	// 请求的优先级
+	Priority float64 `json:"priority,omitempty"`
+	// 从所属起点出发的最大爬取深度，为 nil 时使用调度器的最大深度
+	MaxDepth *uint32 `json:"max_depth,omitempty"`
+	// 已进行的下载次数
+	Attempt uint32 `json:"attempt,omitempty"`
+	// 父请求的 URL 和所属起点的 URL
+	ParentURL string `json:"parent_url,omitempty"`
+	RootURL   string `json:"root_url,omitempty"`
+	// 请求的元数据，恢复后其中的数字都会变为 float64
+	Meta module.Metadata `json:"meta,omitempty"`
+}
+
+// 检查点的内容
+// 条目缓冲池中尚未处理的条目不会被保存
+type checkpointData struct {
+	Version         int         `json:"version"`
+	Time            time.Time   `json:"time"`
+	RequestArgs     RequestArgs `json:"request_args"`
+	DataArgs        DataArgs    `json:"data_args"`
+	AcceptedDomains []string    `json:"accepted_domains"`
+	// 是否使用了自定义的优先级函数，该函数本身无法被保存
+	CustomPriority bool `json:"custom_priority,omitempty"`
+	// 已处理的 URL 集合文件的名称，位于检查点目录中
+	SeenFile string `json:"seen_file"`
+	// 已处理的 URL 的数量
+	SeenNumber      uint64                       `json:"seen_number"`
+	PendingRequests []checkpointRequest          `json:"pending_requests"`
+	Modules         map[module.MID]module.Counts `json:"modules"`
+}
+
+// 可以恢复计数的组件
+type countsRestorer interface {
+	RestoreCounts(counts module.Counts)
+}
+
+// 初始化检查点相关字段
+func (sched *myScheduler) initCheckpoint(dataArgs DataArgs) {
+	sched.checkpointDir = dataArgs.CheckpointDir
+	sched.checkpointInterval = dataArgs.CheckpointInterval
+	if sched.checkpointDir == "" {
+		log.L().Sugar().Info("-- Checkpoint: disabled")
+		return
+	}
+	log.L().Sugar().Infof("-- Checkpoint: dir: %s, interval: %s",
+		sched.checkpointDir, sched.checkpointInterval)
+}
+
+// 定期生成检查点，直到调度器停止
+func (sched *myScheduler) checkpointLoop() {
+	if sched.checkpointDir == "" || sched.checkpointInterval <= 0 {
+		return
+	}
+	sched.workers.Add(1)
+	go func() {
+		defer sched.workers.Done()
+		ticker := time.NewTicker(sched.checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sched.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := sched.writeCheckpoint(); err != nil {
+					log.L().Sugar().Errorf("Couldn't write checkpoint: %s", err)
+					sched.sendError(err, "")
+				}
+			}
+		}
+	}()
+}
+
+func (sched *myScheduler) Checkpoint() (err error) {
+	if sched.urlMap == nil {
+		return genError("the scheduler has not been initialized")
+	}
+	if sched.checkpointDir == "" {
+		return genError("empty checkpoint dir")
+	}
+	return sched.writeCheckpoint()
+}
+
+// 生成爬取进度的快照，已处理的 URL 集合会被写入 seen
+func (sched *myScheduler) snapshot(seen io.Writer) (*checkpointData, error) {
+	ss := sched.summary.Struct()
+	data := &checkpointData{
+		Version:     checkpointVersion,
+		Time:        time.Now(),
+		RequestArgs: ss.RequestArgs,
+		DataArgs:    ss.DataArgs,
+		Modules:     map[module.MID]module.Counts{},
+		// 自定义的优先级函数不会被写入检查点，恢复时需要重新提供
+		CustomPriority: ss.RequestArgs.Frontier.Priority != nil,
+	}
+	// 先写入已处理的 URL 集合再取出待处理的请求，写入期间不阻塞新请求的放入。
+	// 新请求总是在持有读锁时同时被加入这两者，而请求只在处理完毕后才被移出待处理集合，
+	// 所以在写锁下取出的待处理请求包含了集合中所有尚未处理完毕的 URL
+	data.SeenNumber = sched.urlMap.Len()
+	if err := sched.urlMap.Save(seen); err != nil {
+		return nil, err
+	}
+	sched.frontierLock.Lock()
+	data.AcceptedDomains = sched.acceptedDomainMap.Elements()
+	reqs := sched.pending.Requests()
+	sched.frontierLock.Unlock()
+	data.PendingRequests = make([]checkpointRequest, 0, len(reqs))
+	for _, req := range reqs {
+		httpReq := req.HTTPReq()
+		body, err := requestBody(httpReq)
+		if err != nil {
+			log.L().Sugar().Warnf("Ignore the pending request in checkpoint! %s (URL: %s)",
+				err, httpReq.URL)
+			continue
+		}
+		cr := checkpointRequest{
+			Method:    httpReq.Method,
+			URL:       httpReq.URL.String(),
+			Header:    httpReq.Header,
+			Body:      body,
+			Depth:     req.Depth(),
+			Priority:  req.Priority(),
+			Attempt:   req.Attempt(),
+			ParentURL: req.ParentURL(),
+			RootURL:   req.RootURL(),
+			Meta:      req.Meta(),
+		}
+		if maxDepth, ok := req.MaxDepth(); ok {
+			cr.MaxDepth = &maxDepth
+		}
+		data.PendingRequests = append(data.PendingRequests, cr)
+	}
+	for mid, m := range sched.registrar.GetAll() {
+		data.Modules[mid] = m.Counts()
+	}
+	return data, nil
+}
+
+// 获取 HTTP 请求的请求体，以便写入检查点
+// 请求体只能通过 GetBody 重新获取，无法重新获取时返回错误值
+func requestBody(httpReq *http.Request) ([]byte, error) {
+	if httpReq.Body == nil || httpReq.Body == http.NoBody {
+		return nil, nil
+	}
+	if httpReq.GetBody == nil {
+		return nil, fmt.Errorf("its body cannot be read again")
+	}
+	body, err := httpReq.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get its body: %s", err)
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// 把爬取进度写入检查点目录
+// 先写入临时文件再重命名，以免留下不完整的检查点
+func (sched *myScheduler) writeCheckpoint() error {
+	sched.checkpointLock.Lock()
+	defer sched.checkpointLock.Unlock()
+	dir := sched.checkpointDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return genErrorByError(err)
+	}
+	seenFile, err := os.CreateTemp(dir, seenFilePrefix+"*.tmp")
+	if err != nil {
+		return genErrorByError(err)
+	}
+	defer os.Remove(seenFile.Name())
+	data, err := sched.snapshot(seenFile)
+	if err == nil {
+		err = seenFile.Sync()
+	}
+	if closeErr := seenFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return genErrorByError(err)
+	}
+	data.SeenFile = fmt.Sprintf("%s%d%s", seenFilePrefix, data.Time.UnixNano(), seenFileSuffix)
+	if err := os.Rename(seenFile.Name(), filepath.Join(dir, data.SeenFile)); err != nil {
+		return genErrorByError(err)
+	}
+	file, err := os.CreateTemp(dir, checkpointFileName+".*")
+	if err != nil {
+		return genErrorByError(err)
+	}
+	defer os.Remove(file.Name())
+	if err := json.NewEncoder(file).Encode(data); err != nil {
+		file.Close()
+		return genErrorByError(err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return genErrorByError(err)
+	}
+	if err := file.Close(); err != nil {
+		return genErrorByError(err)
+	}
+	if err := os.Rename(file.Name(), filepath.Join(dir, checkpointFileName)); err != nil {
+		return genErrorByError(err)
+	}
+	removeStaleSeenFiles(dir, data.SeenFile)
+	log.L().Sugar().Infof("Checkpoint has been written. (seen URLs: %d, pending requests: %d)",
+		data.SeenNumber, len(data.PendingRequests))
+	return nil
+}
+
+// 删除检查点目录中除 current 以外的已处理的 URL 集合文件
+func removeStaleSeenFiles(dir string, current string) {
+	names, err := filepath.Glob(filepath.Join(dir, seenFilePrefix+"*"+seenFileSuffix))
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		if filepath.Base(name) == current {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			log.L().Sugar().Warnf("Couldn't remove the stale seen file: %s", err)
+		}
+	}
+}
+
+// 从检查点目录读取已处理的 URL 集合并放入去重存储
+func loadSeenFile(dir string, data *checkpointData, deduper dedup.Deduper) error {
+	if data.SeenFile == "" {
+		return nil
+	}
+	file, err := os.Open(filepath.Join(dir, filepath.Base(data.SeenFile)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return deduper.Load(file)
+}
+
+// 从检查点目录读取检查点
+func readCheckpoint(dir string) (*checkpointData, error) {
+	file, err := os.Open(filepath.Join(dir, checkpointFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data := &checkpointData{}
+	if err := json.NewDecoder(file).Decode(data); err != nil {
+		return nil, err
+	}
+	if data.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d", data.Version)
+	}
+	return data, nil
+}
+
+func (sched *myScheduler) ResumeFrom(
+	checkpointDir string,
+	moduleArgs ModuleArgs,
+	priority frontier.PriorityFunc) (err error) {
+	log.L().Sugar().Infof("Resume scheduler from checkpoint %q...", checkpointDir)
+	if checkpointDir == "" {
+		return genParameterError("empty checkpoint dir")
+	}
+	data, err := readCheckpoint(checkpointDir)
+	if err != nil {
+		return genParameterError(fmt.Sprintf("couldn't read checkpoint: %s", err))
+	}
+	if data.CustomPriority && priority == nil {
+		return genParameterError("the checkpoint was written with a custom priority function, " +
+			"which must be provided again")
+	}
+	requestArgs := data.RequestArgs
+	requestArgs.Frontier.Priority = priority
+	// 之后的检查点写回同一目录
+	dataArgs := data.DataArgs
+	dataArgs.CheckpointDir = checkpointDir
+	if err = sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		return err
+	}
+	// 恢复中途失败时关闭初始化时打开的存储
+	defer func() {
+		if err != nil {
+			sched.closeStores()
+		}
+	}()
+
+	// 检查状态
+	log.L().Sugar().Info("Check status for resume...")
+	var oldStatus Status
+	oldStatus, err = sched.checkAndSetStatus(SCHED_STATUS_STARTING)
+	defer func() {
+		sched.statusLock.Lock()
+		if err != nil {
+			sched.status = oldStatus
+		} else {
+			sched.status = SCHED_STATUS_STARTED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil {
+		return
+	}
+
+	// 恢复爬取进度
+	for _, domain := range data.AcceptedDomains {
+		sched.acceptedDomainMap.Add(domain)
+	}
+	if err = loadSeenFile(checkpointDir, data, sched.urlMap); err != nil {
+		err = genErrorByError(fmt.Errorf("couldn't load seen URLs: %s", err))
+		return
+	}
+	for mid, m := range sched.registrar.GetAll() {
+		counts, ok := data.Modules[mid]
+		if !ok {
+			continue
+		}
+		if restorer, ok := m.(countsRestorer); ok {
+			restorer.RestoreCounts(counts)
+		}
+	}
+	reqs := make([]*module.Request, 0, len(data.PendingRequests))
+	for _, cr := range data.PendingRequests {
+		var body io.Reader
+		if len(cr.Body) > 0 {
+			body = bytes.NewReader(cr.Body)
+		}
+		httpReq, err := http.NewRequest(cr.Method, cr.URL, body)
+		if err != nil {
+			log.L().Sugar().Warnf("Ignore the pending request! Its URL is invalid: %s (URL: %s)",
+				err, cr.URL)
+			continue
+		}
+		if cr.Header != nil {
+			httpReq.Header = cr.Header
+		}
+		// 检查点中的已处理的 URL 集合不一定包含之后才放入的待处理请求
+		sched.urlMap.Add(sched.urlKey(httpReq.URL))
+		req := module.NewRequest(httpReq, cr.Depth)
+		req.SetPriority(cr.Priority)
+		req.SetAttempt(cr.Attempt)
+		req.SetLineage(cr.ParentURL, cr.RootURL)
+		req.SetMeta(cr.Meta)
+		if cr.MaxDepth != nil {
+			req.SetMaxDepth(*cr.MaxDepth)
+		}
+		reqs = append(reqs, req)
+	}
+	log.L().Sugar().Infof("-- Restored: seen URLs: %d, pending requests: %d",
+		sched.urlMap.Len(), len(reqs))
+
+	// 开始调度数据和组件
+	if err = sched.checkBufferPoolForStart(); err != nil {
+		return
+	}
+	sched.startWorkers()
+	log.L().Sugar().Info("Scheduler has been resumed.")
+
+	// 重新放入待处理的请求
+	for _, req := range reqs {
+		sched.putReq(req)
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/dedup"
+)
+
+// genCheckpointServer 用于生成一个测试服务器。
+// 对 /slow 的首次请求会被阻塞，直到调用返回的释放函数。
+func genCheckpointServer() (*httptest.Server, func() map[string]int, func()) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	block := make(chan struct{})
+	var once sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		first := hits[r.URL.Path] == 1
+		lock.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/p1">p1</a><a href="/p2">p2</a><a href="/slow">slow</a>`)
+		case "/slow":
+			if first {
+				<-block
+			}
+			fmt.Fprint(w, `<a href="/child">child</a><a href="/p1">p1</a>`)
+		default:
+			fmt.Fprint(w, "<html></html>")
+		}
+	}))
+	hitsFunc := func() map[string]int {
+		lock.Lock()
+		defer lock.Unlock()
+		result := map[string]int{}
+		for k, v := range hits {
+			result[k] = v
+		}
+		return result
+	}
+	return server, hitsFunc, func() { once.Do(func() { close(block) }) }
+}
+
+func TestCheckpointAndResume(t *testing.T) {
+	server, hits, release := genCheckpointServer()
+	defer server.Close()
+	defer release()
+	dir := t.TempDir()
+
+	requestArgs := genRequestArgs([]string{}, 2)
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.DownloaderWorkerNumber = 2
+	dataArgs.CheckpointDir = dir
+	dataArgs.CheckpointInterval = time.Hour
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	// 等待除 /slow 之外的请求都处理完毕。
+	mySched := sched.(*myScheduler)
+	for i := 0; mySched.pending.Len() != 1 || mySched.urlMap.Len() != 4; i++ {
+		if i >= 300 {
+			t.Fatalf("Timeout when waiting for crawling! (hits: %v)", hits())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sched.Checkpoint(); err != nil {
+		t.Fatalf("An error occurs when writing checkpoint: %s", err)
+	}
+	// 停止时会再次生成检查点。
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	release()
+
+	data, err := readCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reading checkpoint: %s", err)
+	}
+	if data.SeenNumber != 4 {
+		t.Fatalf("Inconsistent seen URL number: expected: %d, actual: %d",
+			4, data.SeenNumber)
+	}
+	// 旧的已处理的 URL 集合文件应已被删除。
+	seenFiles, _ := filepath.Glob(filepath.Join(dir, seenFilePrefix+"*"))
+	if len(seenFiles) != 1 || filepath.Base(seenFiles[0]) != data.SeenFile {
+		t.Fatalf("Inconsistent seen files: %v (current: %s)", seenFiles, data.SeenFile)
+	}
+	if len(data.PendingRequests) != 1 ||
+		data.PendingRequests[0].URL != server.URL+"/slow" ||
+		data.PendingRequests[0].Depth != 1 {
+		t.Fatalf("Inconsistent pending requests: %#v", data.PendingRequests)
+	}
+	if data.DataArgs != dataArgs {
+		t.Fatalf("Inconsistent data args: expected: %#v, actual: %#v",
+			dataArgs, data.DataArgs)
+	}
+	downloaderCalled := uint64(0)
+	for _, counts := range data.Modules {
+		downloaderCalled += counts.CalledCount
+	}
+	if downloaderCalled == 0 {
+		t.Fatalf("The module counts were not saved: %#v", data.Modules)
+	}
+
+	// 在新的调度器中恢复。
+	resumed := NewScheduler()
+	if err := resumed.ResumeFrom(dir, genSimpleModuleArgs(2, 1, 1, t), nil); err != nil {
+		t.Fatalf("An error occurs when resuming scheduler: %s", err)
+	}
+	if status := resumed.Status(); status != SCHED_STATUS_STARTED {
+		t.Fatalf("Inconsistent status: expected: %d, actual: %d",
+			SCHED_STATUS_STARTED, status)
+	}
+	waitForIdle(resumed, 3, t)
+	summary := resumed.Summary().Struct()
+	if err := resumed.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	result := hits()
+	for _, path := range []string{"/", "/p1", "/p2", "/child"} {
+		if result[path] != 1 {
+			t.Fatalf("Inconsistent hits for %s: expected: %d, actual: %d (hits: %v)",
+				path, 1, result[path], result)
+		}
+	}
+	if result["/slow"] != 2 {
+		t.Fatalf("The pending request was not resumed! (hits: %v)", result)
+	}
+	if summary.NumURL != 5 {
+		t.Fatalf("Inconsistent URL number: expected: %d, actual: %d",
+			5, summary.NumURL)
+	}
+	if summary.DataArgs.CheckpointDir != dir {
+		t.Fatalf("Inconsistent checkpoint dir: expected: %s, actual: %s",
+			dir, summary.DataArgs.CheckpointDir)
+	}
+}
+
+func TestCheckpointWithoutDir(t *testing.T) {
+	sched := NewScheduler()
+	if err := sched.Checkpoint(); err == nil {
+		t.Fatal("It still can write checkpoint with uninitialized scheduler!")
+	}
+	if err := sched.Init(genRequestArgs([]string{}, 0), genDataArgs(10, 2, 0),
+		genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.Checkpoint(); err == nil {
+		t.Fatal("It still can write checkpoint without checkpoint dir!")
+	}
+	if err := NewScheduler().ResumeFrom(t.TempDir(), genSimpleModuleArgs(1, 1, 1, t), nil); err == nil {
+		t.Fatal("It still can resume from an empty dir!")
+	}
+}
+
+func TestResumeFailureClosesStores(t *testing.T) {
+	dir := t.TempDir()
+	dedupDir := t.TempDir()
+	dataArgs := genDataArgs(10, 2, 0)
+	dataArgs.CheckpointDir = dir
+	dataArgs.DedupType = dedup.TYPE_DISK
+	dataArgs.DedupDir = dedupDir
+	dataArgs.DeadLetterFile = filepath.Join(t.TempDir(), "dead.jsonl")
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 0), dataArgs, genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.Checkpoint(); err != nil {
+		t.Fatalf("An error occurs when writing checkpoint: %s", err)
+	}
+	sched.(*myScheduler).closeStores()
+	// 损坏已处理的 URL 的文件，使恢复在初始化之后失败
+	seenFiles, _ := filepath.Glob(filepath.Join(dir, seenFilePrefix+"*"))
+	if len(seenFiles) != 1 {
+		t.Fatalf("Inconsistent seen files: %v", seenFiles)
+	}
+	if err := os.WriteFile(seenFiles[0], []byte("broken"), 0644); err != nil {
+		t.Fatalf("An error occurs when writing seen file: %s", err)
+	}
+	resumed := NewScheduler()
+	if err := resumed.ResumeFrom(dir, genSimpleModuleArgs(1, 1, 1, t), nil); err == nil {
+		t.Fatal("No error when resuming from a broken checkpoint!")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dedupDir, "*")); len(files) != 0 {
+		t.Fatalf("The URL map was not closed after the failed resume: %v", files)
+	}
+	if store := resumed.(*myScheduler).deadLetters; store == nil || store.AddItem(module.Item{}, 0, fmt.Errorf("test")) == nil {
+		t.Fatal("The dead letter store was not closed after the failed resume!")
+	}
+}
+
+func TestResumeWithCustomPriority(t *testing.T) {
+	dir := t.TempDir()
+	requestArgs := genRequestArgs([]string{}, 0)
+	requestArgs.Frontier.Priority = func(req *module.Request) float64 {
+		return -float64(req.Depth())
+	}
+	dataArgs := genDataArgs(10, 2, 0)
+	dataArgs.CheckpointDir = dir
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.Checkpoint(); err != nil {
+		t.Fatalf("An error occurs when writing checkpoint: %s", err)
+	}
+	sched.(*myScheduler).closeStores()
+	// 未重新提供优先级函数时不能恢复，以免静默地退回先进先出的顺序。
+	if err := NewScheduler().ResumeFrom(dir, genSimpleModuleArgs(1, 1, 1, t), nil); err == nil {
+		t.Fatal("It still can resume without the custom priority function!")
+	}
+	resumed := NewScheduler()
+	if err := resumed.ResumeFrom(dir, genSimpleModuleArgs(1, 1, 1, t), requestArgs.Frontier.Priority); err != nil {
+		t.Fatalf("An error occurs when resuming scheduler: %s", err)
+	}
+	defer resumed.Stop()
+	frontierArgs := resumed.Summary().Struct().RequestArgs.Frontier
+	if frontierArgs.Priority == nil {
+		t.Fatal("The custom priority function was not used after resuming!")
+	}
+}
+
+func TestCheckpointRequestBody(t *testing.T) {
+	var lock sync.Mutex
+	bodies := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		bodies[r.Method+" "+r.URL.Path] = string(body)
+		lock.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	dataArgs := genDataArgs(10, 2, 0)
+	dataArgs.CheckpointDir = dir
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 1), dataArgs, genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	mySched := sched.(*myScheduler)
+	postHTTPReq, _ := http.NewRequest("POST", server.URL+"/post", strings.NewReader("q=1"))
+	postReq := module.NewRequest(postHTTPReq, 0)
+	mySched.pending.Add(pendingKey(postReq), postReq)
+	// 无法重新读取请求体的请求不会被写入检查点。
+	streamHTTPReq, _ := http.NewRequest("POST", server.URL+"/stream", io.NopCloser(strings.NewReader("q=2")))
+	streamReq := module.NewRequest(streamHTTPReq, 0)
+	mySched.pending.Add(pendingKey(streamReq), streamReq)
+	if err := sched.Checkpoint(); err != nil {
+		t.Fatalf("An error occurs when writing checkpoint: %s", err)
+	}
+	mySched.closeStores()
+	data, err := readCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("An error occurs when reading checkpoint: %s", err)
+	}
+	if len(data.PendingRequests) != 1 || string(data.PendingRequests[0].Body) != "q=1" {
+		t.Fatalf("Inconsistent pending requests: %#v", data.PendingRequests)
+	}
+	resumed := NewScheduler()
+	if err := resumed.ResumeFrom(dir, genSimpleModuleArgs(1, 1, 1, t), nil); err != nil {
+		t.Fatalf("An error occurs when resuming scheduler: %s", err)
+	}
+	waitForIdle(resumed, 3, t)
+	if err := resumed.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if body := bodies["POST /post"]; body != "q=1" {
+		t.Fatalf("Inconsistent request body after resuming: expected: %q, actual: %q", "q=1", body)
+	}
+}
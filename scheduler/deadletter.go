@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/deadletter"
+)
+
+// 初始化死信存储
+// 已存在的死信存储会先被关闭
+func (sched *myScheduler) initDeadLetter(dataArgs DataArgs) error {
+	sched.closeDeadLetter()
+	sched.deadLetters = nil
+	if dataArgs.DeadLetterFile == "" {
+		log.L().Sugar().Info("-- Dead letter: disabled")
+		return nil
+	}
+	store, err := deadletter.NewStore(dataArgs.DeadLetterFile)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	sched.deadLetters = store
+	log.L().Sugar().Infof("-- Dead letter: file: %s", dataArgs.DeadLetterFile)
+	return nil
+}
+
+// 关闭死信存储
+func (sched *myScheduler) closeDeadLetter() {
+	if sched.deadLetters == nil {
+		return
+	}
+	if err := sched.deadLetters.Close(); err != nil {
+		log.L().Sugar().Warnf("Couldn't close the dead letter store: %s", err)
+	}
+}
+
+// 记录最终失败的请求
+func (sched *myScheduler) deadLetterRequest(req *module.Request, err error) {
+	store := sched.deadLetters
+	if store == nil {
+		return
+	}
+	if err := store.AddRequest(req, err); err != nil {
+		log.L().Sugar().Errorf("Couldn't record the failed request: %s", err)
+	}
+}
+
+// 记录处理失败的条目
+// 条目处理函数的序号取自首个 module.ProcessorError
+// 被有意过滤的条目不会被记录
+func (sched *myScheduler) deadLetterItem(item module.Item, errs []error) {
+	store := sched.deadLetters
+	if store == nil || len(errs) == 0 {
+		return
+	}
+	index := -1
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		if err == nil || isFiltered(err) {
+			continue
+		}
+		var pe *module.ProcessorError
+		if index < 0 && errors.As(err, &pe) {
+			index = pe.Index
+		}
+		msgs = append(msgs, err.Error())
+	}
+	if len(msgs) == 0 {
+		return
+	}
+	if err := store.AddItem(item, index, errors.New(strings.Join(msgs, "; "))); err != nil {
+		log.L().Sugar().Errorf("Couldn't record the failed item: %s", err)
+	}
+}
+
+func (sched *myScheduler) Reinject(reqs ...*module.Request) (accepted int, rejected map[string]string) {
+	return sched.enqueue(reqs, true)
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/module/local/pipeline"
+	"github.com/dokidokikoi/webcrawler/toolkit/deadletter"
+)
+
+func TestDeadLetter(t *testing.T) {
+	// 为 1 时 /gone 会正常响应。
+	var recovered uint32
+	var lock sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		lock.Unlock()
+		if r.URL.Path == "/gone" && atomic.LoadUint32(&recovered) == 0 {
+			// 不返回响应直接关闭连接。
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/gone">gone</a>`)
+			return
+		}
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	// 第二个条目处理函数总会失败。
+	moduleArgs := genSimpleModuleArgs(2, 1, 0, t)
+	p, err := pipeline.New("P1", []module.ProcessItem{
+		processItem,
+		func(item module.Item) (module.Item, error) {
+			return nil, errors.New("rejected item")
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	moduleArgs.Pipelines = []module.Pipeline{p}
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.DeadLetterFile = path
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 1), dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	records, err := deadletter.ReadFile(path)
+	if err != nil {
+		t.Fatalf("An error occurs when reading dead letters: %s", err)
+	}
+	var reqRecords, itemRecords int
+	for _, record := range records {
+		switch record.Kind {
+		case deadletter.KIND_REQUEST:
+			reqRecords++
+			if record.Request.URL != server.URL+"/gone" || record.Request.Attempt != 1 ||
+				record.Error == "" {
+				t.Fatalf("Inconsistent request record: %#v (error: %s)", record.Request, record.Error)
+			}
+		case deadletter.KIND_ITEM:
+			itemRecords++
+			if record.Item.ProcessorIndex != 1 || record.Error != "rejected item" {
+				t.Fatalf("Inconsistent item record: %#v (error: %s)", record.Item, record.Error)
+			}
+		}
+	}
+	if reqRecords != 1 || itemRecords != 1 {
+		t.Fatalf("Inconsistent dead letters: requests: %d, items: %d", reqRecords, itemRecords)
+	}
+
+	// 在之后的运行中重新放入失败的请求。
+	atomic.StoreUint32(&recovered, 1)
+	reqs, err := deadletter.ReadRequests(path)
+	if err != nil {
+		t.Fatalf("An error occurs when reading dead-lettered requests: %s", err)
+	}
+	dataArgs.DeadLetterFile = ""
+	if err := sched.Init(genRequestArgs([]string{}, 1), dataArgs, genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ = http.NewRequest("GET", server.URL+"/gone", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	lock.Lock()
+	goneHits := hits["/gone"]
+	lock.Unlock()
+	if accepted, rejected := sched.Enqueue(reqs...); accepted != 0 {
+		t.Fatalf("It still can enqueue repeated request! (rejected: %v)", rejected)
+	}
+	if accepted, rejected := sched.Reinject(reqs...); accepted != 1 {
+		t.Fatalf("Couldn't reinject the request! (rejected: %v)", rejected)
+	}
+	waitForIdle(sched, 3, t)
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if hits["/gone"] != goneHits+1 {
+		t.Fatalf("The reinjected request was not downloaded! (hits: %v)", hits)
+	}
+}
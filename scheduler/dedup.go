@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/toolkit/dedup"
+)
+
+// 初始化 URL 去重存储
+// 已存在的去重存储会先被关闭
+func (sched *myScheduler) initDeduper(args DataArgs) error {
+	sched.closeDeduper()
+	deduper, err := dedup.New(dedup.Config{
+		Type:              args.DedupType,
+		Capacity:          args.DedupCapacity,
+		FalsePositiveRate: args.DedupFalsePositiveRate,
+		Dir:               args.DedupDir,
+	})
+	if err != nil {
+		return genErrorByError(err)
+	}
+	sched.urlMap = deduper
+	dedupType := args.DedupType
+	if dedupType == "" {
+		dedupType = dedup.TYPE_MEMORY
+	}
+	log.L().Sugar().Infof("-- URL map: type: %s", dedupType)
+	return nil
+}
+
+// 关闭 URL 去重存储
+func (sched *myScheduler) closeDeduper() {
+	if sched.urlMap == nil {
+		return
+	}
+	if err := sched.urlMap.Close(); err != nil {
+		log.L().Sugar().Warnf("Couldn't close the URL map: %s", err)
+	}
+}
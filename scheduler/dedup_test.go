@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/dedup"
+)
+
+func TestDedupArgs(t *testing.T) {
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.DedupType = "unknown"
+	if err := dataArgs.Check(); err == nil {
+		t.Fatal("No error when checking data args with unknown dedup type!")
+	}
+	dataArgs.DedupType = dedup.TYPE_BLOOM
+	for _, fpRate := range []float64{-0.1, 1} {
+		dataArgs.DedupFalsePositiveRate = fpRate
+		if err := dataArgs.Check(); err == nil {
+			t.Fatalf("No error when checking data args with false positive rate %v!", fpRate)
+		}
+	}
+}
+
+func TestDedupBackends(t *testing.T) {
+	server, hits := genRobotsServer(http.StatusNotFound, "")
+	defer server.Close()
+	for _, dedupType := range []string{dedup.TYPE_MEMORY, dedup.TYPE_BLOOM, dedup.TYPE_DISK} {
+		dataArgs := genDataArgs(10, 2, 1)
+		dataArgs.DedupType = dedupType
+		dataArgs.DedupDir = t.TempDir()
+		sched := NewScheduler()
+		err := sched.Init(genRequestArgs([]string{}, 1), dataArgs, genSimpleModuleArgs(2, 1, 1, t))
+		if err != nil {
+			t.Fatalf("An error occurs when initializing scheduler: %s (dedup type: %s)",
+				err, dedupType)
+		}
+		firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+		if err := sched.Start(firstHTTPReq); err != nil {
+			t.Fatalf("An error occurs when starting scheduler: %s (dedup type: %s)",
+				err, dedupType)
+		}
+		waitForIdle(sched, 3, t)
+		// 重复的请求应被忽略。
+		httpReq, _ := http.NewRequest("GET", server.URL+"/public", nil)
+		if sched.(*myScheduler).sendReq(module.NewRequest(httpReq, 1)) {
+			t.Fatalf("It still can send repeated request! (dedup type: %s)", dedupType)
+		}
+		summary := sched.Summary().Struct()
+		if err := sched.Stop(); err != nil {
+			t.Fatalf("An error occurs when stopping scheduler: %s (dedup type: %s)",
+				err, dedupType)
+		}
+		if summary.NumURL != 3 {
+			t.Fatalf("Inconsistent URL number: expected: %d, actual: %d (dedup type: %s)",
+				3, summary.NumURL, dedupType)
+		}
+	}
+	if result := hits(); result["/public"] != 3 || result["/private/a"] != 3 {
+		t.Fatalf("Some pages were crawled repeatedly or not crawled! (hits: %v)", result)
+	}
+}
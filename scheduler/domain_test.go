@@ -28,7 +28,18 @@ func TestGetPrimaryDomain(t *testing.T) {
 	if err == nil {
 		t.Fatal("It still can get primary domain for a empty host!")
 	}
-	host = "123.abc"
+	host = "foo.github.io:8080"
+	pd, err = getPrimaryDomain(host)
+	if err != nil {
+		t.Fatalf("An error occurs when getting primary domain: %s (host: %s)",
+			err, host)
+	}
+	expectedPD = "foo.github.io"
+	if pd != expectedPD {
+		t.Fatalf("Inconsistent primary domain: expected: %s, actual: %s",
+			expectedPD, pd)
+	}
+	host = "123.notatld"
 	_, err = getPrimaryDomain(host)
 	if err == nil {
 		t.Fatalf("It still can get primary domain for a unrecognized host %q!", host)
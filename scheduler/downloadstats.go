@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// DownloadSummaryStruct 代表下载情况的摘要类型。
+type DownloadSummaryStruct struct {
+	// 已分析的响应数
+	Responses uint64 `json:"responses"`
+	// 已读取的响应体字节总数
+	Bytes uint64 `json:"bytes"`
+	// 经过的重定向总数
+	Redirects uint64 `json:"redirects"`
+	// 响应体被截断的响应数
+	Truncated uint64 `json:"truncated"`
+	// 平均的首字节时长
+	AvgTTFB string `json:"avg_ttfb"`
+	// 平均的下载时长，包括读取响应体的时间
+	AvgDuration string `json:"avg_duration"`
+}
+
+// downloadStats 代表下载情况的统计，其方法都是并发安全的。
+type downloadStats struct {
+	responses uint64
+	bytes     uint64
+	redirects uint64
+	truncated uint64
+	// 首字节时长的总和，以纳秒表示
+	ttfb uint64
+	// 下载时长的总和，以纳秒表示
+	duration uint64
+}
+
+// record 用于统计一次已完成的下载。
+// 没有下载过程信息的响应不计入统计。
+func (stats *downloadStats) record(resp *module.Response) {
+	trace := resp.Trace()
+	if trace == nil {
+		return
+	}
+	if resp.Truncated() {
+		atomic.AddUint64(&stats.truncated, 1)
+	}
+	atomic.AddUint64(&stats.responses, 1)
+	atomic.AddUint64(&stats.bytes, uint64(trace.BytesRead()))
+	atomic.AddUint64(&stats.redirects, uint64(len(trace.Redirects)))
+	atomic.AddUint64(&stats.ttfb, uint64(trace.TTFB))
+	atomic.AddUint64(&stats.duration, uint64(trace.Duration()))
+}
+
+// reset 用于清空统计。
+func (stats *downloadStats) reset() {
+	atomic.StoreUint64(&stats.responses, 0)
+	atomic.StoreUint64(&stats.bytes, 0)
+	atomic.StoreUint64(&stats.redirects, 0)
+	atomic.StoreUint64(&stats.truncated, 0)
+	atomic.StoreUint64(&stats.ttfb, 0)
+	atomic.StoreUint64(&stats.duration, 0)
+}
+
+// getDownloadSummary 用于生成和返回下载情况的摘要信息。
+func getDownloadSummary(stats *downloadStats) DownloadSummaryStruct {
+	summary := DownloadSummaryStruct{
+		Responses:   atomic.LoadUint64(&stats.responses),
+		Bytes:       atomic.LoadUint64(&stats.bytes),
+		Redirects:   atomic.LoadUint64(&stats.redirects),
+		Truncated:   atomic.LoadUint64(&stats.truncated),
+		AvgTTFB:     time.Duration(0).String(),
+		AvgDuration: time.Duration(0).String(),
+	}
+	if summary.Responses > 0 {
+		summary.AvgTTFB = time.Duration(
+			atomic.LoadUint64(&stats.ttfb) / summary.Responses).String()
+		summary.AvgDuration = time.Duration(
+			atomic.LoadUint64(&stats.duration) / summary.Responses).String()
+	}
+	return summary
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+func (sched *myScheduler) Enqueue(reqs ...*module.Request) (accepted int, rejected map[string]string) {
+	return sched.enqueue(reqs, false)
+}
+
+// 向运行中的调度器放入请求
+// 参数 reinject 代表是否允许放入已处理过的 URL
+func (sched *myScheduler) enqueue(reqs []*module.Request, reinject bool) (accepted int, rejected map[string]string) {
+	rejected = map[string]string{}
+	status := sched.Status()
+	running := status == SCHED_STATUS_STARTED || status == SCHED_STATUS_PAUSING ||
+		status == SCHED_STATUS_PAUSED || status == SCHED_STATUS_RESUMING
+	for i, req := range reqs {
+		key := enqueueKey(i, req)
+		if !running {
+			rejected[key] = fmt.Sprintf("The scheduler is not running. (status: %s)",
+				GetStatusDescription(status))
+			continue
+		}
+		if reason := sched.trySendReq(req, reinject); reason != "" {
+			rejected[key] = reason
+			continue
+		}
+		accepted++
+	}
+	log.L().Sugar().Infof("Enqueued requests: accepted: %d, rejected: %d",
+		accepted, len(rejected))
+	return
+}
+
+// 获取请求在拒绝原因字典中的键
+// 请求无效时使用其序号
+func enqueueKey(index int, req *module.Request) string {
+	if req == nil || req.HTTPReq() == nil || req.HTTPReq().URL == nil {
+		return fmt.Sprintf("#%d", index+1)
+	}
+	return req.HTTPReq().URL.String()
+}
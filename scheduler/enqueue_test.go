@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+func TestEnqueue(t *testing.T) {
+	server, hits := genRobotsServer(http.StatusNotFound, "")
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{}, 1)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	genReq := func(url string, depth uint32) *module.Request {
+		httpReq, _ := http.NewRequest("GET", url, nil)
+		return module.NewRequest(httpReq, depth)
+	}
+	// 调度器启动前的请求都会被拒绝。
+	accepted, rejected := sched.Enqueue(genReq(server.URL+"/public", 0))
+	if accepted != 0 || len(rejected) != 1 {
+		t.Fatalf("It still can enqueue request before starting! (accepted: %d, rejected: %v)",
+			accepted, rejected)
+	}
+	seedReq, _ := http.NewRequest("GET", server.URL+"/public", nil)
+	if err := sched.Start(seedReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	accepted, rejected = sched.Enqueue(
+		genReq(server.URL+"/", 0),
+		genReq(server.URL+"/public", 0),
+		genReq("ftp://127.0.0.1/", 0),
+		genReq("http://other.com/", 0),
+		genReq(server.URL+"/deep", 2),
+		nil,
+	)
+	if accepted != 1 {
+		t.Fatalf("Inconsistent accepted number: expected: %d, actual: %d (rejected: %v)",
+			1, accepted, rejected)
+	}
+	expected := map[string]string{
+		server.URL + "/public": "repeated",
+		"ftp://127.0.0.1/":     "scheme",
+		"http://other.com/":    "scope",
+		server.URL + "/deep":   "depth",
+		"#6":                   "nil",
+	}
+	if len(rejected) != len(expected) {
+		t.Fatalf("Inconsistent rejected requests: %v", rejected)
+	}
+	for url, keyword := range expected {
+		if !strings.Contains(rejected[url], keyword) {
+			t.Fatalf("Inconsistent rejection reason: expected keyword: %q, actual: %q (URL: %s)",
+				keyword, rejected[url], url)
+		}
+	}
+	waitForIdle(sched, 3, t)
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	if result := hits(); result["/"] != 1 || result["/private/a"] != 1 {
+		t.Fatalf("The enqueued request was not crawled! (hits: %v)", result)
+	}
+}
@@ -1,10 +1,11 @@
 package scheduler
 
 import (
+	stderrors "errors"
+
 	"github.com/dokidokikoi/webcrawler/errors"
 	"github.com/dokidokikoi/webcrawler/log"
 	"github.com/dokidokikoi/webcrawler/module"
-	"github.com/dokidokikoi/webcrawler/toolkit/buffer"
 )
 
 // genError 用于生成爬虫错误值。
@@ -15,8 +16,7 @@ func genError(errMsg string) error {
 
 // genErrorByError 用于基于给定的错误值生成爬虫错误值。
 func genErrorByError(err error) error {
-	return errors.NewCrawlerError(errors.ERROR_TYPE_SCHEDULER,
-		err.Error())
+	return errors.NewCrawlerErrorBy(errors.ERROR_TYPE_SCHEDULER, err)
 }
 
 // genParameterError 用于生成爬虫参数错误值。
@@ -26,34 +26,22 @@ func genParameterError(errMsg string) error {
 }
 
 // sendError 用于向错误缓冲池发送错误值。
-func sendError(err error, mid module.MID, errorBufferPool buffer.Pool) bool {
+func (sched *myScheduler) sendError(err error, mid module.MID) bool {
+	return sched.sendErrorWith(err, errors.ErrorContext{MID: string(mid)})
+}
+
+// sendErrorWith 用于向错误缓冲池发送带有上下文的错误值。
+// 错误值会被转换为包装了原错误值的爬虫错误值，并被计入错误统计。
+func (sched *myScheduler) sendErrorWith(err error, ctx errors.ErrorContext) bool {
+	errorBufferPool := sched.errorBufferPool
 	if err == nil || errorBufferPool == nil || errorBufferPool.Closed() {
 		return false
 	}
-	var crawlerError errors.CrawlerError
-	var ok bool
-	crawlerError, ok = err.(errors.CrawlerError)
-	if !ok {
-		var moduleType module.Type
-		var errorType errors.ErrorType
-		ok, moduleType = module.GetType(mid)
-		if !ok {
-			errorType = errors.ERROR_TYPE_SCHEDULER
-		} else {
-			switch moduleType {
-			case module.TYPE_DOWNLOADER:
-				errorType = errors.ERROR_TYPE_DOWNLOADER
-			case module.TYPE_ANALYZER:
-				errorType = errors.ERROR_TYPE_ANALYZER
-			case module.TYPE_PIPELINE:
-				errorType = errors.ERROR_TYPE_PIPELINE
-			}
-		}
-		crawlerError = errors.NewCrawlerError(errorType, err.Error())
-	}
+	crawlerError := annotateError(err, ctx)
 	if errorBufferPool.Closed() {
 		return false
 	}
+	sched.errorStats.record(crawlerError)
 	go func(crawlerError errors.CrawlerError) {
 		if err := errorBufferPool.Put(crawlerError); err != nil {
 			log.L().Sugar().Warnln("The error buffer pool was closed. Ignore error sending.")
@@ -61,3 +49,81 @@ func sendError(err error, mid module.MID, errorBufferPool buffer.Pool) bool {
 	}(crawlerError)
 	return true
 }
+
+// annotateError 用于把错误值转换为带有上下文的爬虫错误值。
+// 错误类型由上下文中的组件 ID 决定。
+func annotateError(err error, ctx errors.ErrorContext) errors.CrawlerError {
+	errorType := errors.ERROR_TYPE_SCHEDULER
+	if ok, moduleType := module.GetType(module.MID(ctx.MID)); ok {
+		switch moduleType {
+		case module.TYPE_DOWNLOADER:
+			errorType = errors.ERROR_TYPE_DOWNLOADER
+		case module.TYPE_ANALYZER:
+			errorType = errors.ERROR_TYPE_ANALYZER
+		case module.TYPE_PIPELINE:
+			errorType = errors.ERROR_TYPE_PIPELINE
+		}
+	}
+	return errors.Annotate(err, errorType, ctx)
+}
+
+// downloadErrorContext 用于生成下载阶段的错误上下文。
+func downloadErrorContext(mid module.MID, req *module.Request) errors.ErrorContext {
+	ctx := errors.ErrorContext{MID: string(mid), Stage: errors.STAGE_DOWNLOAD}
+	if req != nil && req.Valid() {
+		ctx.URL = req.HTTPReq().URL.String()
+		ctx.Depth = req.Depth()
+	}
+	return ctx
+}
+
+// sendDownloadError 用于向错误缓冲池发送下载阶段的错误值。
+func (sched *myScheduler) sendDownloadError(err error, mid module.MID, req *module.Request) bool {
+	return sched.sendErrorWith(err, downloadErrorContext(mid, req))
+}
+
+// recordDownloadError 用于把下载阶段的错误值只计入错误统计，而不发送到错误缓冲池。
+// 像 404 这样常见的状态码不应淹没真正的错误。
+func (sched *myScheduler) recordDownloadError(err error, mid module.MID, req *module.Request) {
+	if err == nil {
+		return
+	}
+	sched.errorStats.record(annotateError(err, downloadErrorContext(mid, req)))
+}
+
+// sendAnalyzeError 用于向错误缓冲池发送分析阶段的错误值。
+func (sched *myScheduler) sendAnalyzeError(err error, mid module.MID, resp *module.Response) bool {
+	ctx := errors.ErrorContext{MID: string(mid), Stage: errors.STAGE_ANALYZE}
+	if resp != nil {
+		ctx.URL = resp.FinalURL()
+		ctx.Depth = resp.Depth()
+	}
+	return sched.sendErrorWith(err, ctx)
+}
+
+// sendPipelineError 用于向错误缓冲池发送条目处理阶段的错误值。
+// 条目所在页面的 URL 取自分析器记录的来源信息。
+func (sched *myScheduler) sendPipelineError(err error, mid module.MID, item module.Item) bool {
+	ctx := errors.ErrorContext{MID: string(mid), Stage: errors.STAGE_PIPELINE}
+	ctx.URL, _ = item[module.ITEM_KEY_PAGE_URL].(string)
+	return sched.sendErrorWith(err, ctx)
+}
+
+// genBadStatusError 用于生成响应状态码不符合预期的错误值。
+func genBadStatusError(statusCode int) error {
+	return &errors.StatusError{StatusCode: statusCode}
+}
+
+// badStatus 用于判断响应的状态码是否不符合预期，即 4xx 或 5xx。
+func badStatus(resp *module.Response) (int, bool) {
+	if resp == nil || resp.HTTPResp() == nil {
+		return 0, false
+	}
+	code := resp.HTTPResp().StatusCode
+	return code, code >= 400
+}
+
+// isFiltered 用于判断错误值是否代表有意的过滤。
+func isFiltered(err error) bool {
+	return stderrors.Is(err, errors.ErrFiltered)
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/module/local/pipeline"
+)
+
+func TestErrorChanStructured(t *testing.T) {
+	// 获取一个没有被监听的地址。
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("An error occurs when listening: %s", err)
+	}
+	refusedURL := "http://" + listener.Addr().String() + "/refused"
+	listener.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="%s">refused</a>`, refusedURL)
+	}))
+	defer server.Close()
+
+	moduleArgs := genSimpleModuleArgs(1, 1, 0, t)
+	p, _ := pipeline.New("P1", []module.ProcessItem{
+		func(item module.Item) (module.Item, error) {
+			return nil, fmt.Errorf("%w: not interesting", errors.ErrFiltered)
+		},
+	}, nil)
+	moduleArgs.Pipelines = []module.Pipeline{p}
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 1), genDataArgs(10, 2, 1), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	var lock sync.Mutex
+	var received []error
+	done := make(chan struct{})
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	go func() {
+		defer close(done)
+		for err := range sched.ErrorChan() {
+			lock.Lock()
+			received = append(received, err)
+			lock.Unlock()
+		}
+	}()
+	waitForIdle(sched, 3, t)
+	summary := sched.Summary().Struct().Errors
+	if summary.ByKind[string(errors.ErrRefused)] != 1 ||
+		summary.ByKind[string(errors.ErrFiltered)] != 1 ||
+		summary.ByType[string(errors.ERROR_TYPE_DOWNLOADER)] != 1 ||
+		summary.ByHost["127.0.0.1"] != summary.Total {
+		t.Fatalf("Inconsistent error summary: %#v", summary)
+	}
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	<-done
+	lock.Lock()
+	defer lock.Unlock()
+	var refused, filtered bool
+	for _, err := range received {
+		var ce errors.CrawlerError
+		if !stderrors.As(err, &ce) {
+			t.Fatalf("Unstructured error: %s", err)
+		}
+		ctx := ce.Context()
+		switch {
+		case stderrors.Is(err, errors.ErrRefused):
+			refused = true
+			if ce.Type() != errors.ERROR_TYPE_DOWNLOADER || ctx.Stage != errors.STAGE_DOWNLOAD ||
+				ctx.URL != refusedURL || ctx.Depth != 1 || ctx.MID == "" {
+				t.Fatalf("Inconsistent download error: %s (context: %#v)", err, ctx)
+			}
+		case stderrors.Is(err, errors.ErrFiltered):
+			filtered = true
+			var pe *module.ProcessorError
+			if !stderrors.As(err, &pe) || ctx.Stage != errors.STAGE_PIPELINE ||
+				ctx.URL != server.URL+"/" {
+				t.Fatalf("Inconsistent pipeline error: %s (context: %#v)", err, ctx)
+			}
+		}
+	}
+	if !refused || !filtered {
+		t.Fatalf("Missing errors! (refused: %v, filtered: %v, errors: %v)",
+			refused, filtered, received)
+	}
+}
@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	stderrors "errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+	"github.com/dokidokikoi/webcrawler/toolkit/domain"
+)
+
+// 摘要中保留的最近错误的数量
+const recentErrorNumber = 20
+
+// RecentErrorStruct 代表最近发生的错误的摘要类型。
+type RecentErrorStruct struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Kind    string    `json:"kind,omitempty"`
+	Stage   string    `json:"stage,omitempty"`
+	MID     string    `json:"mid,omitempty"`
+	URL     string    `json:"url,omitempty"`
+	Depth   uint32    `json:"depth"`
+	Message string    `json:"message"`
+}
+
+// ErrorSummaryStruct 代表错误统计的摘要类型。
+type ErrorSummaryStruct struct {
+	// 错误总数
+	Total uint64 `json:"total"`
+	// 按错误类型统计的错误数
+	ByType map[string]uint64 `json:"by_type,omitempty"`
+	// 按错误种类统计的错误数，无法归类的错误不计入
+	ByKind map[string]uint64 `json:"by_kind,omitempty"`
+	// 按组件 ID 统计的错误数，与组件无关的错误不计入
+	ByModule map[string]uint64 `json:"by_module,omitempty"`
+	// 按主机统计的错误数，与 URL 无关的错误不计入
+	ByHost map[string]uint64 `json:"by_host,omitempty"`
+	// 按响应状态码统计的错误数，只计入状态码不符合预期的错误
+	ByStatus map[int]uint64 `json:"by_status,omitempty"`
+	// 最近发生的错误，按时间由远及近排列
+	Recent []RecentErrorStruct `json:"recent,omitempty"`
+}
+
+// Same 用于判断当前的错误统计摘要与另一份是否相同。
+func (one *ErrorSummaryStruct) Same(another ErrorSummaryStruct) bool {
+	if one.Total != another.Total ||
+		!sameCounters(one.ByType, another.ByType) ||
+		!sameCounters(one.ByKind, another.ByKind) ||
+		!sameCounters(one.ByModule, another.ByModule) ||
+		!sameCounters(one.ByHost, another.ByHost) {
+		return false
+	}
+	if len(one.ByStatus) != len(another.ByStatus) {
+		return false
+	}
+	for code, n := range one.ByStatus {
+		if an, ok := another.ByStatus[code]; !ok || an != n {
+			return false
+		}
+	}
+	if len(one.Recent) != len(another.Recent) {
+		return false
+	}
+	for i, re := range one.Recent {
+		if re != another.Recent[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameCounters(one, another map[string]uint64) bool {
+	if len(one) != len(another) {
+		return false
+	}
+	for k, n := range one {
+		if an, ok := another[k]; !ok || an != n {
+			return false
+		}
+	}
+	return true
+}
+
+// errorStats 代表错误统计，其方法都是并发安全的。
+type errorStats struct {
+	lock     sync.Mutex
+	total    uint64
+	byType   map[string]uint64
+	byKind   map[string]uint64
+	byModule map[string]uint64
+	byHost   map[string]uint64
+	byStatus map[int]uint64
+	// 最近发生的错误的环形缓冲
+	recent []RecentErrorStruct
+	// 环形缓冲中下一个写入的位置
+	next int
+}
+
+// record 用于统计一个错误值。
+func (stats *errorStats) record(err errors.CrawlerError) {
+	ctx := err.Context()
+	re := RecentErrorStruct{
+		Time:    time.Now(),
+		Type:    string(err.Type()),
+		Kind:    string(err.Kind()),
+		Stage:   string(ctx.Stage),
+		MID:     ctx.MID,
+		URL:     ctx.URL,
+		Depth:   ctx.Depth,
+		Message: err.Error(),
+	}
+	var host string
+	if u, e := url.Parse(ctx.URL); e == nil {
+		host, _ = domain.Hostname(u.Host)
+	}
+	var se *errors.StatusError
+	hasStatus := stderrors.As(err, &se)
+
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	if stats.byType == nil {
+		stats.init()
+	}
+	stats.total++
+	stats.byType[re.Type]++
+	if re.Kind != "" {
+		stats.byKind[re.Kind]++
+	}
+	if re.MID != "" {
+		stats.byModule[re.MID]++
+	}
+	if host != "" {
+		stats.byHost[host]++
+	}
+	if hasStatus {
+		stats.byStatus[se.StatusCode]++
+	}
+	if len(stats.recent) < recentErrorNumber {
+		stats.recent = append(stats.recent, re)
+	} else {
+		stats.recent[stats.next] = re
+	}
+	stats.next = (stats.next + 1) % recentErrorNumber
+}
+
+// init 用于初始化各计数器，调用方需持有锁。
+func (stats *errorStats) init() {
+	stats.total = 0
+	stats.byType = map[string]uint64{}
+	stats.byKind = map[string]uint64{}
+	stats.byModule = map[string]uint64{}
+	stats.byHost = map[string]uint64{}
+	stats.byStatus = map[int]uint64{}
+	stats.recent = nil
+	stats.next = 0
+}
+
+// reset 用于清空统计。
+func (stats *errorStats) reset() {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	stats.init()
+}
+
+// getErrorSummary 用于生成和返回错误统计的摘要信息。
+func getErrorSummary(stats *errorStats) ErrorSummaryStruct {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	summary := ErrorSummaryStruct{Total: stats.total}
+	if stats.total == 0 {
+		return summary
+	}
+	summary.ByType = copyCounters(stats.byType)
+	summary.ByKind = copyCounters(stats.byKind)
+	summary.ByModule = copyCounters(stats.byModule)
+	summary.ByHost = copyCounters(stats.byHost)
+	if len(stats.byStatus) > 0 {
+		summary.ByStatus = make(map[int]uint64, len(stats.byStatus))
+		for code, n := range stats.byStatus {
+			summary.ByStatus[code] = n
+		}
+	}
+	summary.Recent = make([]RecentErrorStruct, 0, len(stats.recent))
+	if len(stats.recent) == recentErrorNumber {
+		summary.Recent = append(summary.Recent, stats.recent[stats.next:]...)
+		summary.Recent = append(summary.Recent, stats.recent[:stats.next]...)
+	} else {
+		summary.Recent = append(summary.Recent, stats.recent...)
+	}
+	return summary
+}
+
+func copyCounters(counters map[string]uint64) map[string]uint64 {
+	if len(counters) == 0 {
+		return nil
+	}
+	result := make(map[string]uint64, len(counters))
+	for k, n := range counters {
+		result[k] = n
+	}
+	return result
+}
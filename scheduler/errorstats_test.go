@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+)
+
+func TestErrorStats(t *testing.T) {
+	var stats errorStats
+	if summary := getErrorSummary(&stats); summary.Total != 0 || summary.Recent != nil {
+		t.Fatalf("Inconsistent empty error summary: %#v", summary)
+	}
+	ctx := errors.ErrorContext{
+		MID:   "D1|127.0.0.1:8080",
+		URL:   "http://Example.com:8080/a",
+		Stage: errors.STAGE_DOWNLOAD,
+	}
+	stats.record(errors.Annotate(genBadStatusError(503), errors.ERROR_TYPE_DOWNLOADER, ctx))
+	stats.record(errors.Annotate(genBadStatusError(503), errors.ERROR_TYPE_DOWNLOADER, ctx))
+	number := recentErrorNumber + 5
+	for i := 0; i < number; i++ {
+		stats.record(errors.Annotate(fmt.Errorf("error %d", i),
+			errors.ERROR_TYPE_SCHEDULER, errors.ErrorContext{}))
+	}
+	summary := getErrorSummary(&stats)
+	if summary.Total != uint64(number+2) {
+		t.Fatalf("Inconsistent error total: expected: %d, actual: %d", number+2, summary.Total)
+	}
+	if summary.ByType[string(errors.ERROR_TYPE_DOWNLOADER)] != 2 ||
+		summary.ByType[string(errors.ERROR_TYPE_SCHEDULER)] != uint64(number) {
+		t.Fatalf("Inconsistent error types: %v", summary.ByType)
+	}
+	if len(summary.ByKind) != 1 || summary.ByKind[string(errors.ErrBadStatus)] != 2 {
+		t.Fatalf("Inconsistent error kinds: %v", summary.ByKind)
+	}
+	if len(summary.ByModule) != 1 || summary.ByModule[ctx.MID] != 2 {
+		t.Fatalf("Inconsistent error modules: %v", summary.ByModule)
+	}
+	if len(summary.ByHost) != 1 || summary.ByHost["example.com"] != 2 {
+		t.Fatalf("Inconsistent error hosts: %v", summary.ByHost)
+	}
+	if len(summary.ByStatus) != 1 || summary.ByStatus[503] != 2 {
+		t.Fatalf("Inconsistent error statuses: %v", summary.ByStatus)
+	}
+	if len(summary.Recent) != recentErrorNumber {
+		t.Fatalf("Inconsistent recent error number: expected: %d, actual: %d",
+			recentErrorNumber, len(summary.Recent))
+	}
+	for i, re := range summary.Recent {
+		expected := fmt.Sprintf("crawler error: scheduler error: error %d", number-recentErrorNumber+i)
+		if re.Message != expected {
+			t.Fatalf("Inconsistent recent error: expected: %q, actual: %q (index: %d)",
+				expected, re.Message, i)
+		}
+	}
+	if !summary.Same(getErrorSummary(&stats)) {
+		t.Fatal("Inconsistent error summaries!")
+	}
+	stats.reset()
+	if summary := getErrorSummary(&stats); summary.Total != 0 || summary.Recent != nil {
+		t.Fatalf("Inconsistent error summary after reset: %#v", summary)
+	}
+}
+
+func TestBadStatusErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `<a href="/missing">missing</a>`)
+	}))
+	defer server.Close()
+
+	// 未启用重试时，状态码不符合预期的响应也应被统计
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 1), genDataArgs(10, 2, 1),
+		genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	summary := sched.Summary().Struct().Errors
+	// 状态码不符合预期的响应只被统计，不会被发送到错误通道
+	errorBufferPool := sched.(*myScheduler).errorBufferPool
+	for n := errorBufferPool.Total(); n > 0; n-- {
+		datum, _ := errorBufferPool.Get()
+		var se *errors.StatusError
+		if err, _ := datum.(error); stderrors.As(err, &se) {
+			t.Fatalf("The bad status was sent to the error channel: %s", err)
+		}
+	}
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	if len(summary.ByStatus) != 1 || summary.ByStatus[http.StatusNotFound] != 1 {
+		t.Fatalf("Inconsistent error statuses: %v", summary.ByStatus)
+	}
+	if summary.ByKind[string(errors.ErrBadStatus)] != 1 {
+		t.Fatalf("Inconsistent error kinds: %v", summary.ByKind)
+	}
+}
@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/toolkit/frontier"
+)
+
+// 创建待爬取队列
+func newFrontier(args FrontierArgs, capacity uint32) (frontier.Frontier, error) {
+	priority := args.Priority
+	if priority == nil {
+		var err error
+		if priority, err = frontier.GetPolicy(args.Policy); err != nil {
+			return nil, genParameterError(err.Error())
+		}
+	}
+	return frontier.New(capacity, priority, args.HostFair)
+}
+
+// 初始化待爬取队列
+// 队列的容量为请求缓冲器的容量与最大数量之积
+func (sched *myScheduler) initFrontier(args FrontierArgs, dataArgs DataArgs) error {
+	if sched.frontier != nil && !sched.frontier.Closed() {
+		sched.frontier.Close()
+	}
+	f, err := newFrontier(args, dataArgs.ReqBufferCap*dataArgs.ReqMaxBufferNumber)
+	if err != nil {
+		return err
+	}
+	sched.frontier = f
+	sched.frontierArgs = args
+	log.L().Sugar().Infof("-- Frontier: policy: %s, host fair: %v, cap: %d",
+		args.policyName(), args.HostFair, f.Cap())
+	return nil
+}
+
+// FrontierSummaryStruct 代表待爬取队列的摘要类型。
+type FrontierSummaryStruct struct {
+	Policy   string `json:"policy"`
+	HostFair bool   `json:"host_fair"`
+	Cap      uint32 `json:"cap"`
+	Total    uint64 `json:"total"`
+}
+
+// getFrontierSummary 用于生成和返回待爬取队列的摘要信息。
+func getFrontierSummary(f frontier.Frontier, args FrontierArgs) FrontierSummaryStruct {
+	return FrontierSummaryStruct{
+		Policy:   args.policyName(),
+		HostFair: args.HostFair,
+		Cap:      f.Cap(),
+		Total:    f.Total(),
+	}
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/frontier"
+)
+
+func TestInitFrontier(t *testing.T) {
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Frontier.Policy = "unknown"
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err == nil {
+		t.Fatal("No error when initializing scheduler with unknown frontier policy!")
+	}
+	requestArgs.Frontier.Policy = frontier.POLICY_BREADTH_FIRST
+	requestArgs.Frontier.HostFair = true
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	expected := FrontierSummaryStruct{
+		Policy:   frontier.POLICY_BREADTH_FIRST,
+		HostFair: true,
+		Cap:      10 * 2,
+	}
+	if summary := sched.Summary().Struct().Frontier; summary != expected {
+		t.Fatalf("Inconsistent frontier summary: expected: %#v, actual: %#v",
+			expected, summary)
+	}
+}
+
+func TestCustomPriority(t *testing.T) {
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Frontier.Policy = "unknown"
+	requestArgs.Frontier.Priority = func(req *module.Request) float64 {
+		if strings.HasSuffix(req.HTTPReq().URL.Path, "/sitemap") {
+			return 1
+		}
+		return 0
+	}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if policy := sched.Summary().Struct().Frontier.Policy; policy != "custom" {
+		t.Fatalf("Inconsistent frontier policy: expected: %q, actual: %q",
+			"custom", policy)
+	}
+	mySched := sched.(*myScheduler)
+	for _, url := range []string{"http://a.com/page", "http://a.com/sitemap"} {
+		httpReq, _ := http.NewRequest("GET", url, nil)
+		if err := mySched.frontier.Put(module.NewRequest(httpReq, 0)); err != nil {
+			t.Fatalf("An error occurs when putting request: %s", err)
+		}
+	}
+	req, _ := mySched.frontier.Get()
+	if url := req.HTTPReq().URL.String(); url != "http://a.com/sitemap" {
+		t.Fatalf("Inconsistent request: expected: %s, actual: %s",
+			"http://a.com/sitemap", url)
+	}
+}
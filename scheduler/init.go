@@ -1,7 +1,9 @@
 package scheduler
 
 import (
-	cmap "github.com/dokidokikoi/go-cmap"
+	"sync"
+	"sync/atomic"
+
 	"github.com/dokidokikoi/webcrawler/log"
 	"github.com/dokidokikoi/webcrawler/module"
 )
@@ -44,6 +46,13 @@ func (sched *myScheduler) Init(
 	}
 	log.L().Sugar().Infof("Module argments are vaild.")
 
+	// 初始化中途失败时关闭已打开的存储，以免它们占用的文件无法被释放
+	defer func() {
+		if err != nil {
+			sched.closeStores()
+		}
+	}()
+
 	// 初始化内部字段
 	log.L().Sugar().Infof("Initialize scheduler's fields...")
 	if sched.registrar == nil {
@@ -53,16 +62,38 @@ func (sched *myScheduler) Init(
 	}
 	sched.maxDepth = reqArgs.MaxDepth
 	log.L().Sugar().Infof("-- Max depth: %d", sched.maxDepth)
-	sched.acceptedDomainMap, _ = cmap.NewConcurrentMap(1, nil)
-	for _, domain := range reqArgs.AcceptedDomains {
-		sched.acceptedDomainMap.Put(domain, struct{}{})
+	sched.initScope(reqArgs)
+	if err = sched.initHostLimiter(reqArgs.Politeness, reqArgs.Robots.Enabled); err != nil {
+		return err
+	}
+	sched.initRobots(reqArgs.Robots)
+	sched.initNormalizer(reqArgs.Canonical)
+	if err = sched.initURLFilter(reqArgs.URLRules); err != nil {
+		return err
+	}
+	sched.initRetrier(reqArgs.Retry)
+	sched.downloadTimeout = reqArgs.Timeout
+	log.L().Sugar().Infof("-- Download timeout: %s", sched.downloadTimeout)
+	sched.initBodyLimiter(reqArgs.BodyLimit)
+	if err = sched.initDeduper(dataArgs); err != nil {
+		return err
+	}
+	sched.pending = newRequestSet()
+	sched.pendingResps = sync.Map{}
+	sched.downloadStats.reset()
+	sched.errorStats.reset()
+	sched.initCheckpoint(dataArgs)
+	if err = sched.initDeadLetter(dataArgs); err != nil {
+		return err
+	}
+	if err = sched.initFrontier(reqArgs.Frontier, dataArgs); err != nil {
+		return err
 	}
-	log.L().Sugar().Infof("-- Accepted primary domain: %v", reqArgs.AcceptedDomains)
-	sched.urlMap, _ = cmap.NewConcurrentMap(16, nil)
-	log.L().Sugar().Infof("-- URL map: length: %d, concurrency: %d",
-		sched.urlMap.Len(), sched.urlMap.Concurrency())
 	sched.initBufferPool(dataArgs)
+	sched.initWorkers(dataArgs)
 	sched.resetContext()
+	sched.gate = newPauseGate()
+	atomic.StoreUint32(&sched.draining, 0)
 	sched.summary = newSchedSummary(reqArgs, dataArgs, moduleArgs, sched)
 
 	// 注册组件
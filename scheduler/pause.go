@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"sync"
+
+	"github.com/dokidokikoi/webcrawler/log"
+)
+
+// 暂停闸门，用于在调度器暂停时阻止工作协程从缓冲池中取出数据
+type pauseGate struct {
+	// 处于开启状态时为已关闭的通道，暂停时为未关闭的通道
+	ch chan struct{}
+	// 已进入闸门且尚未离开的数量
+	active int
+	// 暂停时用于等待已进入闸门的全部离开，会在 active 变为 0 时被关闭
+	idle chan struct{}
+	lock sync.Mutex
+}
+
+func newPauseGate() *pauseGate {
+	ch := make(chan struct{})
+	close(ch)
+	return &pauseGate{ch: ch}
+}
+
+// 判断给定的通道是否已关闭
+func chanClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait 用于等待闸门开启。
+// 若 done 先被关闭，则返回 false。
+func (gate *pauseGate) wait(done <-chan struct{}) bool {
+	gate.lock.Lock()
+	ch := gate.ch
+	gate.lock.Unlock()
+	select {
+	case <-ch:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// enter 用于等待闸门开启并进入闸门。
+// 若 done 先被关闭，则返回 false，否则处理完毕后必须调用 leave。
+func (gate *pauseGate) enter(done <-chan struct{}) bool {
+	for {
+		gate.lock.Lock()
+		ch := gate.ch
+		if chanClosed(ch) {
+			gate.active++
+			gate.lock.Unlock()
+			return true
+		}
+		gate.lock.Unlock()
+		select {
+		case <-ch:
+		case <-done:
+			return false
+		}
+	}
+}
+
+// leave 用于离开闸门。
+func (gate *pauseGate) leave() {
+	gate.lock.Lock()
+	defer gate.lock.Unlock()
+	gate.active--
+	if gate.active == 0 && gate.idle != nil {
+		close(gate.idle)
+		gate.idle = nil
+	}
+}
+
+// pause 用于关闭闸门，并等待所有已进入闸门的处理完毕。
+// 闸门会被立即关闭，若 done 在等待期间被关闭，则不再等待并返回 false。
+func (gate *pauseGate) pause(done <-chan struct{}) bool {
+	gate.lock.Lock()
+	if chanClosed(gate.ch) {
+		gate.ch = make(chan struct{})
+	}
+	if gate.active == 0 {
+		gate.lock.Unlock()
+		return true
+	}
+	if gate.idle == nil {
+		gate.idle = make(chan struct{})
+	}
+	idle := gate.idle
+	gate.lock.Unlock()
+	select {
+	case <-idle:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// resume 用于开启闸门。
+func (gate *pauseGate) resume() {
+	gate.lock.Lock()
+	defer gate.lock.Unlock()
+	if !chanClosed(gate.ch) {
+		close(gate.ch)
+	}
+}
+
+func (sched *myScheduler) Pause() (err error) {
+	log.L().Sugar().Info("Pause scheduler...")
+	// 检查状态
+	log.L().Sugar().Info("Check status for pause...")
+	var oldStatus Status
+	oldStatus, err = sched.checkAndSetStatus(SCHED_STATUS_PAUSING)
+	if err != nil {
+		return
+	}
+	defer func() {
+		sched.statusLock.Lock()
+		// 等待期间调度器可能已被停止，此时不再改变状态
+		if sched.status == SCHED_STATUS_PAUSING {
+			if err != nil {
+				sched.status = oldStatus
+			} else {
+				sched.status = SCHED_STATUS_PAUSED
+			}
+		} else if err == nil {
+			err = genError("the scheduler was stopped while pausing")
+		}
+		sched.statusLock.Unlock()
+	}()
+	// 等待正在处理的数据处理完毕，调度器被停止时不再等待
+	if !sched.gate.pause(sched.ctx.Done()) {
+		return genError("the scheduler was stopped while pausing")
+	}
+	log.L().Sugar().Info("Scheduler has been paused.")
+	return nil
+}
+
+func (sched *myScheduler) Resume() (err error) {
+	log.L().Sugar().Info("Resume scheduler...")
+	// 检查状态
+	log.L().Sugar().Info("Check status for resume...")
+	var oldStatus Status
+	oldStatus, err = sched.checkAndSetStatus(SCHED_STATUS_RESUMING)
+	defer func() {
+		sched.statusLock.Lock()
+		if err != nil {
+			sched.status = oldStatus
+		} else {
+			sched.status = SCHED_STATUS_STARTED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil {
+		return
+	}
+	sched.gate.resume()
+	log.L().Sugar().Info("Scheduler has been resumed.")
+	return nil
+}
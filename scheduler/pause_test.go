@@ -0,0 +1,225 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPauseGate(t *testing.T) {
+	gate := newPauseGate()
+	done := make(chan struct{})
+	if !gate.wait(done) {
+		t.Fatal("Couldn't pass the open gate!")
+	}
+	if !gate.enter(done) {
+		t.Fatal("Couldn't enter the open gate!")
+	}
+	paused := make(chan struct{})
+	go func() {
+		gate.pause(make(chan struct{}))
+		close(paused)
+	}()
+	select {
+	case <-paused:
+		t.Fatal("The gate was paused before the entered one left!")
+	case <-time.After(50 * time.Millisecond):
+	}
+	gate.leave()
+	<-paused
+	entered := make(chan bool, 1)
+	go func() {
+		entered <- gate.enter(make(chan struct{}))
+	}()
+	select {
+	case <-entered:
+		t.Fatal("It still can enter the paused gate!")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(done)
+	if gate.wait(done) {
+		t.Fatal("It still can pass the paused gate!")
+	}
+	if gate.enter(done) {
+		t.Fatal("It still can enter the paused gate after done!")
+	}
+	gate.resume()
+	if !<-entered {
+		t.Fatal("Couldn't enter the resumed gate!")
+	}
+	gate.leave()
+	if !gate.wait(make(chan struct{})) || !gate.enter(make(chan struct{})) {
+		t.Fatal("Couldn't enter the resumed gate!")
+	}
+	// 等待已进入闸门的处理完毕时，可以被 done 中断。
+	stop := make(chan struct{})
+	result := make(chan bool, 1)
+	go func() {
+		result <- gate.pause(stop)
+	}()
+	close(stop)
+	if <-result {
+		t.Fatal("The gate was paused before the entered one left!")
+	}
+	if gate.wait(done) {
+		t.Fatal("The gate should be closed even if the waiting is interrupted!")
+	}
+	gate.leave()
+	if !gate.pause(make(chan struct{})) {
+		t.Fatal("Couldn't pause the gate without entered ones!")
+	}
+}
+
+func TestStopWhilePausing(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 1), genDataArgs(10, 2, 1),
+		genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	mySched := sched.(*myScheduler)
+	for i := 0; mySched.downloadWorkers.Active() == 0; i++ {
+		if i >= 300 {
+			t.Fatal("Timeout when waiting for the download!")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 下载一直不结束，暂停会一直等待。
+	paused := make(chan error, 1)
+	go func() {
+		paused <- sched.Pause()
+	}()
+	for i := 0; sched.Status() != SCHED_STATUS_PAUSING; i++ {
+		if i >= 300 {
+			t.Fatal("Timeout when waiting for pausing!")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- sched.Stop()
+	}()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("An error occurs when stopping a pausing scheduler: %s", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timeout when stopping a pausing scheduler!")
+	}
+	if err := <-paused; err == nil {
+		t.Fatal("No error when the scheduler was stopped while pausing!")
+	}
+	if status := sched.Status(); status != SCHED_STATUS_STOPPED {
+		t.Fatalf("Inconsistent status: expected: %q, actual: %q",
+			GetStatusDescription(SCHED_STATUS_STOPPED), GetStatusDescription(status))
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	var hits int32
+	pageNumber := 20
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+		for i := 0; i < pageNumber; i++ {
+			fmt.Fprintf(w, `<a href="/page%d">page%d</a>`, i, i)
+		}
+	}))
+	defer server.Close()
+
+	requestArgs := genRequestArgs([]string{}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.Pause(); err == nil {
+		t.Fatal("It still can pause a scheduler not started!")
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	// 等待首页分析完毕。
+	mySched := sched.(*myScheduler)
+	for i := 0; mySched.urlMap.Len() < uint64(pageNumber+1); i++ {
+		if i >= 300 {
+			t.Fatal("Timeout when waiting for the first page!")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	if status := sched.Status(); status != SCHED_STATUS_PAUSED {
+		t.Fatalf("Inconsistent status: expected: %q, actual: %q",
+			GetStatusDescription(SCHED_STATUS_PAUSED), GetStatusDescription(status))
+	}
+	if err := sched.Pause(); err == nil {
+		t.Fatal("It still can pause a paused scheduler!")
+	}
+	// 等待已放入缓冲池的数据就位，此后每个请求要么已被下载，
+	// 要么仍在缓冲池中，要么由下载工作协程持有并等待恢复。
+	pausedHits := atomic.LoadInt32(&hits)
+	held := uint64(mySched.downloadWorkers.Number())
+	var queued uint64
+	for i := 0; ; i++ {
+		queued = sched.Summary().Struct().Frontier.Total
+		total := uint64(pausedHits) + queued
+		if total <= uint64(pageNumber+1) && total+held >= uint64(pageNumber+1) {
+			break
+		}
+		if i >= 100 {
+			t.Fatalf("The queued requests were not kept: hits: %d, queued: %d",
+				pausedHits, queued)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if queued == 0 {
+		t.Fatal("No request was queued when the scheduler was paused!")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if current := atomic.LoadInt32(&hits); current != pausedHits {
+		t.Fatalf("The scheduler still downloads when paused: before: %d, after: %d",
+			pausedHits, current)
+	}
+	if err := sched.Resume(); err != nil {
+		t.Fatalf("An error occurs when resuming scheduler: %s", err)
+	}
+	if err := sched.Resume(); err == nil {
+		t.Fatal("It still can resume a running scheduler!")
+	}
+	waitForIdle(sched, 5, t)
+	if current := atomic.LoadInt32(&hits); current != int32(pageNumber+1) {
+		t.Fatalf("Inconsistent hits: expected: %d, actual: %d",
+			pageNumber+1, current)
+	}
+	if err := sched.Pause(); err != nil {
+		t.Fatalf("An error occurs when pausing scheduler: %s", err)
+	}
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping a paused scheduler: %s", err)
+	}
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/limiter"
+)
+
+// 初始化主机访问限制器
+// 参数中没有任何限制项且未遵守 robots.txt 时不会创建限制器
+// 遵守 robots.txt 时总会创建限制器，以便应用 Crawl-delay
+func (sched *myScheduler) initHostLimiter(args PolitenessArgs, obeyRobots bool) error {
+	sched.hostLimiter = nil
+	sched.limitByPrimaryDomain = args.ByPrimaryDomain
+	config := limiter.Config{
+		RequestsPerSecond: args.RequestsPerSecond,
+		MinDelay:          args.MinDelay,
+		MaxInFlight:       args.MaxInFlight,
+	}
+	if !config.Enabled() && !obeyRobots {
+		log.L().Sugar().Info("-- Host limiter: disabled")
+		return nil
+	}
+	hostLimiter, err := limiter.NewHostLimiter(config)
+	if err != nil {
+		return genErrorByError(err)
+	}
+	sched.hostLimiter = hostLimiter
+	log.L().Sugar().Infof("-- Host limiter: %+v", args)
+	return nil
+}
+
+// 获取给定请求在主机访问限制器中的键
+func (sched *myScheduler) hostLimiterKey(httpReq *http.Request) string {
+	host := strings.ToLower(httpReq.Host)
+	if host == "" && httpReq.URL != nil {
+		host = strings.ToLower(httpReq.URL.Host)
+	}
+	if sched.limitByPrimaryDomain {
+		if pd, err := getPrimaryDomain(host); err == nil {
+			return pd
+		}
+	}
+	return host
+}
+
+// 等待直到允许向请求的主机发送请求
+// 未启用限制时立即返回
+// 若调度器在等待期间停止，则第二个结果值为 false
+func (sched *myScheduler) acquireHost(httpReq *http.Request) (release func(), ok bool) {
+	if sched.hostLimiter == nil || httpReq == nil {
+		return func() {}, true
+	}
+	release, err := sched.hostLimiter.Acquire(sched.ctx, sched.hostLimiterKey(httpReq))
+	if err != nil {
+		return nil, false
+	}
+	return release, true
+}
+
+// 尝试立即获得向请求的主机发送请求的许可
+// 未启用限制时总能获得许可
+// 无法立即获得时释放函数为 nil，第二个结果值代表建议的等待时间
+func (sched *myScheduler) tryAcquireHost(httpReq *http.Request) (release func(), wait time.Duration) {
+	if sched.hostLimiter == nil || httpReq == nil {
+		return func() {}, 0
+	}
+	return sched.hostLimiter.TryAcquire(sched.hostLimiterKey(httpReq))
+}
+
+// 记录一个因主机暂时不允许发送请求而被推迟的请求，以便在摘要中体现
+// 请求被放回队列时必须调用返回的函数
+func (sched *myScheduler) deferHost(httpReq *http.Request) (done func()) {
+	if sched.hostLimiter == nil || httpReq == nil {
+		return func() {}
+	}
+	return sched.hostLimiter.Defer(sched.hostLimiterKey(httpReq))
+}
+
+// 使主机访问许可在响应体被关闭时才被释放，这样读取响应体的时间也受到限制
+// 响应为 nil 时会立即释放
+func holdHost(resp *module.Response, release func()) *module.Response {
+	if resp == nil || resp.HTTPResp() == nil || resp.HTTPResp().Body == nil {
+		release()
+		return resp
+	}
+	httpResp := resp.HTTPResp()
+	httpResp.Body = &hostBody{httpResp.Body, release}
+	return resp
+}
+
+// 关闭时释放主机访问许可的响应体
+type hostBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *hostBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,215 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/buffer"
+)
+
+func TestPolitenessArgs(t *testing.T) {
+	requestArgs := genRequestArgs([]string{}, 0)
+	requestArgs.Politeness.MinDelay = -time.Second
+	if err := requestArgs.Check(); err == nil {
+		t.Fatal("No error when check request arguments with negative min delay!")
+	}
+	requestArgs.Politeness = PolitenessArgs{RequestsPerSecond: -1}
+	if err := requestArgs.Check(); err == nil {
+		t.Fatal("No error when check request arguments with negative rate!")
+	}
+	one := genRequestArgs([]string{}, 0)
+	another := genRequestArgs([]string{}, 0)
+	another.Politeness.MaxInFlight = 2
+	if one.Same(&another) {
+		t.Fatal("Same request arguments with different politeness arguments!")
+	}
+}
+
+func TestPolitenessInCrawl(t *testing.T) {
+	var handling, maxHandling int32
+	var lock sync.Mutex
+	var hits []time.Time
+	pageNumber := 4
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&handling, 1)
+		defer atomic.AddInt32(&handling, -1)
+		if current > atomic.LoadInt32(&maxHandling) {
+			atomic.StoreInt32(&maxHandling, current)
+		}
+		lock.Lock()
+		hits = append(hits, time.Now())
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+		for i := 0; i < pageNumber; i++ {
+			fmt.Fprintf(w, `<a href="/page%d">page%d</a>`, i, i)
+		}
+	}))
+	defer server.Close()
+
+	minDelay := 50 * time.Millisecond
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Politeness = PolitenessArgs{
+		MinDelay:    minDelay,
+		MaxInFlight: 1,
+	}
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.DownloaderWorkerNumber = 4
+	moduleArgs := genSimpleModuleArgs(4, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	summary := sched.Summary().Struct()
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	if maxHandling > 1 {
+		t.Fatalf("Too many requests in flight: expected: <= %d, actual: %d",
+			1, maxHandling)
+	}
+	if len(hits) != pageNumber+1 {
+		t.Fatalf("Inconsistent hit number: expected: %d, actual: %d",
+			pageNumber+1, len(hits))
+	}
+	for i := 1; i < len(hits); i++ {
+		// 允许少量的计时误差。
+		if interval := hits[i].Sub(hits[i-1]); interval < minDelay-5*time.Millisecond {
+			t.Fatalf("The min delay was not respected: expected: >= %s, actual: %s",
+				minDelay, interval)
+		}
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	hs, ok := summary.Politeness[host]
+	if !ok {
+		t.Fatalf("Not found host %q in politeness summary: %#v", host, summary.Politeness)
+	}
+	if hs.Acquired != uint64(pageNumber+1) {
+		t.Fatalf("Inconsistent acquired number: expected: %d, actual: %d",
+			pageNumber+1, hs.Acquired)
+	}
+}
+
+func TestPolitenessNotBlockingWorkers(t *testing.T) {
+	var lock sync.Mutex
+	hits := map[string]time.Time{}
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits["other"] = time.Now()
+		lock.Unlock()
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer other.Close()
+	pageNumber := 3
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path] = time.Now()
+		lock.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+		// 同一主机的请求排在另一主机的请求之前。
+		for i := 0; i < pageNumber; i++ {
+			fmt.Fprintf(w, `<a href="/page%d">page%d</a>`, i, i)
+		}
+		fmt.Fprintf(w, `<a href="%s/">other</a>`, other.URL)
+	}))
+	defer server.Close()
+
+	minDelay := 300 * time.Millisecond
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Politeness = PolitenessArgs{MinDelay: minDelay}
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.DownloaderWorkerNumber = 1
+	moduleArgs := genSimpleModuleArgs(1, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(hits) != pageNumber+2 {
+		t.Fatalf("Inconsistent hit number: expected: %d, actual: %d", pageNumber+2, len(hits))
+	}
+	// 等待主机许可的请求不应占用唯一的下载工作协程。
+	if elapsed := hits["other"].Sub(hits["/"]); elapsed >= minDelay {
+		t.Fatalf("The request to another host was blocked: elapsed: %s", elapsed)
+	}
+}
+
+func TestHoldHost(t *testing.T) {
+	var released int32
+	release := func() { atomic.AddInt32(&released, 1) }
+	if resp := holdHost(nil, release); resp != nil || atomic.LoadInt32(&released) != 1 {
+		t.Fatalf("The host should be released at once for nil response: %d", released)
+	}
+	httpResp := &http.Response{Body: io.NopCloser(strings.NewReader("body"))}
+	resp := holdHost(module.NewResponse(httpResp, 0), release)
+	io.ReadAll(resp.HTTPResp().Body)
+	if n := atomic.LoadInt32(&released); n != 1 {
+		t.Fatalf("The host was released before the body was closed: %d", n)
+	}
+	resp.HTTPResp().Body.Close()
+	if n := atomic.LoadInt32(&released); n != 2 {
+		t.Fatalf("The host was not released after the body was closed: %d", n)
+	}
+}
+
+func TestDroppedRespReleasesHost(t *testing.T) {
+	var released int32
+	release := func() { atomic.AddInt32(&released, 1) }
+	newResp := func() *module.Response {
+		httpResp := &http.Response{Body: io.NopCloser(strings.NewReader("body"))}
+		return holdHost(module.NewResponse(httpResp, 0), release)
+	}
+	// 响应缓冲池已关闭时，响应会被立即丢弃。
+	pool, _ := buffer.NewPool(1, 1)
+	pool.Close()
+	if sendResp(newResp(), pool) {
+		t.Fatal("It still can send response to a closed pool!")
+	}
+	if n := atomic.LoadInt32(&released); n != 1 {
+		t.Fatalf("The host was not released for the dropped response: %d", n)
+	}
+	// 响应缓冲池在放入期间被关闭时，响应也会被丢弃。
+	pool, _ = buffer.NewPool(1, 1)
+	pool.Put(newResp())
+	if !sendResp(newResp(), pool) {
+		t.Fatal("Couldn't send response to an open pool!")
+	}
+	pool.Close()
+	for i := 0; atomic.LoadInt32(&released) != 2; i++ {
+		if i >= 100 {
+			t.Fatalf("The host was not released for the dropped response: %d",
+				atomic.LoadInt32(&released))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// 首次重试前默认等待的时间
+const defaultRetryBaseDelay = time.Second
+
+// 重试前默认等待的最长时间
+const defaultRetryMaxDelay = time.Minute
+
+// 默认需要重试的响应状态码
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// 下载重试器
+type retrier struct {
+	// 每个请求最多的下载次数
+	maxAttempts uint32
+	// 首次重试前等待的时间
+	baseDelay time.Duration
+	// 重试前等待的最长时间
+	maxDelay time.Duration
+	// 随机减少等待时间的最大比例
+	jitter float64
+	// 需要重试的响应状态码
+	statusCodes map[int]struct{}
+	// 用于生成抖动的随机数生成器
+	rand     *rand.Rand
+	randLock sync.Mutex
+}
+
+// 创建下载重试器，不需要重试时返回 nil
+func newRetrier(args RetryArgs) *retrier {
+	if args.MaxAttempts <= 1 {
+		return nil
+	}
+	r := &retrier{
+		maxAttempts: args.MaxAttempts,
+		baseDelay:   args.BaseDelay,
+		maxDelay:    args.MaxDelay,
+		jitter:      args.Jitter,
+		statusCodes: map[int]struct{}{},
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if r.baseDelay == 0 {
+		r.baseDelay = defaultRetryBaseDelay
+	}
+	if r.maxDelay == 0 {
+		r.maxDelay = defaultRetryMaxDelay
+	}
+	statusCodes := args.StatusCodes
+	if statusCodes == nil {
+		statusCodes = defaultRetryStatusCodes
+	}
+	for _, code := range statusCodes {
+		r.statusCodes[code] = struct{}{}
+	}
+	return r
+}
+
+// 初始化下载重试器
+func (sched *myScheduler) initRetrier(args RetryArgs) {
+	sched.retrier = newRetrier(args)
+	if sched.retrier == nil {
+		log.L().Sugar().Info("-- Retry: disabled")
+		return
+	}
+	log.L().Sugar().Infof("-- Retry: max attempts: %d, base delay: %s, max delay: %s, jitter: %v",
+		sched.retrier.maxAttempts, sched.retrier.baseDelay, sched.retrier.maxDelay, sched.retrier.jitter)
+}
+
+// 判断下载结果是否需要重试
+// 结果值 retryAfter 代表响应要求的等待时间，没有要求时为 0
+func (r *retrier) retryable(resp *module.Response, err error) (ok bool, retryAfter time.Duration) {
+	if err != nil {
+		return isRetryableError(err), 0
+	}
+	if resp == nil || resp.HTTPResp() == nil {
+		return false, 0
+	}
+	httpResp := resp.HTTPResp()
+	if _, ok := r.statusCodes[httpResp.StatusCode]; !ok {
+		return false, 0
+	}
+	return true, parseRetryAfter(httpResp.Header.Get("Retry-After"), time.Now())
+}
+
+// 获取第 attempt 次下载失败后重试前等待的时间
+func (r *retrier) delay(attempt uint32, retryAfter time.Duration) time.Duration {
+	delay := r.maxDelay
+	if shift := attempt - 1; shift < 32 {
+		if d := r.baseDelay << shift; d > 0 && d < r.maxDelay {
+			delay = d
+		}
+	}
+	if r.jitter > 0 {
+		r.randLock.Lock()
+		delay -= time.Duration(float64(delay) * r.jitter * r.rand.Float64())
+		r.randLock.Unlock()
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// 判断下载错误是否是暂时性的
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// 解析 Retry-After 头，它可以是秒数或 HTTP 日期
+// 无法解析时返回 0
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// 按需重试下载失败的请求
+// 结果值代表下载结果是否已被处理，为 false 时应按常规流程处理
+func (sched *myScheduler) retryIfNeeded(req *module.Request, resp *module.Response, err error, mid module.MID) bool {
+	r := sched.retrier
+	if r == nil {
+		return false
+	}
+	ok, retryAfter := r.retryable(resp, err)
+	if !ok {
+		return false
+	}
+	if resp != nil && resp.HTTPResp() != nil && resp.HTTPResp().Body != nil {
+		resp.HTTPResp().Body.Close()
+	}
+	reason := err
+	if reason == nil {
+		reason = genBadStatusError(resp.HTTPResp().StatusCode)
+	}
+	attempt := req.Attempt()
+	if attempt >= r.maxAttempts {
+		sched.pending.Remove(pendingKey(req))
+		err := fmt.Errorf("gave up the request after %d attempts: %w (URL: %s)",
+			attempt, reason, req.HTTPReq().URL)
+		sched.sendDownloadError(err, mid, req)
+		sched.deadLetterRequest(req, reason)
+		return true
+	}
+	delay := r.delay(attempt, retryAfter)
+	log.L().Sugar().Warnf("Retry the request in %s: %s (URL: %s, attempt: %d)",
+		delay, reason, req.HTTPReq().URL, attempt)
+	atomic.AddInt64(&sched.retrying, 1)
+	// 定时器可能在调度器停止并重新初始化之后才触发，因此使用当前的上下文和队列
+	ctx, frontier := sched.ctx, sched.frontier
+	time.AfterFunc(delay, func() {
+		defer atomic.AddInt64(&sched.retrying, -1)
+		// 调度器停止后，请求仍会保留在待处理集合中以便恢复
+		sched.requeue(ctx, frontier, req)
+	})
+	return true
+}
@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Mon, 02 Jan 2023 03:04:15 GMT": 10 * time.Second,
+		"Mon, 02 Jan 2023 03:04:00 GMT": 0,
+	}
+	for value, expected := range cases {
+		if d := parseRetryAfter(value, now); d != expected {
+			t.Fatalf("Inconsistent retry after: expected: %s, actual: %s (value: %q)",
+				expected, d, value)
+		}
+	}
+}
+
+func TestRetrierDelay(t *testing.T) {
+	if newRetrier(RetryArgs{MaxAttempts: 1}) != nil {
+		t.Fatal("The retrier was created with max attempts 1!")
+	}
+	r := newRetrier(RetryArgs{
+		MaxAttempts: 5,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    30 * time.Millisecond,
+	})
+	expected := []time.Duration{
+		10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond,
+	}
+	for i, e := range expected {
+		if d := r.delay(uint32(i+1), 0); d != e {
+			t.Fatalf("Inconsistent delay: expected: %s, actual: %s (attempt: %d)", e, d, i+1)
+		}
+	}
+	if d := r.delay(100, 0); d != 30*time.Millisecond {
+		t.Fatalf("Inconsistent delay: expected: %s, actual: %s", 30*time.Millisecond, d)
+	}
+	if d := r.delay(1, time.Second); d != time.Second {
+		t.Fatalf("The retry after was not honoured: expected: %s, actual: %s", time.Second, d)
+	}
+	r.jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := r.delay(1, 0); d < 5*time.Millisecond || d > 10*time.Millisecond {
+			t.Fatalf("The delay %s with jitter is out of range!", d)
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	retryable := []error{
+		context.DeadlineExceeded,
+		&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+		fmt.Errorf("wrapped: %w", syscall.ECONNREFUSED),
+		&net.DNSError{Err: "timeout", IsTimeout: true},
+	}
+	for _, err := range retryable {
+		if !isRetryableError(err) {
+			t.Fatalf("The error %#v should be retryable!", err)
+		}
+	}
+	for _, err := range []error{errors.New("bad request"), &net.DNSError{Err: "no such host"}} {
+		if isRetryableError(err) {
+			t.Fatalf("The error %#v should not be retryable!", err)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		n := hits[r.URL.Path]
+		lock.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprint(w, `<a href="/flaky">flaky</a><a href="/down">down</a>`)
+		case r.URL.Path == "/flaky" && n >= 3:
+			fmt.Fprint(w, "<html></html>")
+		default:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Retry = RetryArgs{
+		MaxAttempts: 3,
+		BaseDelay:   10 * time.Millisecond,
+		Jitter:      0.5,
+	}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	// 放弃重试时只统计一次
+	if byStatus := sched.Summary().Struct().Errors.ByStatus; byStatus[503] != 1 {
+		t.Fatalf("Inconsistent error statuses: %v", byStatus)
+	}
+	mySched := sched.(*myScheduler)
+	if n := mySched.pending.Len(); n != 0 {
+		t.Fatalf("Inconsistent pending request number: expected: %d, actual: %d", 0, n)
+	}
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if hits["/flaky"] != 3 || hits["/down"] != 3 {
+		t.Fatalf("Inconsistent download attempts! (hits: %v)", hits)
+	}
+}
@@ -0,0 +1,265 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/robots"
+)
+
+// robots.txt 的最大读取长度，超出部分会被忽略
+const robotsMaxBodySize = 500 * 1024
+
+// 无法获取 robots.txt 时，重新获取之前等待的时间
+var robotsRetryInterval = time.Minute
+
+// 获取 robots.txt 的超时时间，在未设置下载超时时间时使用，
+// 以免某个站点一直不响应而使该站点的请求一直等待
+var robotsFetchTimeout = 30 * time.Second
+
+// robots.txt 的获取状态
+const (
+	// 成功获取并解析
+	ROBOTS_STATUS_OK = "ok"
+	// 不存在（4xx），允许爬取所有路径
+	ROBOTS_STATUS_NOT_FOUND = "not found"
+	// 暂时无法获取（5xx 或网络错误），请求会被推迟到重新获取之后
+	ROBOTS_STATUS_UNREACHABLE = "unreachable"
+)
+
+// robots.txt 缓存条目
+type robotsEntry struct {
+	// 加载完成后会被关闭
+	ready chan struct{}
+	// 获取状态
+	status string
+	// 适用于当前用户代理的规则
+	agent robots.Agent
+	// 声明的站点地图
+	sitemaps []string
+	// 过期时间，零值代表永不过期
+	expires time.Time
+	// 被禁止的请求数
+	disallowed uint64
+}
+
+// robots.txt 缓存，以 scheme://host 为键
+type robotsCache struct {
+	// 用于匹配规则组的用户代理
+	userAgent string
+	entries   map[string]*robotsEntry
+	lock      sync.Mutex
+}
+
+func newRobotsCache(userAgent string) *robotsCache {
+	return &robotsCache{
+		userAgent: userAgent,
+		entries:   map[string]*robotsEntry{},
+	}
+}
+
+// 初始化 robots.txt 缓存
+func (sched *myScheduler) initRobots(args RobotsArgs) {
+	sched.robots = nil
+	if !args.Enabled {
+		log.L().Sugar().Info("-- Robots: disabled")
+		return
+	}
+	sched.robots = newRobotsCache(args.UserAgent)
+	log.L().Sugar().Infof("-- Robots: user agent: %q", args.UserAgent)
+}
+
+// 判断给定的请求是否被 robots.txt 允许
+// 未启用 robots.txt 时总是允许
+// 站点的 robots.txt 尚未加载完成时，第二个结果值为加载完成后会被关闭的通道；
+// 站点的 robots.txt 暂时无法获取时，第三个结果值为重新获取之前需要等待的时间。
+// 这两种情况下第一个结果值都没有意义，调用方应在等待之后重新判断
+func (sched *myScheduler) robotsAllowed(httpReq *http.Request) (bool, <-chan struct{}, time.Duration) {
+	if sched.robots == nil {
+		return true, nil, 0
+	}
+	entry := sched.getRobotsEntry(httpReq)
+	select {
+	case <-entry.ready:
+	default:
+		return false, entry.ready, 0
+	}
+	if entry.status == ROBOTS_STATUS_UNREACHABLE {
+		retry := time.Until(entry.expires)
+		if retry < time.Millisecond {
+			retry = time.Millisecond
+		}
+		return false, nil, retry
+	}
+	path := httpReq.URL.EscapedPath()
+	if httpReq.URL.RawQuery != "" {
+		path += "?" + httpReq.URL.RawQuery
+	}
+	if entry.agent.Allowed(path) {
+		return true, nil, 0
+	}
+	atomic.AddUint64(&entry.disallowed, 1)
+	return false, nil, 0
+}
+
+// 获取请求所属站点的 robots.txt 缓存条目
+// 条目不存在或已过期时会在后台获取 robots.txt，因此结果值可能尚未加载完成
+func (sched *myScheduler) getRobotsEntry(httpReq *http.Request) *robotsEntry {
+	cache := sched.robots
+	key := strings.ToLower(httpReq.URL.Scheme) + "://" + strings.ToLower(httpReq.URL.Host)
+	cache.lock.Lock()
+	entry, ok := cache.entries[key]
+	if ok {
+		// 加载完成之前条目的内容还在被写入，不能判断是否过期
+		select {
+		case <-entry.ready:
+			ok = entry.expires.IsZero() || !time.Now().After(entry.expires)
+		default:
+		}
+	}
+	if !ok {
+		entry = &robotsEntry{ready: make(chan struct{})}
+		cache.entries[key] = entry
+		sched.workers.Add(1)
+		go func() {
+			defer sched.workers.Done()
+			sched.loadRobots(key, httpReq, entry)
+			close(entry.ready)
+		}()
+	}
+	cache.lock.Unlock()
+	return entry
+}
+
+// 通过已注册的下载器获取并解析 robots.txt
+func (sched *myScheduler) loadRobots(key string, httpReq *http.Request, entry *robotsEntry) {
+	entry.status = ROBOTS_STATUS_UNREACHABLE
+	entry.agent = robots.DisallowAll().Agent(sched.robots.userAgent)
+	entry.expires = time.Now().Add(robotsRetryInterval)
+
+	rules, status, err := sched.fetchRobots(key, httpReq)
+	if err != nil {
+		log.L().Sugar().Warnf("Couldn't get robots.txt: %s (site: %s)", err, key)
+		return
+	}
+	entry.status = status
+	entry.agent = rules.Agent(sched.robots.userAgent)
+	entry.sitemaps = rules.Sitemaps()
+	entry.expires = time.Time{}
+	if delay := entry.agent.CrawlDelay(); delay > 0 && sched.hostLimiter != nil {
+		sched.hostLimiter.SetDelay(sched.hostLimiterKey(httpReq), delay)
+	}
+	log.L().Sugar().Infof("Got robots.txt (site: %s, status: %s, crawl delay: %s)",
+		key, status, entry.agent.CrawlDelay())
+}
+
+// 下载 robots.txt
+// 仅当返回 5xx 状态码或下载失败时会返回非 nil 的错误值
+func (sched *myScheduler) fetchRobots(key string, httpReq *http.Request) (*robots.Robots, string, error) {
+	robotsHTTPReq, err := http.NewRequest("GET", key+robots.Path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	robotsHTTPReq.Host = httpReq.Host
+	if sched.robots.userAgent != "" {
+		robotsHTTPReq.Header.Set("User-Agent", sched.robots.userAgent)
+	}
+	m, err := sched.registrar.Get(module.TYPE_DOWNLOADER)
+	if err != nil || m == nil {
+		return nil, "", fmt.Errorf("couldn't get a downloader: %s", err)
+	}
+	downloader, ok := m.(module.Downloader)
+	if !ok {
+		return nil, "", fmt.Errorf("incorrect downloader type: %T (MID: %s)", m, m.ID())
+	}
+	release, ok := sched.acquireHost(robotsHTTPReq)
+	if !ok {
+		return nil, "", genError("the scheduler has been stopped")
+	}
+	// 许可在读取完响应体之后才被释放
+	defer release()
+	robotsReq := module.NewRequest(robotsHTTPReq, 0)
+	if sched.downloadTimeout <= 0 {
+		robotsReq.Meta().Set(module.META_KEY_TIMEOUT, robotsFetchTimeout)
+	}
+	ctx, timer := sched.downloadContext(robotsReq)
+	defer timer.release()
+	resp, err := module.DownloadContext(ctx, downloader, robotsReq)
+	if err != nil {
+		return nil, "", timer.check(err)
+	}
+	httpResp := resp.HTTPResp()
+	if httpResp == nil {
+		return nil, "", genError("nil HTTP response")
+	}
+	if httpResp.Body != nil {
+		defer httpResp.Body.Close()
+	}
+	switch {
+	case httpResp.StatusCode >= 500:
+		return nil, "", fmt.Errorf("unexpected status code %d", httpResp.StatusCode)
+	case httpResp.StatusCode >= 400:
+		return robots.AllowAll(), ROBOTS_STATUS_NOT_FOUND, nil
+	}
+	var body io.Reader = http.NoBody
+	if httpResp.Body != nil {
+		body = io.LimitReader(httpResp.Body, robotsMaxBodySize)
+	}
+	rules, err := robots.Parse(body)
+	if err != nil {
+		return nil, "", err
+	}
+	return rules, ROBOTS_STATUS_OK, nil
+}
+
+// RobotsSummaryStruct 代表某个站点的 robots.txt 的摘要类型。
+type RobotsSummaryStruct struct {
+	Status     string   `json:"status"`
+	CrawlDelay string   `json:"crawl_delay"`
+	Sitemaps   []string `json:"sitemaps,omitempty"`
+	Disallowed uint64   `json:"disallowed"`
+}
+
+// Same 用于判断两份 robots.txt 摘要是否相同。
+func (one RobotsSummaryStruct) Same(another RobotsSummaryStruct) bool {
+	if one.Status != another.Status ||
+		one.CrawlDelay != another.CrawlDelay ||
+		one.Disallowed != another.Disallowed ||
+		len(one.Sitemaps) != len(another.Sitemaps) {
+		return false
+	}
+	for i, sitemap := range one.Sitemaps {
+		if sitemap != another.Sitemaps[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 获取已加载的 robots.txt 的摘要
+func (cache *robotsCache) summary() map[string]RobotsSummaryStruct {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	summaries := make(map[string]RobotsSummaryStruct, len(cache.entries))
+	for key, entry := range cache.entries {
+		select {
+		case <-entry.ready:
+		default:
+			continue
+		}
+		summaries[key] = RobotsSummaryStruct{
+			Status:     entry.status,
+			CrawlDelay: entry.agent.CrawlDelay().String(),
+			Sitemaps:   entry.sitemaps,
+			Disallowed: atomic.LoadUint64(&entry.disallowed),
+		}
+	}
+	return summaries
+}
@@ -0,0 +1,219 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// genRobotsServer 用于生成一个提供 robots.txt 的测试服务器。
+// 参数 robotsStatus 代表 robots.txt 的响应状态码。
+func genRobotsServer(robotsStatus int, robotsContent string) (*httptest.Server, func() map[string]int) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		lock.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(robotsStatus)
+			fmt.Fprint(w, robotsContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+		fmt.Fprint(w, `<a href="/public">public</a><a href="/private/a">private</a>`)
+	}))
+	return server, func() map[string]int {
+		lock.Lock()
+		defer lock.Unlock()
+		result := map[string]int{}
+		for k, v := range hits {
+			result[k] = v
+		}
+		return result
+	}
+}
+
+// runRobotsCrawl 用于在遵守 robots.txt 的情况下爬取测试服务器并返回最终摘要。
+func runRobotsCrawl(server *httptest.Server, t *testing.T) SummaryStruct {
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Robots = RobotsArgs{Enabled: true, UserAgent: "FinderBot/1.0"}
+	dataArgs := genDataArgs(10, 2, 1)
+	moduleArgs := genSimpleModuleArgs(2, 1, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	summary := sched.Summary().Struct()
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	return summary
+}
+
+func TestRobotsDisallow(t *testing.T) {
+	content := "User-agent: *\nDisallow: /\n\n" +
+		"User-agent: finderbot\nDisallow: /private/\nCrawl-delay: 0.05\n\n" +
+		"Sitemap: http://example.com/sitemap.xml\n"
+	server, hits := genRobotsServer(http.StatusOK, content)
+	defer server.Close()
+	summary := runRobotsCrawl(server, t)
+	result := hits()
+	if result["/robots.txt"] != 1 {
+		t.Fatalf("Inconsistent robots.txt fetching count: expected: %d, actual: %d",
+			1, result["/robots.txt"])
+	}
+	if result["/public"] != 1 {
+		t.Fatalf("The allowed page was not crawled! (hits: %v)", result)
+	}
+	// robots.txt 的下载不计入下载统计
+	if summary.Downloads.Responses != 2 || summary.Downloads.Bytes == 0 {
+		t.Fatalf("Inconsistent download summary: %#v", summary.Downloads)
+	}
+	if result["/private/a"] != 0 {
+		t.Fatalf("The disallowed page was still crawled! (hits: %v)", result)
+	}
+	site := server.URL
+	rs, ok := summary.Robots[site]
+	if !ok {
+		t.Fatalf("Not found site %q in robots summary: %#v", site, summary.Robots)
+	}
+	expected := RobotsSummaryStruct{
+		Status:     ROBOTS_STATUS_OK,
+		CrawlDelay: "50ms",
+		Sitemaps:   []string{"http://example.com/sitemap.xml"},
+		Disallowed: 1,
+	}
+	if !rs.Same(expected) {
+		t.Fatalf("Inconsistent robots summary: expected: %#v, actual: %#v",
+			expected, rs)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	if delay := summary.Politeness[host].Delay; delay != "50ms" {
+		t.Fatalf("The crawl delay was not applied: expected: %s, actual: %s",
+			"50ms", delay)
+	}
+}
+
+func TestRobotsNotFound(t *testing.T) {
+	server, hits := genRobotsServer(http.StatusNotFound, "")
+	defer server.Close()
+	summary := runRobotsCrawl(server, t)
+	result := hits()
+	if result["/public"] != 1 || result["/private/a"] != 1 {
+		t.Fatalf("Some pages were not crawled! (hits: %v)", result)
+	}
+	if status := summary.Robots[server.URL].Status; status != ROBOTS_STATUS_NOT_FOUND {
+		t.Fatalf("Inconsistent robots status: expected: %s, actual: %s",
+			ROBOTS_STATUS_NOT_FOUND, status)
+	}
+}
+
+func TestRobotsUnreachable(t *testing.T) {
+	defer func(interval, timeout time.Duration) {
+		robotsRetryInterval, robotsFetchTimeout = interval, timeout
+	}(robotsRetryInterval, robotsFetchTimeout)
+	robotsRetryInterval = 100 * time.Millisecond
+	robotsFetchTimeout = 200 * time.Millisecond
+	var lock sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		count := hits[r.URL.Path]
+		lock.Unlock()
+		if r.URL.Path != "/robots.txt" {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+		switch count {
+		case 1:
+			// 第一次获取时一直不响应，直到超时
+			<-r.Context().Done()
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	summary := runRobotsCrawl(server, t)
+	lock.Lock()
+	defer lock.Unlock()
+	// 暂时无法获取 robots.txt 时，请求应被推迟而不是被丢弃。
+	if hits["/"] != 1 {
+		t.Fatalf("The page was not crawled after robots.txt became reachable! (hits: %v)", hits)
+	}
+	if hits["/robots.txt"] != 3 {
+		t.Fatalf("Inconsistent robots.txt fetching count: expected: %d, actual: %d",
+			3, hits["/robots.txt"])
+	}
+	if status := summary.Robots[server.URL].Status; status != ROBOTS_STATUS_NOT_FOUND {
+		t.Fatalf("Inconsistent robots status: expected: %s, actual: %s",
+			ROBOTS_STATUS_NOT_FOUND, status)
+	}
+}
+
+func TestRobotsLoadedInBackground(t *testing.T) {
+	delay := 300 * time.Millisecond
+	var lock sync.Mutex
+	hits := map[string]time.Time{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path] = time.Now()
+		lock.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			time.Sleep(delay)
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/public">public</a><a href="/private/a">private</a>`)
+	}))
+	defer server.Close()
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Robots = RobotsArgs{Enabled: true, UserAgent: "FinderBot/1.0"}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	start := time.Now()
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	// 获取 robots.txt 不应阻塞放入请求的一方。
+	if elapsed := time.Since(start); elapsed >= delay {
+		t.Fatalf("Starting was blocked by fetching robots.txt: elapsed: %s", elapsed)
+	}
+	waitForIdle(sched, 3, t)
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := hits["/public"]; !ok {
+		t.Fatalf("The allowed page was not crawled! (hits: %v)", hits)
+	}
+	if _, ok := hits["/private/a"]; ok {
+		t.Fatalf("The disallowed page was still crawled! (hits: %v)", hits)
+	}
+	// 请求在 robots.txt 加载完成之后才被下载。
+	if !hits["/"].After(hits["/robots.txt"].Add(delay)) {
+		t.Fatalf("The page was downloaded before robots.txt was loaded! (hits: %v)", hits)
+	}
+}
@@ -7,11 +7,18 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	cmap "github.com/dokidokikoi/go-cmap"
 	"github.com/dokidokikoi/webcrawler/log"
 	"github.com/dokidokikoi/webcrawler/module"
 	"github.com/dokidokikoi/webcrawler/toolkit/buffer"
+	"github.com/dokidokikoi/webcrawler/toolkit/deadletter"
+	"github.com/dokidokikoi/webcrawler/toolkit/dedup"
+	"github.com/dokidokikoi/webcrawler/toolkit/frontier"
+	"github.com/dokidokikoi/webcrawler/toolkit/limiter"
+	"github.com/dokidokikoi/webcrawler/toolkit/urlfilter"
+	"github.com/dokidokikoi/webcrawler/toolkit/urlnorm"
 )
 
 // 调度器接口
@@ -25,9 +32,34 @@ type Scheduler interface {
 	// @Param firstHTTPReq 代表首次请求
 	// 调度会以此为起点开始执行爬行流程
 	Start(firstHTTPReq *http.Request) (err error)
+	// 以多个起点启动调度器并执行爬虫程序
+	// 各起点的主机会按范围模式加入爬取范围，不在范围内的起点会被忽略
+	// @Param seeds 代表起点列表，可由 LoadSeeds 或 LoadSeedFile 读取
+	StartWith(seeds []Seed) (err error)
+	// 向运行中（包括暂停中）的调度器放入新的请求
+	// 请求会经过与分析器产生的请求相同的检查
+	// 所属站点的 robots.txt 尚未加载完成的请求会先被接受，在下载之前才按 robots.txt 检查
+	// @Return accepted 代表被放入待爬取队列的请求数
+	// @Return rejected 代表被拒绝的请求的 URL 与拒绝原因，URL 无效时以请求的序号为键
+	Enqueue(reqs ...*module.Request) (accepted int, rejected map[string]string)
+	// 向运行中的调度器重新放入请求，如由 deadletter.ReadRequests 读取的失败请求
+	// 与 Enqueue 不同，已处理过的 URL 也会被接受
+	Reinject(reqs ...*module.Request) (accepted int, rejected map[string]string)
 	// 停止调度器的运行
 	// 所有的处理模块执行的流程都会终止
 	Stop() (err error)
+	// 暂停调度器的运行
+	// 工作协程不再从缓冲池中取出数据，缓冲池中的数据会被保留
+	// 会等待正在处理的数据处理完毕，等待期间调度器仍可以被停止，此时会返回错误
+	Pause() (err error)
+	// 恢复已暂停的调度器的运行
+	Resume() (err error)
+	// 平稳地停止调度器的运行
+	// 不再接受和下载新的请求，等待正在进行的下载、分析和条目处理完成
+	// 并处理完条目缓冲池中的条目后再停止
+	// 若 ctx 先结束，则立即停止
+	// @Return abandoned 代表停止时尚未处理的条目数
+	Shutdown(ctx context.Context) (abandoned uint64, err error)
 	// 获取调度器状态
 	Status() Status
 	// 获取错误管道
@@ -39,25 +71,92 @@ type Scheduler interface {
 	Idle() bool
 	// 获取摘要实例
 	Summary() SchedSummary
+	// 立即把爬取进度写入检查点目录
+	// 若未设置检查点目录，则返回错误值
+	Checkpoint() (err error)
+	// 从给定目录中的检查点恢复并继续执行爬虫程序
+	// 调度器会先按检查点中的参数初始化，再恢复爬取进度并启动
+	// @Param checkpointDir 代表检查点目录
+	// @Param moduleArgs 代表组件相关的参数
+	// @Param priority 代表自定义的优先级函数，为 nil 时使用检查点中的优先级策略
+	// 生成检查点时使用了自定义的优先级函数的，必须重新提供，否则会返回错误
+	ResumeFrom(checkpointDir string, moduleArgs ModuleArgs, priority frontier.PriorityFunc) (err error)
 }
 
 type myScheduler struct {
 	// 爬取到最大深度，首次请求的深度为0
 	maxDepth uint32
-	// 可以接受的 URL 的主域名的字典
-	acceptedDomainMap cmap.ConcurrentMap
+	// 爬取范围的模式
+	scopeMode string
+	// 可以接受的 URL 的主域名或主机名的集合，取决于范围模式
+	acceptedDomainMap *stringSet
 	// 组件组册器
 	registrar module.Registrar
-	// 请求缓冲池
-	reqBufferPool buffer.Pool
+	// 待爬取队列，按优先级存放请求
+	frontier frontier.Frontier
+	// 待爬取队列的参数，用于在重新启动时重建队列
+	frontierArgs FrontierArgs
 	// 响应缓冲池
 	respBufferPool buffer.Pool
 	// 条目缓冲池
 	itemBufferPool buffer.Pool
 	// 错误缓冲池
 	errorBufferPool buffer.Pool
-	// 已处理的 URL 字典
-	urlMap cmap.ConcurrentMap
+	// 已处理的 URL 集合，其中的 URL 已被规范化
+	urlMap dedup.Deduper
+	// URL 规范化器，为 nil 时不做规范化
+	normalizer urlnorm.Normalizer
+	// URL 过滤器，未设置规则时为 nil
+	urlFilter urlfilter.Filter
+	// 死信存储，未设置死信文件时为 nil
+	deadLetters deadletter.Store
+	// 下载重试器，不需要重试时为 nil
+	retrier *retrier
+	// 等待重试的请求数
+	retrying int64
+	// 暂时不能下载而等待放回待爬取队列的请求数
+	deferred int64
+	// 每次下载的超时时间，为 0 时不限制
+	downloadTimeout time.Duration
+	// 响应体大小的限制器，为 nil 时不限制
+	bodyLimiter *bodyLimiter
+	// 分析时把响应体暂存到临时文件的阈值，为 0 时保持分析器自身的设置
+	spillThreshold int64
+	// 下载情况的统计
+	downloadStats downloadStats
+	// 错误统计
+	errorStats errorStats
+	// 尚未处理完毕的请求，以 URL 为键
+	pending *requestSet
+	// 已下载但尚未分析的响应与其请求的对应关系
+	pendingResps sync.Map
+	// 爬取进度的读写锁
+	// 接受新请求时持有读锁，生成检查点时持有写锁
+	frontierLock sync.RWMutex
+	// 检查点目录，为空时不生成检查点
+	checkpointDir string
+	// 定期生成检查点的间隔，为 0 时不定期生成
+	checkpointInterval time.Duration
+	// 检查点写入锁
+	checkpointLock sync.Mutex
+	// 正在运行的工作协程，调度器停止时会等待它们退出
+	workers sync.WaitGroup
+	// 下载阶段的工作协程计数器
+	downloadWorkers workerCounter
+	// 分析阶段的工作协程计数器
+	analyzeWorkers workerCounter
+	// 条目处理阶段的工作协程计数器
+	pickWorkers workerCounter
+	// 主机访问限制器，为 nil 时不做限制
+	hostLimiter limiter.HostLimiter
+	// 是否按主域名归并主机访问限制
+	limitByPrimaryDomain bool
+	// robots.txt 缓存，为 nil 时不遵守 robots.txt
+	robots *robotsCache
+	// 暂停闸门
+	gate *pauseGate
+	// 是否正在平稳停止，为 1 时不再接受和下载新的请求
+	draining uint32
 	// 上下文，用于感知调度器的停止
 	ctx context.Context
 	// 取消函数，用于停止调度器
@@ -86,15 +185,6 @@ func (sched *myScheduler) checkAndSetStatus(
 // 初始化缓冲池
 // 如果某个缓冲池可用且为关闭，就先关闭该缓冲池
 func (sched *myScheduler) initBufferPool(dataArgs DataArgs) {
-	// 初始化请求缓冲池
-	if sched.reqBufferPool != nil && !sched.reqBufferPool.Closed() {
-		sched.reqBufferPool.Close()
-	}
-	sched.reqBufferPool, _ = buffer.NewPool(
-		dataArgs.ReqBufferCap, dataArgs.ReqMaxBufferNumber)
-	log.L().Sugar().Info("-- Request buffer pool: bufferCap: %d, maxBufferNumber: %d",
-		sched.reqBufferPool.BufferCap(), sched.reqBufferPool.MaxBufferNumber())
-
 	// 初始化响应缓冲池
 	if sched.respBufferPool != nil && !sched.respBufferPool.Closed() {
 		sched.respBufferPool.Close()
@@ -123,6 +213,15 @@ func (sched *myScheduler) initBufferPool(dataArgs DataArgs) {
 		sched.errorBufferPool.BufferCap(), sched.errorBufferPool.MaxBufferNumber())
 }
 
+// 初始化各处理阶段的工作协程数量
+func (sched *myScheduler) initWorkers(dataArgs DataArgs) {
+	sched.downloadWorkers.setNumber(getWorkerNumber(dataArgs.DownloaderWorkerNumber))
+	sched.analyzeWorkers.setNumber(getWorkerNumber(dataArgs.AnalyzerWorkerNumber))
+	sched.pickWorkers.setNumber(getWorkerNumber(dataArgs.PipelineWorkerNumber))
+	log.L().Sugar().Infof("-- Workers: download: %d, analyze: %d, pick: %d",
+		sched.downloadWorkers.Number(), sched.analyzeWorkers.Number(), sched.pickWorkers.Number())
+}
+
 func (sched *myScheduler) resetContext() {
 	sched.ctx, sched.cancelFunc = context.WithCancel(context.Background())
 }
@@ -156,6 +255,9 @@ func (sched *myScheduler) registerModules(moduleArgs ModuleArgs) error {
 			errMsg := fmt.Sprintf("Couldn't register analyzer instance with MID %q!", a.ID())
 			return genError(errMsg)
 		}
+		if sa, ok := a.(module.SpillingAnalyzer); ok && sched.spillThreshold > 0 {
+			sa.SetSpillThreshold(sched.spillThreshold)
+		}
 	}
 	log.L().Sugar().Infof("All analyzers have been registered. (number: %d)",
 		len(moduleArgs.Analyzers))
@@ -182,13 +284,12 @@ func (sched *myScheduler) registerModules(moduleArgs ModuleArgs) error {
 // 如果某个缓冲池不可用，就直接返回错误值报告此情况
 // 如果某个缓冲池已关闭，就按原先的参数重新初始化它
 func (sched *myScheduler) checkBufferPoolForStart() error {
-	// 检查请求缓冲池
-	if sched.reqBufferPool == nil {
-		return genError("nil request buffer pool")
+	// 检查待爬取队列
+	if sched.frontier == nil {
+		return genError("nil frontier")
 	}
-	if sched.reqBufferPool != nil && sched.reqBufferPool.Closed() {
-		sched.reqBufferPool, _ = buffer.NewPool(
-			sched.reqBufferPool.BufferCap(), sched.reqBufferPool.MaxBufferNumber())
+	if sched.frontier.Closed() {
+		sched.frontier, _ = newFrontier(sched.frontierArgs, sched.frontier.Cap())
 	}
 
 	// 检查响应缓冲池
@@ -220,27 +321,42 @@ func (sched *myScheduler) checkBufferPoolForStart() error {
 	return nil
 }
 
-// 从缓冲池取出请求并下载
+// 从待爬取队列取出请求并下载
 // 然后把得到的响应放入响应缓冲池
+// 会启动若干个工作协程并行地处理请求
 func (sched *myScheduler) download() {
-	go func() {
-		for {
-			if sched.canceled() {
-				break
+	for i := uint32(0); i < sched.downloadWorkers.Number(); i++ {
+		sched.workers.Add(1)
+		go func() {
+			defer sched.workers.Done()
+			for {
+				if !sched.gate.wait(sched.ctx.Done()) {
+					break
+				}
+				req, err := sched.frontier.Get()
+				if err != nil {
+					log.L().Sugar().Warnln("The frontier was closed. Break request reception.")
+					break
+				}
+				// 调度器在取出请求后被暂停时，持有该请求等待恢复
+				if !sched.gate.enter(sched.ctx.Done()) {
+					break
+				}
+				// 先计入正在处理的数量再检查是否正在平稳停止，
+				// 这样平稳停止时要么等待该请求下载完毕，要么该请求不会被下载
+				sched.downloadWorkers.incrActive()
+				if sched.isDraining() {
+					// 平稳停止时不再下载新的请求，该请求仍会保留在检查点中
+					sched.downloadWorkers.decrActive()
+					sched.gate.leave()
+					break
+				}
+				sched.downloadOne(req)
+				sched.downloadWorkers.decrActive()
+				sched.gate.leave()
 			}
-			datum, err := sched.reqBufferPool.Get()
-			if err != nil {
-				log.L().Sugar().Warnln("The request buffer pool was closed. Break request reception.")
-				break
-			}
-			req, ok := datum.(*module.Request)
-			if !ok {
-				errMsg := fmt.Sprintf("incorrect request type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
-			}
-			sched.downloadOne(req)
-		}
-	}()
+		}()
+	}
 }
 
 // 根据给定的请求执行下载并把响应放入响应缓冲池
@@ -251,95 +367,237 @@ func (sched *myScheduler) downloadOne(req *module.Request) {
 	if sched.canceled() {
 		return
 	}
+	allowed, loading, retry := sched.robotsAllowed(req.HTTPReq())
+	if loading != nil {
+		// 站点的 robots.txt 加载完成之后再放回队列，以免阻塞下载工作协程
+		sched.deferReqUntil(req, loading)
+		return
+	}
+	if retry > 0 {
+		// 站点的 robots.txt 暂时无法获取，等到重新获取时再放回队列
+		sched.deferReq(req, retry, nil)
+		return
+	}
+	if !allowed {
+		sched.pending.Remove(pendingKey(req))
+		log.L().Sugar().Warnf("Ignore the request! It is disallowed by robots.txt. (URL: %s)",
+			req.HTTPReq().URL)
+		return
+	}
 	m, err := sched.registrar.Get(module.TYPE_DOWNLOADER)
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("couldn't get a downloader: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sched.sendReq(req)
+		sched.sendDownloadError(errors.New(errMsg), "", req)
+		sched.putReq(req)
 		return
 	}
 	downloader, ok := m.(module.Downloader)
 	if !ok {
 		errMsg := fmt.Sprintf("incorrect downloader type: %T (MID: %s)",
 			m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sched.sendReq(req)
+		sched.sendDownloadError(errors.New(errMsg), m.ID(), req)
+		sched.putReq(req)
+		return
+	}
+	release, wait := sched.tryAcquireHost(req.HTTPReq())
+	if release == nil {
+		// 主机暂时不允许发送请求时稍后再放回队列，以免阻塞下载工作协程
+		sched.deferReq(req, wait, sched.deferHost(req.HTTPReq()))
 		return
 	}
-	resp, err := downloader.Download(req)
+	req.SetAttempt(req.Attempt() + 1)
+	ctx, timer := sched.downloadContext(req)
+	resp, err := module.DownloadContext(ctx, downloader, req)
+	// 响应等待分析期间暂停计时，直到读取响应体时才继续
+	timer.stop()
+	err = timer.check(err)
+	resp = timeBody(resp, timer)
+	resp = holdHost(resp, release)
+	if err == nil {
+		if err = sched.limitBody(resp); err != nil {
+			resp = nil
+		}
+	}
+	if err != nil && sched.canceled() {
+		// 下载因调度器停止而中止，请求仍会保留在待处理集合中以便恢复
+		closeResp(resp)
+		return
+	}
+	if sched.retryIfNeeded(req, resp, err, m.ID()) {
+		return
+	}
+	if resp == nil && err != nil {
+		sched.deadLetterRequest(req, err)
+	}
+	if code, ok := badStatus(resp); ok {
+		// 响应仍会被分析，但状态码不符合预期这一情况需要被统计
+		sched.recordDownloadError(genBadStatusError(code), m.ID(), req)
+	}
 	if resp != nil {
-		sendResp(resp, sched.respBufferPool)
+		// 请求在其响应被分析完毕后才算处理完毕
+		sched.pendingResps.Store(resp, req)
+		if sendResp(resp, sched.respBufferPool) {
+			sched.analyzeWorkers.incrPending()
+		} else {
+			sched.pendingResps.Delete(resp)
+		}
+	} else {
+		sched.pending.Remove(pendingKey(req))
 	}
 	if err != nil {
-		sendError(err, m.ID(), sched.errorBufferPool)
+		sched.sendDownloadError(err, m.ID(), req)
 	}
 }
 
 func (sched *myScheduler) sendReq(req *module.Request) bool {
+	reason := sched.trySendReq(req, false)
+	if reason == "" {
+		return true
+	}
+	if req != nil && req.HTTPReq() != nil && req.HTTPReq().URL != nil {
+		log.L().Sugar().Warnf("Ignore the request! %s (URL: %s)", reason, req.HTTPReq().URL)
+	} else {
+		log.L().Sugar().Warnf("Ignore the request! %s", reason)
+	}
+	return false
+}
+
+// 检查请求并在通过检查后放入待爬取队列
+// 参数 reinject 代表是否允许放入已处理过的 URL
+// 结果值为请求被忽略的原因，为空时代表请求已被放入
+func (sched *myScheduler) trySendReq(req *module.Request, reinject bool) string {
 	if req == nil {
-		return false
+		return "The request is nil."
 	}
-	if sched.canceled() {
-		return false
+	if sched.canceled() || sched.isDraining() {
+		return "The scheduler is stopping or has been stopped."
 	}
 	httpReq := req.HTTPReq()
 	if httpReq == nil {
-		log.L().Sugar().Warnln("Ignore the request! Its HTTP request is invalid!")
-		return false
+		return "Its HTTP request is invalid."
 	}
 	reqURL := httpReq.URL
 	if reqURL == nil {
-		log.L().Sugar().Warnln("Ignore the request! Its URL is invalid!")
-		return false
+		return "Its URL is invalid."
 	}
 	scheme := strings.ToLower(reqURL.Scheme)
 	if scheme != "http" && scheme != "https" {
-		log.L().Sugar().Warnln("Ignore the request! Its URL scheme is %q, but should be %q or %q. (URL: %s)\n",
-			scheme, "http", "https", reqURL)
-		return false
+		return fmt.Sprintf("Its URL scheme is %q, but should be %q or %q.",
+			scheme, "http", "https")
 	}
-	if v := sched.urlMap.Get(reqURL.String()); v != nil {
-		log.L().Sugar().Warnf("Ignore the request! Its URL is repeated. (URL: %s)\n",
-			reqURL)
-		return false
+	urlKey := sched.urlKey(reqURL)
+	if !reinject && sched.urlMap.Has(urlKey) {
+		return "Its URL is repeated."
 	}
-	pd, _ := getPrimaryDomain(httpReq.Host)
-	if sched.acceptedDomainMap.Get(pd) == nil {
-		if pd == "bing.net" {
-			panic(httpReq.URL)
-		}
-		log.L().Sugar().Warnf("Ignore the request! Its host %q is not in accepted primary domain map. (URL: %s)",
-			httpReq.Host, reqURL)
-		return false
+	if !sched.inScope(httpReq) {
+		return fmt.Sprintf("Its host %q is out of scope.", httpReq.Host)
 	}
-	if req.Depth() > sched.maxDepth {
-		log.L().Sugar().Warnf("Ignore the request! Its depth %d is greater than %d. (URL: %s)",
-			req.Depth(), sched.maxDepth, reqURL)
-		return false
+	maxDepth := sched.maxDepth
+	if d, ok := req.MaxDepth(); ok {
+		maxDepth = d
+	}
+	if req.Depth() > maxDepth {
+		return fmt.Sprintf("Its depth %d is greater than %d.", req.Depth(), maxDepth)
+	}
+	if allowed, index := sched.urlAllowed(reqURL); !allowed {
+		return fmt.Sprintf("It is denied by URL rule #%d.", index+1)
 	}
+	// 站点的 robots.txt 尚未加载完成或暂时无法获取时，请求会在下载之前被再次检查
+	if allowed, loading, retry := sched.robotsAllowed(httpReq); loading == nil && retry == 0 && !allowed {
+		return "It is disallowed by robots.txt."
+	}
+
+	sched.frontierLock.RLock()
+	defer sched.frontierLock.RUnlock()
+	if !sched.urlMap.Add(urlKey) && !reinject {
+		return "Its URL is repeated."
+	}
+	sched.putReq(req)
+	return ""
+}
 
-	go func(req *module.Request) {
-		if err := sched.reqBufferPool.Put(req); err != nil {
-			log.L().Sugar().Warnln("The request buffer pool was closed. Ingnore request sending.")
+// 把请求记为待处理并放入待爬取队列
+// 不做任何检查
+func (sched *myScheduler) putReq(req *module.Request) {
+	sched.pending.Add(pendingKey(req), req)
+	go func(req *module.Request, frontier frontier.Frontier) {
+		if err := frontier.Put(req); err != nil {
+			log.L().Sugar().Warnln("The frontier was closed. Ingnore request sending.")
 		}
-	}(req)
-	sched.urlMap.Put(reqURL.String(), struct{}{})
-	return true
+	}(req, sched.frontier)
+}
+
+// 在给定的时间之后把暂时不能下载的请求放回待爬取队列
+// 请求仍保留在待处理集合中，因此调度器停止后也可以被恢复
+// 参数 done 不为 nil 时会在等待结束时被调用
+func (sched *myScheduler) deferReq(req *module.Request, delay time.Duration, done func()) {
+	atomic.AddInt64(&sched.deferred, 1)
+	// 定时器可能在调度器停止并重新初始化之后才触发，因此使用当前的上下文和队列
+	ctx, frontier := sched.ctx, sched.frontier
+	time.AfterFunc(delay, func() {
+		defer atomic.AddInt64(&sched.deferred, -1)
+		if done != nil {
+			done()
+		}
+		sched.requeue(ctx, frontier, req)
+	})
 }
 
+// 在给定的通道被关闭之后把暂时不能下载的请求放回待爬取队列
+// 请求仍保留在待处理集合中，因此调度器停止后也可以被恢复
+func (sched *myScheduler) deferReqUntil(req *module.Request, ready <-chan struct{}) {
+	atomic.AddInt64(&sched.deferred, 1)
+	ctx, frontier := sched.ctx, sched.frontier
+	go func() {
+		defer atomic.AddInt64(&sched.deferred, -1)
+		select {
+		case <-ready:
+			sched.requeue(ctx, frontier, req)
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// 把等待过的请求放回给定的待爬取队列
+// 调度器已停止或正在平稳停止时不再放回
+func (sched *myScheduler) requeue(ctx context.Context, frontier frontier.Frontier, req *module.Request) {
+	if ctx.Err() != nil || sched.isDraining() {
+		return
+	}
+	if err := frontier.Put(req); err != nil {
+		log.L().Sugar().Warnln("The frontier was closed. Ingnore request sending.")
+	}
+}
+
+// 把响应放入响应缓冲池
+// 无法放入时响应体会被关闭，以释放其持有的主机访问许可
 func sendResp(resp *module.Response, respBufferPool buffer.Pool) bool {
-	if resp == nil || respBufferPool == nil || respBufferPool.Closed() {
+	if resp == nil {
+		return false
+	}
+	if respBufferPool == nil || respBufferPool.Closed() {
+		closeResp(resp)
 		return false
 	}
 
 	go func(resp *module.Response) {
 		if err := respBufferPool.Put(resp); err != nil {
 			log.L().Sugar().Warnln("The response buffer pool was closed. Ignore response sending.")
+			closeResp(resp)
 		}
 	}(resp)
 	return true
 }
 
+// 关闭被丢弃的响应的响应体
+// 主机访问许可和下载计时器都在响应体被关闭时才被释放，因此丢弃响应时必须调用它
+func closeResp(resp *module.Response) {
+	if resp == nil || resp.HTTPResp() == nil || resp.HTTPResp().Body == nil {
+		return
+	}
+	resp.HTTPResp().Body.Close()
+}
+
 // canceled 用于判断调度器的上下文是否已被取消。
 func (sched *myScheduler) canceled() bool {
 	select {
@@ -352,25 +610,41 @@ func (sched *myScheduler) canceled() bool {
 
 // 从响应缓冲池取出响应并解析
 // 然后把得到的条目或请求放入响应的缓冲池
+// 会启动若干个工作协程并行地处理响应
 func (sched *myScheduler) analyze() {
-	go func() {
-		for {
-			if sched.canceled() {
-				break
+	for i := uint32(0); i < sched.analyzeWorkers.Number(); i++ {
+		sched.workers.Add(1)
+		go func() {
+			defer sched.workers.Done()
+			for {
+				if !sched.gate.wait(sched.ctx.Done()) {
+					break
+				}
+				datum, err := sched.respBufferPool.Get()
+				if err != nil {
+					log.L().Sugar().Warnln("The response buffer pool was closed. Break response reception.")
+					break
+				}
+				resp, ok := datum.(*module.Response)
+				if !ok {
+					errMsg := fmt.Sprintf("incorrect response type: %T", datum)
+					sched.sendError(errors.New(errMsg), "")
+					sched.analyzeWorkers.decrPending()
+					continue
+				}
+				// 调度器在取出数据后被暂停时，持有该数据等待恢复
+				if !sched.gate.enter(sched.ctx.Done()) {
+					closeResp(resp)
+					break
+				}
+				sched.analyzeWorkers.incrActive()
+				sched.analyzeOne(resp)
+				sched.analyzeWorkers.decrActive()
+				sched.analyzeWorkers.decrPending()
+				sched.gate.leave()
 			}
-			datum, err := sched.respBufferPool.Get()
-			if err != nil {
-				log.L().Sugar().Warnln("The response buffer pool was closed. Break response reception.")
-				break
-			}
-			resp, ok := datum.(*module.Response)
-			if !ok {
-				errMsg := fmt.Sprintf("incorrect response type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
-			}
-			sched.analyzeOne(resp)
-		}
-	}()
+		}()
+	}
 }
 
 func (sched *myScheduler) analyzeOne(resp *module.Response) {
@@ -378,24 +652,34 @@ func (sched *myScheduler) analyzeOne(resp *module.Response) {
 		return
 	}
 	if sched.canceled() {
+		closeResp(resp)
 		return
 	}
 	m, err := sched.registrar.Get(module.TYPE_ANALYZER)
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("couldn't get an analyzer: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sendResp(resp, sched.respBufferPool)
+		sched.sendAnalyzeError(errors.New(errMsg), "", resp)
+		if sendResp(resp, sched.respBufferPool) {
+			sched.analyzeWorkers.incrPending()
+		}
 		return
 	}
 	analyzer, ok := m.(module.Analyzer)
 	if !ok {
 		errMsg := fmt.Sprintf("incorrect analyzer type %T (MID: %s)",
 			m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sendResp(resp, sched.respBufferPool)
+		sched.sendAnalyzeError(errors.New(errMsg), m.ID(), resp)
+		if sendResp(resp, sched.respBufferPool) {
+			sched.analyzeWorkers.incrPending()
+		}
 		return
 	}
 	dataList, errs := analyzer.Analyze(resp)
+	sched.downloadStats.record(resp)
+	var parent *module.Request
+	if v, ok := sched.pendingResps.Load(resp); ok {
+		parent = v.(*module.Request)
+	}
 	if dataList != nil {
 		for _, data := range dataList {
 			if data == nil {
@@ -403,20 +687,42 @@ func (sched *myScheduler) analyzeOne(resp *module.Response) {
 			}
 			switch d := data.(type) {
 			case *module.Request:
+				inheritMaxDepth(d, parent)
 				sched.sendReq(d)
 			case module.Item:
-				sendItem(d, sched.itemBufferPool)
+				if sendItem(d, sched.itemBufferPool) {
+					sched.pickWorkers.incrPending()
+				}
 			default:
 				errMsg := fmt.Sprintf("Unsupported data type %T! (data: %#v)", d, d)
-				sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
+				sched.sendAnalyzeError(errors.New(errMsg), m.ID(), resp)
 			}
 		}
 	}
 	if errs != nil {
 		for _, err := range errs {
-			sendError(err, m.ID(), sched.errorBufferPool)
+			sched.sendAnalyzeError(err, m.ID(), resp)
 		}
 	}
+	// 调度器停止后新请求会被忽略，因此保留该请求以便恢复后重新处理
+	if _, ok := sched.pendingResps.LoadAndDelete(resp); ok &&
+		!sched.canceled() && !sched.isDraining() && parent != nil {
+		sched.pending.Remove(pendingKey(parent))
+	}
+}
+
+// 把父请求的最大爬取深度传递给新请求
+// 新请求已设置最大爬取深度时不做处理
+func inheritMaxDepth(req *module.Request, parent *module.Request) {
+	if parent == nil {
+		return
+	}
+	if _, ok := req.MaxDepth(); ok {
+		return
+	}
+	if d, ok := parent.MaxDepth(); ok {
+		req.SetMaxDepth(d)
+	}
 }
 
 func sendItem(item module.Item, itemBufferPool buffer.Pool) bool {
@@ -433,25 +739,40 @@ func sendItem(item module.Item, itemBufferPool buffer.Pool) bool {
 }
 
 // 从条目缓冲池取出条目并处理
+// 会启动若干个工作协程并行地处理条目
 func (sched *myScheduler) pick() {
-	go func() {
-		for {
-			if sched.canceled() {
-				break
-			}
-			datum, err := sched.itemBufferPool.Get()
-			if err != nil {
-				log.L().Sugar().Warnln("The item buffer pool was closed. Break item reception.")
-				break
-			}
-			item, ok := datum.(module.Item)
-			if !ok {
-				errMsg := fmt.Sprintf("incorrect item type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
+	for i := uint32(0); i < sched.pickWorkers.Number(); i++ {
+		sched.workers.Add(1)
+		go func() {
+			defer sched.workers.Done()
+			for {
+				if !sched.gate.wait(sched.ctx.Done()) {
+					break
+				}
+				datum, err := sched.itemBufferPool.Get()
+				if err != nil {
+					log.L().Sugar().Warnln("The item buffer pool was closed. Break item reception.")
+					break
+				}
+				item, ok := datum.(module.Item)
+				if !ok {
+					errMsg := fmt.Sprintf("incorrect item type: %T", datum)
+					sched.sendError(errors.New(errMsg), "")
+					sched.pickWorkers.decrPending()
+					continue
+				}
+				// 调度器在取出数据后被暂停时，持有该数据等待恢复
+				if !sched.gate.enter(sched.ctx.Done()) {
+					break
+				}
+				sched.pickWorkers.incrActive()
+				sched.pickOne(item)
+				sched.pickWorkers.decrActive()
+				sched.pickWorkers.decrPending()
+				sched.gate.leave()
 			}
-			sched.pickOne(item)
-		}
-	}()
+		}()
+	}
 }
 
 func (sched *myScheduler) pickOne(item module.Item) {
@@ -461,22 +782,27 @@ func (sched *myScheduler) pickOne(item module.Item) {
 	m, err := sched.registrar.Get(module.TYPE_PIPELINE)
 	if err != nil || m == nil {
 		errMsg := fmt.Sprintf("couldn't get a pipeline: %s", err)
-		sendError(errors.New(errMsg), "", sched.errorBufferPool)
-		sendItem(item, sched.itemBufferPool)
+		sched.sendPipelineError(errors.New(errMsg), "", item)
+		if sendItem(item, sched.itemBufferPool) {
+			sched.pickWorkers.incrPending()
+		}
 		return
 	}
 	pipeline, ok := m.(module.Pipeline)
 	if !ok {
 		errMsg := fmt.Sprintf("incorrent pipeline type; %T (MID: %s",
 			m, m.ID())
-		sendError(errors.New(errMsg), m.ID(), sched.errorBufferPool)
-		sendItem(item, sched.itemBufferPool)
+		sched.sendPipelineError(errors.New(errMsg), m.ID(), item)
+		if sendItem(item, sched.itemBufferPool) {
+			sched.pickWorkers.incrPending()
+		}
 		return
 	}
 	errs := pipeline.Send(item)
+	sched.deadLetterItem(item, errs)
 	if errs != nil {
 		for _, err := range errs {
-			sendError(err, m.ID(), sched.errorBufferPool)
+			sched.sendPipelineError(err, m.ID(), item)
 		}
 	}
 }
@@ -499,13 +825,43 @@ func (sched *myScheduler) Stop() (err error) {
 	if err != nil {
 		return
 	}
+	sched.stop()
+	log.L().Sugar().Info("Scheduler has been stopped.")
+	return nil
+}
+
+// 取消上下文并关闭所有缓冲池
+// 若设置了检查点目录，还会生成最后一个检查点
+func (sched *myScheduler) stop() {
 	sched.cancelFunc()
-	sched.reqBufferPool.Close()
+	sched.frontier.Close()
 	sched.respBufferPool.Close()
 	sched.itemBufferPool.Close()
 	sched.errorBufferPool.Close()
-	log.L().Sugar().Info("Scheduler has been stopped.")
-	return nil
+	// 等待工作协程退出，以免它们与之后的初始化同时访问调度器的字段
+	sched.workers.Wait()
+	// 关闭仍留在响应缓冲池中而不会再被分析的响应
+	sched.pendingResps.Range(func(key, _ interface{}) bool {
+		closeResp(key.(*module.Response))
+		return true
+	})
+	if sched.checkpointDir != "" {
+		if err := sched.writeCheckpoint(); err != nil {
+			log.L().Sugar().Errorf("Couldn't write the final checkpoint: %s", err)
+		}
+	}
+	sched.closeDeduper()
+	sched.closeDeadLetter()
+}
+
+// 关闭初始化时打开的待爬取队列、URL 去重存储和死信存储
+// 用于在初始化或恢复中途失败时释放资源
+func (sched *myScheduler) closeStores() {
+	if sched.frontier != nil && !sched.frontier.Closed() {
+		sched.frontier.Close()
+	}
+	sched.closeDeduper()
+	sched.closeDeadLetter()
 }
 
 func (sched *myScheduler) Status() Status {
@@ -519,9 +875,10 @@ func (sched *myScheduler) Status() Status {
 func (sched *myScheduler) ErrorChan() <-chan error {
 	errBuffer := sched.errorBufferPool
 	errCh := make(chan error, errBuffer.BufferCap())
+	ctx := sched.ctx
 	go func(errBuffer buffer.Pool, errCh chan error) {
 		for {
-			if sched.canceled() {
+			if ctx.Err() != nil {
 				close(errCh)
 				break
 			}
@@ -534,10 +891,10 @@ func (sched *myScheduler) ErrorChan() <-chan error {
 			err, ok := datum.(error)
 			if !ok {
 				errMsg := fmt.Sprintf("incorrect error type: %T", datum)
-				sendError(errors.New(errMsg), "", sched.errorBufferPool)
+				sched.sendError(errors.New(errMsg), "")
 				continue
 			}
-			if sched.canceled() {
+			if ctx.Err() != nil {
 				close(errCh)
 				break
 			}
@@ -554,11 +911,20 @@ func (sched *myScheduler) Idle() bool {
 			return false
 		}
 	}
-	if sched.reqBufferPool.Total() > 0 ||
+	if sched.frontier.Total() > 0 ||
 		sched.respBufferPool.Total() > 0 ||
 		sched.itemBufferPool.Total() > 0 {
 		return false
 	}
+	if sched.downloadWorkers.Active() > 0 ||
+		sched.analyzeWorkers.Active() > 0 ||
+		sched.pickWorkers.Active() > 0 {
+		return false
+	}
+	if atomic.LoadInt64(&sched.retrying) > 0 ||
+		atomic.LoadInt64(&sched.deferred) > 0 {
+		return false
+	}
 	return true
 }
 
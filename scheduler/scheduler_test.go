@@ -6,10 +6,10 @@ import (
 	"testing"
 	"time"
 
-	cmap "github.com/dokidokikoi/go-cmap"
 	"github.com/dokidokikoi/webcrawler/log"
 	"github.com/dokidokikoi/webcrawler/module"
 	"github.com/dokidokikoi/webcrawler/toolkit/buffer"
+	"github.com/dokidokikoi/webcrawler/toolkit/dedup"
 )
 
 // snGen 代表序列号生成器。
@@ -367,7 +367,7 @@ func TestSchedSendReq(t *testing.T) {
 	if mySched.sendReq(req) {
 		t.Fatalf("It still can send repeated request!")
 	}
-	mySched.urlMap, _ = cmap.NewConcurrentMap(16, nil)
+	mySched.urlMap = dedup.NewMemory()
 	// 测试scheme不匹配的情况。
 	httpReq.URL.Scheme = "tcp"
 	if mySched.sendReq(req) {
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/toolkit/domain"
+)
+
+// 爬取范围的模式
+const (
+	// 主域名，接受主域名在范围内的主机，首个请求的主域名会被加入范围
+	// 范围的初始值为 RequestArgs.AcceptedDomains
+	SCOPE_PRIMARY_DOMAIN = "primary_domain"
+	// 主机及其子域名，首个请求的主机名会被加入范围
+	// 范围的初始值为 ScopeArgs.Hosts
+	SCOPE_SUBDOMAINS = "subdomains"
+	// 精确的主机名，首个请求的主机名会被加入范围
+	// 范围的初始值为 ScopeArgs.Hosts
+	SCOPE_EXACT_HOST = "exact_host"
+	// 明确的主机名列表，即 ScopeArgs.Hosts，首个请求不会扩大范围
+	SCOPE_HOST_LIST = "host_list"
+)
+
+// 获取生效的范围模式
+func (args *ScopeArgs) mode() string {
+	if args.Mode == "" {
+		return SCOPE_PRIMARY_DOMAIN
+	}
+	return args.Mode
+}
+
+// 初始化爬取范围
+func (sched *myScheduler) initScope(reqArgs RequestArgs) {
+	sched.scopeMode = reqArgs.Scope.mode()
+	sched.acceptedDomainMap = newStringSet()
+	entries := reqArgs.Scope.Hosts
+	if sched.scopeMode == SCOPE_PRIMARY_DOMAIN {
+		entries = reqArgs.AcceptedDomains
+	}
+	for _, entry := range entries {
+		if host, err := domain.Hostname(entry); err == nil {
+			sched.acceptedDomainMap.Add(host)
+		}
+	}
+	log.L().Sugar().Infof("-- Scope: mode: %s, accepted: %v", sched.scopeMode, entries)
+}
+
+// 获取给定主机在范围内的键
+// 主域名模式下为主域名，其他模式下为去掉端口的主机名
+func (sched *myScheduler) scopeKey(host string) (string, error) {
+	if sched.scopeMode == SCOPE_PRIMARY_DOMAIN {
+		return getPrimaryDomain(host)
+	}
+	hostname, err := domain.Hostname(host)
+	if err != nil {
+		return "", genError(err.Error())
+	}
+	return hostname, nil
+}
+
+// 判断请求的主机是否在爬取范围内
+func (sched *myScheduler) inScope(httpReq *http.Request) bool {
+	key, err := sched.scopeKey(httpReq.Host)
+	if err != nil {
+		return false
+	}
+	if sched.acceptedDomainMap.Has(key) {
+		return true
+	}
+	if sched.scopeMode != SCOPE_SUBDOMAINS {
+		return false
+	}
+	for i := strings.Index(key, "."); i >= 0; i = strings.Index(key, ".") {
+		key = key[i+1:]
+		if sched.acceptedDomainMap.Has(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按范围模式把首个请求的主机加入爬取范围
+// 明确的主机名列表模式下不会扩大范围，但首个请求必须在范围内
+func (sched *myScheduler) addSeedToScope(httpReq *http.Request) error {
+	log.L().Sugar().Infof("-- Host: %s", httpReq.Host)
+	key, err := sched.scopeKey(httpReq.Host)
+	if err != nil {
+		return err
+	}
+	if sched.scopeMode == SCOPE_HOST_LIST {
+		if !sched.inScope(httpReq) {
+			errMsg := fmt.Sprintf("the host %q of the first request is out of scope", httpReq.Host)
+			return genParameterError(errMsg)
+		}
+		return nil
+	}
+	sched.acceptedDomainMap.Add(key)
+	log.L().Sugar().Infof("-- Accepted: %s", key)
+	return nil
+}
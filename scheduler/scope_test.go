@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+func TestScopeArgs(t *testing.T) {
+	illegalArgs := []ScopeArgs{
+		{Mode: "unknown"},
+		{Mode: SCOPE_HOST_LIST},
+		{Mode: SCOPE_EXACT_HOST, Hosts: []string{""}},
+	}
+	for _, args := range illegalArgs {
+		if err := args.Check(); err == nil {
+			t.Fatalf("No error when checking illegal scope args %#v!", args)
+		}
+	}
+}
+
+func TestScopeModes(t *testing.T) {
+	urls := []string{
+		"http://example.com/",
+		"http://www.example.com/",
+		"http://a.tenant.example.com/",
+		"http://example.org/",
+		"http://other.com/",
+	}
+	cases := []struct {
+		scope ScopeArgs
+		seed  string
+		// 各 URL 是否被接受
+		accepted []bool
+		// 首个请求是否会被拒绝
+		startErr bool
+	}{
+		{ScopeArgs{}, "http://www.example.com:8080/",
+			[]bool{true, true, true, false, false}, false},
+		{ScopeArgs{Mode: SCOPE_SUBDOMAINS}, "http://www.example.com/",
+			[]bool{false, true, false, false, false}, false},
+		{ScopeArgs{Mode: SCOPE_SUBDOMAINS, Hosts: []string{"tenant.example.com"}}, "http://example.org/",
+			[]bool{false, false, true, true, false}, false},
+		{ScopeArgs{Mode: SCOPE_EXACT_HOST, Hosts: []string{"Other.com:80"}}, "http://example.com/",
+			[]bool{true, false, false, false, true}, false},
+		{ScopeArgs{Mode: SCOPE_HOST_LIST, Hosts: []string{"example.com", "example.org"}}, "http://example.com/",
+			[]bool{true, false, false, true, false}, false},
+		{ScopeArgs{Mode: SCOPE_HOST_LIST, Hosts: []string{"example.org"}}, "http://example.com/",
+			nil, true},
+	}
+	for _, c := range cases {
+		requestArgs := genRequestArgs([]string{}, 1)
+		requestArgs.Scope = c.scope
+		sched := NewScheduler()
+		if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+			t.Fatalf("An error occurs when initializing scheduler: %s", err)
+		}
+		mySched := sched.(*myScheduler)
+		seedReq, _ := http.NewRequest("GET", c.seed, nil)
+		err := mySched.addSeedToScope(seedReq)
+		if c.startErr {
+			if err == nil {
+				t.Fatalf("No error when the first request is out of scope! (scope: %#v)", c.scope)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("An error occurs when adding the first request to scope: %s (scope: %#v)",
+				err, c.scope)
+		}
+		for i, u := range urls {
+			httpReq, _ := http.NewRequest("GET", u, nil)
+			if accepted := mySched.sendReq(module.NewRequest(httpReq, 0)); accepted != c.accepted[i] {
+				t.Fatalf("Inconsistent result: expected: %v, actual: %v (URL: %s, scope: %#v)",
+					c.accepted[i], accepted, u, c.scope)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// Seed 代表爬取的起点。
+type Seed struct {
+	// 起点的 HTTP 请求
+	HTTPReq *http.Request
+	// 从该起点出发的最大爬取深度，为 nil 时使用 RequestArgs.MaxDepth
+	MaxDepth *uint32
+	// 起点请求的元数据
+	Meta module.Metadata
+}
+
+// 生成起点对应的请求
+func (seed Seed) request() *module.Request {
+	req := module.NewRequest(seed.HTTPReq, 0)
+	req.SetMeta(seed.Meta.Clone())
+	if seed.MaxDepth != nil {
+		req.SetMaxDepth(*seed.MaxDepth)
+	}
+	return req
+}
+
+// 起点文件中的 JSON 记录
+type seedRecord struct {
+	URL string `json:"url"`
+	// 请求方法，为空时使用 GET
+	Method   string            `json:"method"`
+	MaxDepth *uint32           `json:"max_depth"`
+	Headers  map[string]string `json:"headers"`
+	Meta     module.Metadata   `json:"meta"`
+}
+
+// LoadSeeds 用于从 reader 中读取起点列表。
+// 每行一个起点，可以是 URL，也可以是形如
+// {"url": "...", "method": "GET", "max_depth": 2, "headers": {"Cookie": "..."}, "meta": {...}}
+// 的 JSON 对象。
+// 空行以及以 # 开头的行会被忽略。
+func LoadSeeds(reader io.Reader) ([]Seed, error) {
+	var seeds []Seed
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record := seedRecord{URL: line}
+		if strings.HasPrefix(line, "{") {
+			record = seedRecord{}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				return nil, genParameterError(
+					fmt.Sprintf("illegal seed at line %d: %s", lineNumber, err))
+			}
+		}
+		seed, err := record.seed()
+		if err != nil {
+			return nil, genParameterError(
+				fmt.Sprintf("illegal seed at line %d: %s", lineNumber, err))
+		}
+		seeds = append(seeds, seed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, genErrorByError(err)
+	}
+	return seeds, nil
+}
+
+// 把 JSON 记录转换为起点
+func (record seedRecord) seed() (Seed, error) {
+	if record.URL == "" {
+		return Seed{}, fmt.Errorf("empty URL")
+	}
+	method := record.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	httpReq, err := http.NewRequest(method, record.URL, nil)
+	if err != nil {
+		return Seed{}, err
+	}
+	for key, value := range record.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	return Seed{HTTPReq: httpReq, MaxDepth: record.MaxDepth, Meta: record.Meta}, nil
+}
+
+// LoadSeedFile 用于从文件中读取起点列表，文件格式见 LoadSeeds。
+func LoadSeedFile(path string) ([]Seed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, genParameterError(fmt.Sprintf("couldn't open seed file: %s", err))
+	}
+	defer file.Close()
+	return LoadSeeds(file)
+}
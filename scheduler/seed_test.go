@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLoadSeeds(t *testing.T) {
+	content := `
+# 测试用的起点文件
+http://a.com/
+{"url": "http://b.com/x", "max_depth": 2, "headers": {"Cookie": "k=v"}, "meta": {"category": "books"}}
+{"url": "http://c.com/", "method": "HEAD", "max_depth": 0}
+`
+	seeds, err := LoadSeeds(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("An error occurs when loading seeds: %s", err)
+	}
+	if len(seeds) != 3 {
+		t.Fatalf("Inconsistent seed number: expected: %d, actual: %d", 3, len(seeds))
+	}
+	if seeds[0].HTTPReq.URL.String() != "http://a.com/" || seeds[0].MaxDepth != nil {
+		t.Fatalf("Inconsistent seed: %#v", seeds[0])
+	}
+	if category, _ := seeds[1].Meta.String("category"); category != "books" ||
+		seeds[1].HTTPReq.Header.Get("Cookie") != "k=v" ||
+		seeds[1].MaxDepth == nil || *seeds[1].MaxDepth != 2 {
+		t.Fatalf("Inconsistent seed: %#v", seeds[1])
+	}
+	if seeds[2].HTTPReq.Method != http.MethodHead ||
+		seeds[2].MaxDepth == nil || *seeds[2].MaxDepth != 0 {
+		t.Fatalf("Inconsistent seed: %#v", seeds[2])
+	}
+	illegalContents := []string{
+		"{\"url\": ",
+		"{\"max_depth\": 1}",
+		"http://a.com/\n:bad",
+	}
+	for _, content := range illegalContents {
+		if _, err := LoadSeeds(strings.NewReader(content)); err == nil {
+			t.Fatalf("No error when loading illegal seeds %q!", content)
+		}
+	}
+	if _, err := LoadSeedFile(t.TempDir() + "/none.txt"); err == nil {
+		t.Fatal("No error when loading a nonexistent seed file!")
+	}
+}
+
+func TestStartWith(t *testing.T) {
+	server1, hits1 := genRobotsServer(http.StatusNotFound, "")
+	defer server1.Close()
+	server2, hits2 := genRobotsServer(http.StatusNotFound, "")
+	defer server2.Close()
+
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Scope = ScopeArgs{Mode: SCOPE_EXACT_HOST}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	if err := sched.StartWith(nil); err == nil {
+		t.Fatal("No error when starting scheduler without seeds!")
+	}
+	content := server1.URL + "/\n" +
+		`{"url": "` + server2.URL + `/", "max_depth": 0}` + "\n"
+	seeds, err := LoadSeeds(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("An error occurs when loading seeds: %s", err)
+	}
+	if err := sched.StartWith(seeds); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	if result := hits1(); result["/"] != 1 || result["/public"] != 1 || result["/private/a"] != 1 {
+		t.Fatalf("Some pages of the first seed were not crawled! (hits: %v)", result)
+	}
+	// 第二个起点的最大爬取深度为 0，只会下载起点本身。
+	if result := hits2(); result["/"] != 1 || result["/public"] != 0 {
+		t.Fatalf("The max depth of the second seed was not applied! (hits: %v)", result)
+	}
+}
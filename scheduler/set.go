@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// 字符串集合的分段数量
+const stringSetShardNumber = 16
+
+// 并发安全的字符串集合
+// 与 cmap.ConcurrentMap 不同，它支持遍历，以便生成检查点
+type stringSet struct {
+	shards [stringSetShardNumber]stringSetShard
+	// 元素总数
+	total uint64
+}
+
+// 字符串集合的分段
+type stringSetShard struct {
+	elements map[string]struct{}
+	rwlock   sync.RWMutex
+}
+
+func newStringSet() *stringSet {
+	set := &stringSet{}
+	for i := range set.shards {
+		set.shards[i].elements = map[string]struct{}{}
+	}
+	return set
+}
+
+// 获取给定元素所在的分段
+func (set *stringSet) shard(element string) *stringSetShard {
+	h := fnv.New32a()
+	h.Write([]byte(element))
+	return &set.shards[h.Sum32()%stringSetShardNumber]
+}
+
+// Add 用于添加元素，结果值代表是否新增了元素。
+func (set *stringSet) Add(element string) bool {
+	shard := set.shard(element)
+	shard.rwlock.Lock()
+	defer shard.rwlock.Unlock()
+	if _, ok := shard.elements[element]; ok {
+		return false
+	}
+	shard.elements[element] = struct{}{}
+	atomic.AddUint64(&set.total, 1)
+	return true
+}
+
+// Has 用于判断集合中是否包含给定元素。
+func (set *stringSet) Has(element string) bool {
+	shard := set.shard(element)
+	shard.rwlock.RLock()
+	defer shard.rwlock.RUnlock()
+	_, ok := shard.elements[element]
+	return ok
+}
+
+// Len 用于获取元素总数。
+func (set *stringSet) Len() uint64 {
+	return atomic.LoadUint64(&set.total)
+}
+
+// Elements 用于获取所有元素的快照，元素的顺序是不确定的。
+func (set *stringSet) Elements() []string {
+	elements := make([]string, 0, set.Len())
+	for i := range set.shards {
+		shard := &set.shards[i]
+		shard.rwlock.RLock()
+		for element := range shard.elements {
+			elements = append(elements, element)
+		}
+		shard.rwlock.RUnlock()
+	}
+	return elements
+}
+
+// 并发安全的请求集合，以请求的 URL 为键
+type requestSet struct {
+	requests map[string]*module.Request
+	lock     sync.Mutex
+}
+
+func newRequestSet() *requestSet {
+	return &requestSet{requests: map[string]*module.Request{}}
+}
+
+// Add 用于添加请求，已存在的同键请求会被替换。
+func (set *requestSet) Add(key string, req *module.Request) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	set.requests[key] = req
+}
+
+// Remove 用于删除给定键的请求。
+func (set *requestSet) Remove(key string) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	delete(set.requests, key)
+}
+
+// Len 用于获取请求总数。
+func (set *requestSet) Len() int {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	return len(set.requests)
+}
+
+// Requests 用于获取所有请求的快照，请求的顺序是不确定的。
+func (set *requestSet) Requests() []*module.Request {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	requests := make([]*module.Request, 0, len(set.requests))
+	for _, req := range set.requests {
+		requests = append(requests, req)
+	}
+	return requests
+}
+
+// 获取请求在待处理集合中的键
+func pendingKey(req *module.Request) string {
+	return req.HTTPReq().URL.String()
+}
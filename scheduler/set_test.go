@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+func TestStringSet(t *testing.T) {
+	set := newStringSet()
+	number := 1000
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < number; j++ {
+				set.Add(fmt.Sprintf("element-%d", j))
+			}
+		}()
+	}
+	wg.Wait()
+	if set.Len() != uint64(number) {
+		t.Fatalf("Inconsistent set length: expected: %d, actual: %d",
+			number, set.Len())
+	}
+	if set.Add("element-1") {
+		t.Fatal("It still can add repeated element!")
+	}
+	if !set.Has("element-1") || set.Has("element-x") {
+		t.Fatal("Inconsistent result of has!")
+	}
+	elements := set.Elements()
+	if len(elements) != number {
+		t.Fatalf("Inconsistent element number: expected: %d, actual: %d",
+			number, len(elements))
+	}
+	sort.Strings(elements)
+	for i := 1; i < len(elements); i++ {
+		if elements[i] == elements[i-1] {
+			t.Fatalf("Repeated element: %s", elements[i])
+		}
+	}
+}
+
+func TestRequestSet(t *testing.T) {
+	set := newRequestSet()
+	for i := 0; i < 3; i++ {
+		httpReq, _ := http.NewRequest("GET", fmt.Sprintf("http://example.com/%d", i), nil)
+		req := module.NewRequest(httpReq, uint32(i))
+		set.Add(pendingKey(req), req)
+	}
+	set.Remove("http://example.com/1")
+	set.Remove("http://example.com/x")
+	if set.Len() != 2 {
+		t.Fatalf("Inconsistent request number: expected: %d, actual: %d",
+			2, set.Len())
+	}
+	for _, req := range set.Requests() {
+		if pendingKey(req) == "http://example.com/1" {
+			t.Fatal("The removed request still exists!")
+		}
+	}
+}
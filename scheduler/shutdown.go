@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/log"
+)
+
+// 平稳停止时检查处理进度的间隔
+const drainCheckInterval = 10 * time.Millisecond
+
+// 判断调度器是否正在平稳停止
+func (sched *myScheduler) isDraining() bool {
+	return atomic.LoadUint32(&sched.draining) == 1
+}
+
+// 判断除请求之外的数据是否都已处理完毕
+func (sched *myScheduler) drained() bool {
+	return sched.downloadWorkers.Active() == 0 &&
+		sched.analyzeWorkers.Pending() == 0 &&
+		sched.pickWorkers.Pending() == 0
+}
+
+func (sched *myScheduler) Shutdown(ctx context.Context) (abandoned uint64, err error) {
+	log.L().Sugar().Info("Shutdown scheduler...")
+	// 检查状态
+	log.L().Sugar().Info("Check status for shutdown...")
+	var oldStatus Status
+	oldStatus, err = sched.checkAndSetStatus(SCHED_STATUS_STOPPING)
+	defer func() {
+		sched.statusLock.Lock()
+		if err != nil {
+			sched.status = oldStatus
+		} else {
+			sched.status = SCHED_STATUS_STOPPED
+		}
+		sched.statusLock.Unlock()
+	}()
+	if err != nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// 不再接受新的请求，并让已暂停的调度器继续处理剩余的数据
+	atomic.StoreUint32(&sched.draining, 1)
+	sched.gate.resume()
+	log.L().Sugar().Info("Drain in-flight responses and items...")
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	timeout := false
+	for !timeout && !sched.drained() {
+		select {
+		case <-ctx.Done():
+			timeout = true
+		case <-ticker.C:
+		}
+	}
+	if timeout {
+		// 正在处理的条目不会被中断，因此不计入其中
+		if n := sched.pickWorkers.Pending() - int64(sched.pickWorkers.Active()); n > 0 {
+			abandoned = uint64(n)
+		}
+		log.L().Sugar().Warnf("The context is done before draining: %s (abandoned items: %d, abandoned responses: %d)",
+			ctx.Err(), abandoned, sched.analyzeWorkers.Pending())
+	}
+	log.L().Sugar().Infof("-- Unsent requests: %d", sched.frontier.Total())
+	sched.stop()
+	log.L().Sugar().Info("Scheduler has been shut down.")
+	return abandoned, nil
+}
@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/module/local/pipeline"
+)
+
+// startShutdownCrawl 用于启动一个条目处理较慢的调度器，
+// 并在首页分析完毕后返回。
+// 参数 processed 用于记录已处理的条目数。
+func startShutdownCrawl(
+	itemDelay time.Duration, processed *int32, t *testing.T) (Scheduler, *httptest.Server) {
+	pageNumber := 10
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+		for i := 0; i < pageNumber; i++ {
+			fmt.Fprintf(w, `<a href="/page%d">page%d</a>`, i, i)
+		}
+	}))
+	processor := func(item module.Item) (module.Item, error) {
+		time.Sleep(itemDelay)
+		atomic.AddInt32(processed, 1)
+		return item, nil
+	}
+	snGen := module.NewSNGenertor(1, 0)
+	p, err := pipeline.New(module.MID(fmt.Sprintf("P%d", snGen.Get())),
+		[]module.ProcessItem{processor}, nil)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a pipeline: %s", err)
+	}
+	moduleArgs := ModuleArgs{
+		Downloaders: genSimpleDownloaders(1, false, snGen, t),
+		Analyzers:   genSimpleAnalyzers(1, false, snGen, t),
+		Pipelines:   []module.Pipeline{p},
+	}
+	sched := NewScheduler()
+	if err := sched.Init(genRequestArgs([]string{}, 1), genDataArgs(10, 2, 1), moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	mySched := sched.(*myScheduler)
+	for i := 0; mySched.urlMap.Len() < uint64(pageNumber+1); i++ {
+		if i >= 300 {
+			t.Fatal("Timeout when waiting for the first page!")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return sched, server
+}
+
+func TestShutdownDrain(t *testing.T) {
+	var processed int32
+	sched, server := startShutdownCrawl(10*time.Millisecond, &processed, t)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	abandoned, err := sched.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("An error occurs when shutting down scheduler: %s", err)
+	}
+	if abandoned != 0 {
+		t.Fatalf("Inconsistent abandoned item number: expected: %d, actual: %d",
+			0, abandoned)
+	}
+	// 首页中的每个链接都会生成一个条目。
+	if p := atomic.LoadInt32(&processed); p < 10 {
+		t.Fatalf("Some items were not processed: expected: >= %d, actual: %d",
+			10, p)
+	}
+	if status := sched.Status(); status != SCHED_STATUS_STOPPED {
+		t.Fatalf("Inconsistent status: expected: %q, actual: %q",
+			GetStatusDescription(SCHED_STATUS_STOPPED), GetStatusDescription(status))
+	}
+	if _, err := sched.Shutdown(ctx); err == nil {
+		t.Fatal("It still can shut down a stopped scheduler!")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	var processed int32
+	sched, server := startShutdownCrawl(200*time.Millisecond, &processed, t)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	abandoned, err := sched.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("An error occurs when shutting down scheduler: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("The shutdown was not bounded by the context: %s", elapsed)
+	}
+	if abandoned == 0 {
+		t.Fatal("No item was abandoned when the context is done!")
+	}
+	p := uint64(atomic.LoadInt32(&processed))
+	if p+abandoned > 10 {
+		t.Fatalf("Inconsistent item number: processed: %d, abandoned: %d",
+			p, abandoned)
+	}
+}
+
+func TestShutdownIncorrectData(t *testing.T) {
+	var processed int32
+	sched, server := startShutdownCrawl(0, &processed, t)
+	defer server.Close()
+	// 类型不正确的数据也应被视为已处理完毕
+	mySched := sched.(*myScheduler)
+	mySched.analyzeWorkers.incrPending()
+	mySched.respBufferPool.Put("incorrect response")
+	mySched.pickWorkers.incrPending()
+	mySched.itemBufferPool.Put("incorrect item")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	abandoned, err := sched.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("An error occurs when shutting down scheduler: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second || abandoned != 0 {
+		t.Fatalf("The incorrect data was not drained: elapsed: %s, abandoned: %d",
+			elapsed, abandoned)
+	}
+}
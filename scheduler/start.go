@@ -5,10 +5,16 @@ import (
 	"net/http"
 
 	"github.com/dokidokikoi/webcrawler/log"
-	"github.com/dokidokikoi/webcrawler/module"
 )
 
 func (sched *myScheduler) Start(firstHTTPReq *http.Request) (err error) {
+	if firstHTTPReq == nil {
+		return genParameterError("nil first HTTP request")
+	}
+	return sched.StartWith([]Seed{{HTTPReq: firstHTTPReq}})
+}
+
+func (sched *myScheduler) StartWith(seeds []Seed) (err error) {
 	defer func() {
 		if p := recover(); p != nil {
 			errMsg := fmt.Sprintf("Fatal scheduler error: %s", p)
@@ -36,34 +42,51 @@ func (sched *myScheduler) Start(firstHTTPReq *http.Request) (err error) {
 	}
 
 	// 检查参数
-	log.L().Sugar().Info("Check first HTTP request...")
-	if firstHTTPReq == nil {
-		err = genParameterError("nil first HTTP request")
+	log.L().Sugar().Info("Check seeds...")
+	if len(seeds) == 0 {
+		err = genParameterError("empty seed list")
 		return
 	}
-	log.L().Sugar().Info("The first HTTP request is valid.")
-	// 获得首次请求的主域名，并将其添加到可接受的主域名的字典
-	log.L().Sugar().Info("Get the primary domain...")
-	log.L().Sugar().Info("-- Host: $s", firstHTTPReq.Host)
-	var primaryDomain string
-	primaryDomain, err = getPrimaryDomain(firstHTTPReq.Host)
-	if err != nil {
+	for i, seed := range seeds {
+		if seed.HTTPReq == nil || seed.HTTPReq.URL == nil {
+			err = genParameterError(fmt.Sprintf("invalid HTTP request of seed #%d", i+1))
+			return
+		}
+	}
+	log.L().Sugar().Infof("The seeds are valid. (number: %d)", len(seeds))
+	// 按范围模式把各起点的主机加入爬取范围
+	log.L().Sugar().Info("Add seeds to scope...")
+	var accepted []Seed
+	for _, seed := range seeds {
+		if scopeErr := sched.addSeedToScope(seed.HTTPReq); scopeErr != nil {
+			log.L().Sugar().Warnf("Ignore the seed! %s (URL: %s)", scopeErr, seed.HTTPReq.URL)
+			continue
+		}
+		accepted = append(accepted, seed)
+	}
+	if len(accepted) == 0 {
+		err = genParameterError("no seed is in scope")
 		return
 	}
-	log.L().Sugar().Info("-- Primary domain: %s", primaryDomain)
-	sched.acceptedDomainMap.Put(primaryDomain, struct{}{})
 
 	// 开始调度数据和组件
 	if err = sched.checkBufferPoolForStart(); err != nil {
 		return
 	}
-	sched.download()
-	sched.analyze()
-	sched.pick()
+	sched.startWorkers()
 	log.L().Sugar().Info("Scheduler has been started.")
 
-	// 放入第一个请求
-	firstReq := module.NewRequest(firstHTTPReq, 0)
-	sched.sendReq(firstReq)
+	// 放入各起点的请求
+	for _, seed := range accepted {
+		sched.sendReq(seed.request())
+	}
 	return nil
 }
+
+// 启动各处理阶段的工作协程以及定期生成检查点的协程
+func (sched *myScheduler) startWorkers() {
+	sched.download()
+	sched.analyze()
+	sched.pick()
+	sched.checkpointLoop()
+}
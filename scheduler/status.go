@@ -17,7 +17,10 @@ type Status uint8
 // 调度器可以被再初始化，但是必须在未启动的情况下。
 // 调用运行中调度器的 Start 方法是不会成功的
 //
-// 仅当调度器处于已启动状态时，才能被停止。
+// 仅当调度器处于已启动、正在暂停或已暂停状态时，才能被停止。
+//
+// 仅当调度器处于已启动状态时，才能被暂停。
+// 仅当调度器处于已暂停状态时，才能被恢复。
 const (
 	// 未初始化状态
 	SCHED_STATUS_UNINITIALIZED Status = iota
@@ -33,17 +36,26 @@ const (
 	SCHED_STATUS_STOPPING
 	// 已停止
 	SCHED_STATUS_STOPPED
+	// 暂停中
+	SCHED_STATUS_PAUSING
+	// 已暂停
+	SCHED_STATUS_PAUSED
+	// 恢复中
+	SCHED_STATUS_RESUMING
 )
 
 // checkStatus 用于状态的检查。
 // 参数currentStatus代表当前的状态。
 // 参数wantedStatus代表想要的状态。
 // 检查规则：
-//  1. 处于正在初始化、正在启动或正在停止状态时，不能从外部改变状态。
-//  2. 想要的状态只能是正在初始化、正在启动或正在停止状态中的一个。
+//  1. 处于正在初始化、正在启动、正在停止、正在暂停或正在恢复状态时，不能从外部改变状态，
+//     但处于正在暂停状态时可以变为正在停止状态，以免暂停时等待的处理一直不结束。
+//  2. 想要的状态只能是正在初始化、正在启动、正在停止、正在暂停或正在恢复状态中的一个。
 //  3. 处于未初始化状态时，不能变为正在启动或正在停止状态。
-//  4. 处于已启动状态时，不能变为正在初始化或正在启动状态。
-//  5. 只要未处于已启动状态就不能变为正在停止状态。
+//  4. 处于已启动或已暂停状态时，不能变为正在初始化或正在启动状态。
+//  5. 只要未处于已启动、正在暂停或已暂停状态就不能变为正在停止状态。
+//  6. 只要未处于已启动状态就不能变为正在暂停状态。
+//  7. 只要未处于已暂停状态就不能变为正在恢复状态。
 func checkStatus(
 	currentStatus Status,
 	wantedStatus Status,
@@ -59,6 +71,12 @@ func checkStatus(
 		err = genError("the scheduler is being started!")
 	case SCHED_STATUS_STOPPING:
 		err = genError("the scheduler is being stopped!")
+	case SCHED_STATUS_PAUSING:
+		if wantedStatus != SCHED_STATUS_STOPPING {
+			err = genError("the scheduler is being paused!")
+		}
+	case SCHED_STATUS_RESUMING:
+		err = genError("the scheduler is being resumed!")
 	}
 	if err != nil {
 		return
@@ -74,6 +92,8 @@ func checkStatus(
 		switch currentStatus {
 		case SCHED_STATUS_STARTED:
 			err = genError("the scheduler has been started!")
+		case SCHED_STATUS_PAUSED:
+			err = genError("the scheduler has been paused!")
 		}
 	case SCHED_STATUS_STARTING:
 		switch currentStatus {
@@ -81,11 +101,23 @@ func checkStatus(
 			err = genError("the scheduler has not been initialized!")
 		case SCHED_STATUS_STARTED:
 			err = genError("the scheduler has been started!")
+		case SCHED_STATUS_PAUSED:
+			err = genError("the scheduler has been paused!")
 		}
 	case SCHED_STATUS_STOPPING:
+		if currentStatus != SCHED_STATUS_STARTED &&
+			currentStatus != SCHED_STATUS_PAUSING &&
+			currentStatus != SCHED_STATUS_PAUSED {
+			err = genError("the scheduler has not been started!")
+		}
+	case SCHED_STATUS_PAUSING:
 		if currentStatus != SCHED_STATUS_STARTED {
 			err = genError("the scheduler has not been started!")
 		}
+	case SCHED_STATUS_RESUMING:
+		if currentStatus != SCHED_STATUS_PAUSED {
+			err = genError("the scheduler has not been paused!")
+		}
 	default:
 		errMsg :=
 			fmt.Sprintf("unsupported wanted status for check! (wantedStatus: %d)",
@@ -112,6 +144,12 @@ func GetStatusDescription(status Status) string {
 		return "stopping"
 	case SCHED_STATUS_STOPPED:
 		return "stopped"
+	case SCHED_STATUS_PAUSING:
+		return "pausing"
+	case SCHED_STATUS_PAUSED:
+		return "paused"
+	case SCHED_STATUS_RESUMING:
+		return "resuming"
 	default:
 		return "unknown"
 	}
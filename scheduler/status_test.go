@@ -8,11 +8,13 @@ import (
 func TestCheckStatus(t *testing.T) {
 	var currentStatus, wantedStatus Status
 	var currentStatusList, wantedStatusList []Status
-	// 1.处于正在初始化、正在启动和正在停止状态时，不能有任何的状态改变。
+	// 1.处于正在初始化、正在启动、正在停止、正在暂停和正在恢复状态时，不能有任何的状态改变。
 	currentStatusList = []Status{
 		SCHED_STATUS_INITIALIZING,
 		SCHED_STATUS_STARTING,
 		SCHED_STATUS_STOPPING,
+		SCHED_STATUS_PAUSING,
+		SCHED_STATUS_RESUMING,
 	}
 	wantedStatus = SCHED_STATUS_INITIALIZING
 	for _, currentStatus := range currentStatusList {
@@ -28,6 +30,7 @@ func TestCheckStatus(t *testing.T) {
 		SCHED_STATUS_INITIALIZED,
 		SCHED_STATUS_STARTED,
 		SCHED_STATUS_STOPPED,
+		SCHED_STATUS_PAUSED,
 	}
 	for _, wantedStatus := range wantedStatusList {
 		if err := checkStatus(currentStatus, wantedStatus, nil); err == nil {
@@ -69,7 +72,7 @@ func TestCheckStatus(t *testing.T) {
 		t.Fatalf("An error occurs when checking status: %s (currentStatus: %q, wantedStatus: %q)!",
 			err, GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
 	}
-	// 5. 只要未处于已启动状态就不能变为正在停止状态。
+	// 5. 只要未处于已启动、正在暂停或已暂停状态就不能变为正在停止状态。
 	currentStatusList = []Status{
 		SCHED_STATUS_UNINITIALIZED,
 		SCHED_STATUS_INITIALIZING,
@@ -85,10 +88,37 @@ func TestCheckStatus(t *testing.T) {
 				GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
 		}
 	}
-	currentStatus = SCHED_STATUS_STARTED
-	if err := checkStatus(currentStatus, wantedStatus, nil); err != nil {
-		t.Fatalf("An error occurs when checking status: %s (currentStatus: %q, wantedStatus: %q)!",
-			err, GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+	for _, currentStatus := range []Status{SCHED_STATUS_STARTED, SCHED_STATUS_PAUSING, SCHED_STATUS_PAUSED} {
+		if err := checkStatus(currentStatus, wantedStatus, nil); err != nil {
+			t.Fatalf("An error occurs when checking status: %s (currentStatus: %q, wantedStatus: %q)!",
+				err, GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+		}
+	}
+	// 6. 只要未处于已启动状态就不能变为正在暂停状态。
+	// 7. 只要未处于已暂停状态就不能变为正在恢复状态。
+	expectedCurrentStatus := map[Status]Status{
+		SCHED_STATUS_PAUSING:  SCHED_STATUS_STARTED,
+		SCHED_STATUS_RESUMING: SCHED_STATUS_PAUSED,
+	}
+	for wantedStatus, expected := range expectedCurrentStatus {
+		for currentStatus := SCHED_STATUS_UNINITIALIZED; currentStatus <= SCHED_STATUS_RESUMING; currentStatus++ {
+			err := checkStatus(currentStatus, wantedStatus, nil)
+			if currentStatus == expected && err != nil {
+				t.Fatalf("An error occurs when checking status: %s (currentStatus: %q, wantedStatus: %q)!",
+					err, GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+			}
+			if currentStatus != expected && err == nil {
+				t.Fatalf("It still can check status with current status %q wanted status %q!",
+					GetStatusDescription(currentStatus), GetStatusDescription(wantedStatus))
+			}
+		}
+	}
+	// 处于已暂停状态时，不能变为正在初始化和正在启动状态。
+	for _, wantedStatus := range []Status{SCHED_STATUS_INITIALIZING, SCHED_STATUS_STARTING} {
+		if err := checkStatus(SCHED_STATUS_PAUSED, wantedStatus, nil); err == nil {
+			t.Fatalf("It still can check status with current status %q wanted status %q!",
+				GetStatusDescription(SCHED_STATUS_PAUSED), GetStatusDescription(wantedStatus))
+		}
 	}
 }
 
@@ -156,7 +186,10 @@ func TestGetStatusDescription(t *testing.T) {
 		SCHED_STATUS_STARTED:       "started",
 		SCHED_STATUS_STOPPING:      "stopping",
 		SCHED_STATUS_STOPPED:       "stopped",
-		Status(7):                  "unknown",
+		SCHED_STATUS_PAUSING:       "pausing",
+		SCHED_STATUS_PAUSED:        "paused",
+		SCHED_STATUS_RESUMING:      "resuming",
+		Status(10):                 "unknown",
 	}
 	for status, expectedDesc := range statusMap {
 		desc := GetStatusDescription(status)
@@ -7,6 +7,8 @@ import (
 	"github.com/dokidokikoi/webcrawler/log"
 	"github.com/dokidokikoi/webcrawler/module"
 	"github.com/dokidokikoi/webcrawler/toolkit/buffer"
+	"github.com/dokidokikoi/webcrawler/toolkit/limiter"
+	"github.com/dokidokikoi/webcrawler/toolkit/urlfilter"
 )
 
 // ModuleArgsSummary 代表组件相关的参数容器的摘要类型。
@@ -24,6 +26,12 @@ type BufferPoolSummaryStruct struct {
 	Total           uint64 `json:"total"`
 }
 
+// WorkerSummaryStruct 代表某个处理阶段工作协程的摘要类型。
+type WorkerSummaryStruct struct {
+	Number uint32 `json:"number"`
+	Active uint32 `json:"active"`
+}
+
 // 表示调度器摘要的结构
 type SummaryStruct struct {
 	RequestArgs     RequestArgs             `json:"request_args"`
@@ -33,11 +41,22 @@ type SummaryStruct struct {
 	Downloaders     []module.SummaryStruct  `json:"downloaders"`
 	Analyzers       []module.SummaryStruct  `json:"analyzers"`
 	Pipelines       []module.SummaryStruct  `json:"pipelines"`
-	ReqBufferPool   BufferPoolSummaryStruct `json:"request_buffer_pool"`
+	Frontier        FrontierSummaryStruct   `json:"frontier"`
 	RespBufferPool  BufferPoolSummaryStruct `json:"response_buffer_pool"`
 	ItemBufferPool  BufferPoolSummaryStruct `json:"item_buffer_pool"`
 	ErrorBufferPool BufferPoolSummaryStruct `json:"error_buffer_pool"`
-	NumURL          uint64                  `json:"url_number"`
+	DownloadWorkers WorkerSummaryStruct     `json:"download_workers"`
+	AnalyzeWorkers  WorkerSummaryStruct     `json:"analyze_workers"`
+	PickWorkers     WorkerSummaryStruct     `json:"pick_workers"`
+	Downloads       DownloadSummaryStruct   `json:"downloads"`
+	Errors          ErrorSummaryStruct      `json:"errors"`
+	// 各主机的访问限制情况，未启用限制时为 nil
+	Politeness map[string]limiter.HostSummary `json:"politeness,omitempty"`
+	// 各站点的 robots.txt 情况，未遵守 robots.txt 时为 nil
+	Robots map[string]RobotsSummaryStruct `json:"robots,omitempty"`
+	// 各 URL 过滤规则的命中统计，未设置规则时为 nil
+	URLRules []urlfilter.RuleStats `json:"url_rules,omitempty"`
+	NumURL   uint64                `json:"url_number"`
 }
 
 // Same 用于判断当前的调度器摘要与另一份是否相同。
@@ -78,7 +97,7 @@ func (one *SummaryStruct) Same(another SummaryStruct) bool {
 			return false
 		}
 	}
-	if another.ReqBufferPool != one.ReqBufferPool {
+	if another.Frontier != one.Frontier {
 		return false
 	}
 	if another.RespBufferPool != one.RespBufferPool {
@@ -90,6 +109,41 @@ func (one *SummaryStruct) Same(another SummaryStruct) bool {
 	if another.ErrorBufferPool != one.ErrorBufferPool {
 		return false
 	}
+	if another.DownloadWorkers != one.DownloadWorkers ||
+		another.AnalyzeWorkers != one.AnalyzeWorkers ||
+		another.PickWorkers != one.PickWorkers {
+		return false
+	}
+	if another.Downloads != one.Downloads {
+		return false
+	}
+	if !one.Errors.Same(another.Errors) {
+		return false
+	}
+	if len(another.Politeness) != len(one.Politeness) {
+		return false
+	}
+	for host, hs := range another.Politeness {
+		if ohs, ok := one.Politeness[host]; !ok || hs != ohs {
+			return false
+		}
+	}
+	if len(another.Robots) != len(one.Robots) {
+		return false
+	}
+	for site, rs := range another.Robots {
+		if ors, ok := one.Robots[site]; !ok || !rs.Same(ors) {
+			return false
+		}
+	}
+	if len(another.URLRules) != len(one.URLRules) {
+		return false
+	}
+	for i, rs := range another.URLRules {
+		if rs != one.URLRules[i] {
+			return false
+		}
+	}
 	if another.NumURL != one.NumURL {
 		return false
 	}
@@ -127,10 +181,18 @@ func (ss *mySchedSummary) Struct() SummaryStruct {
 		Downloaders:     getModuleSummaries(registrar, module.TYPE_DOWNLOADER),
 		Analyzers:       getModuleSummaries(registrar, module.TYPE_ANALYZER),
 		Pipelines:       getModuleSummaries(registrar, module.TYPE_PIPELINE),
-		ReqBufferPool:   getBufferPoolSummary(ss.sched.reqBufferPool),
+		Frontier:        getFrontierSummary(ss.sched.frontier, ss.sched.frontierArgs),
 		RespBufferPool:  getBufferPoolSummary(ss.sched.respBufferPool),
 		ItemBufferPool:  getBufferPoolSummary(ss.sched.itemBufferPool),
 		ErrorBufferPool: getBufferPoolSummary(ss.sched.errorBufferPool),
+		DownloadWorkers: getWorkerSummary(&ss.sched.downloadWorkers),
+		AnalyzeWorkers:  getWorkerSummary(&ss.sched.analyzeWorkers),
+		PickWorkers:     getWorkerSummary(&ss.sched.pickWorkers),
+		Downloads:       getDownloadSummary(&ss.sched.downloadStats),
+		Errors:          getErrorSummary(&ss.sched.errorStats),
+		Politeness:      getHostLimiterSummary(ss.sched.hostLimiter),
+		Robots:          getRobotsSummary(ss.sched.robots),
+		URLRules:        getURLRuleSummary(ss.sched.urlFilter),
 		NumURL:          ss.sched.urlMap.Len(),
 	}
 }
@@ -154,6 +216,30 @@ func getBufferPoolSummary(bufferPool buffer.Pool) BufferPoolSummaryStruct {
 	}
 }
 
+// getWorkerSummary 用于生成和返回某个处理阶段工作协程的摘要信息。
+func getWorkerSummary(counter *workerCounter) WorkerSummaryStruct {
+	return WorkerSummaryStruct{
+		Number: counter.Number(),
+		Active: counter.Active(),
+	}
+}
+
+// getHostLimiterSummary 用于获取主机访问限制器的摘要信息。
+func getHostLimiterSummary(hostLimiter limiter.HostLimiter) map[string]limiter.HostSummary {
+	if hostLimiter == nil {
+		return nil
+	}
+	return hostLimiter.Summary()
+}
+
+// getRobotsSummary 用于获取 robots.txt 缓存的摘要信息。
+func getRobotsSummary(cache *robotsCache) map[string]RobotsSummaryStruct {
+	if cache == nil {
+		return nil
+	}
+	return cache.summary()
+}
+
 // getModuleSummaries 用于获取已注册的某类组件的摘要。
 func getModuleSummaries(registrar module.Registrar, mType module.Type) []module.SummaryStruct {
 	moduleMap, _ := registrar.GetAllByType(mType)
@@ -132,12 +132,12 @@ func TestSummaryStruct(t *testing.T) {
 	}
 	another.Pipelines = make([]module.SummaryStruct, len(one.Pipelines))
 	copy(another.Pipelines, one.Pipelines)
-	// 不同的请求缓冲池摘要。
-	another.ReqBufferPool.Total = 10
+	// 不同的待爬取队列摘要。
+	another.Frontier.Total = 10
 	if one.Same(another) {
-		t.Fatalf("Same scheduler summaries with different request buffer summary!")
+		t.Fatalf("Same scheduler summaries with different frontier summary!")
 	}
-	another.ReqBufferPool = one.ReqBufferPool
+	another.Frontier = one.Frontier
 	// 不同的响应缓冲池摘要。
 	another.RespBufferPool.Total = 11
 	if one.Same(another) {
@@ -156,6 +156,12 @@ func TestSummaryStruct(t *testing.T) {
 		t.Fatalf("Same scheduler summaries with different error buffer summary!")
 	}
 	another.ErrorBufferPool = one.ErrorBufferPool
+	// 不同的工作协程摘要。
+	another.DownloadWorkers.Active = 15
+	if one.Same(another) {
+		t.Fatalf("Same scheduler summaries with different download workers summary!")
+	}
+	another.DownloadWorkers = one.DownloadWorkers
 	// 不同的URL数量。
 	another.NumURL = 14
 	if one.Same(another) {
@@ -185,7 +191,46 @@ func TestSummaryString(t *testing.T) {
 	expectedSummaryStr := `{
     "request_args": {
         "accepted_primary_domains": [],
-        "max_depth": 0
+        "scope": {
+            "mode": "",
+            "hosts": null
+        },
+        "max_depth": 0,
+        "politeness": {
+            "by_primary_domain": false,
+            "requests_per_second": 0,
+            "min_delay": 0,
+            "max_in_flight": 0
+        },
+        "robots": {
+            "enabled": false,
+            "user_agent": ""
+        },
+        "frontier": {
+            "policy": "",
+            "host_fair": false
+        },
+        "canonical": {
+            "enabled": false,
+            "keep_fragment": false,
+            "keep_query_order": false,
+            "strip_params": null
+        },
+        "retry": {
+            "max_attempts": 0,
+            "base_delay": 0,
+            "max_delay": 0,
+            "jitter": 0,
+            "status_codes": null
+        },
+        "timeout": 0,
+        "body_limit": {
+            "max_size": 0,
+            "max_size_by_type": null,
+            "discard": false,
+            "spill_threshold": 0
+        },
+        "url_rules": null
     },
     "data_args": {
         "req_buffer_cap": 10,
@@ -195,7 +240,17 @@ func TestSummaryString(t *testing.T) {
         "item_buffer_cap": 10,
         "item_max_buffer_number": 2,
         "error_buffer_cap": 10,
-        "error_max_buffer_number": 2
+        "error_max_buffer_number": 2,
+        "downloader_worker_number": 0,
+        "analyzer_worker_number": 0,
+        "pipeline_worker_number": 0,
+        "checkpoint_dir": "",
+        "checkpoint_interval": 0,
+        "dedup_type": "",
+        "dedup_capacity": 0,
+        "dedup_false_positive_rate": 0,
+        "dedup_dir": "",
+        "dead_letter_file": ""
     },
     "module_args": {
         "downloader_list_size": 2,
@@ -248,10 +303,10 @@ func TestSummaryString(t *testing.T) {
             }
         }
     ],
-    "request_buffer_pool": {
-        "buffer_cap": 10,
-        "max_buffer_number": 2,
-        "buffer_number": 1,
+    "frontier": {
+        "policy": "fifo",
+        "host_fair": false,
+        "cap": 20,
         "total": 0
     },
     "response_buffer_pool": {
@@ -272,6 +327,29 @@ func TestSummaryString(t *testing.T) {
         "buffer_number": 1,
         "total": 0
     },
+    "download_workers": {
+        "number": 1,
+        "active": 0
+    },
+    "analyze_workers": {
+        "number": 1,
+        "active": 0
+    },
+    "pick_workers": {
+        "number": 1,
+        "active": 0
+    },
+    "downloads": {
+        "responses": 0,
+        "bytes": 0,
+        "redirects": 0,
+        "truncated": 0,
+        "avg_ttfb": "0s",
+        "avg_duration": "0s"
+    },
+    "errors": {
+        "total": 0
+    },
     "url_number": 0
 }`
 	summaryStr := summary.String()
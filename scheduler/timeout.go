@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// 下载计时器，超时后会取消下载用的上下文
+// 只在下载以及读取响应体期间计时，响应在缓冲池中等待分析的时间不计入其中
+// 该类型的方法都是并发安全的
+type downloadTimer struct {
+	// 超时时间，为 0 时不限制
+	timeout time.Duration
+	// 取消下载用的上下文的函数
+	cancel context.CancelFunc
+	// 剩余的时间
+	remaining time.Duration
+	// 正在计时的定时器，未在计时时为 nil
+	timer *time.Timer
+	// 本次开始计时的时间
+	started time.Time
+	// 是否已超时
+	expired bool
+	// 是否已释放
+	released bool
+	lock     sync.Mutex
+}
+
+// 生成下载用的上下文以及已开始计时的计时器，上下文会在调度器停止时被取消
+// 请求元数据中的超时时间优先于调度器的超时时间
+func (sched *myScheduler) downloadContext(req *module.Request) (context.Context, *downloadTimer) {
+	timeout := sched.downloadTimeout
+	if d, ok := req.Meta().Duration(module.META_KEY_TIMEOUT); ok {
+		timeout = d
+	}
+	ctx, cancel := context.WithCancel(sched.ctx)
+	timer := &downloadTimer{
+		timeout:   timeout,
+		cancel:    cancel,
+		remaining: timeout,
+	}
+	timer.start()
+	return ctx, timer
+}
+
+// start 用于开始或继续计时。
+func (t *downloadTimer) start() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.timeout <= 0 || t.timer != nil || t.expired || t.released {
+		return
+	}
+	t.started = time.Now()
+	t.timer = time.AfterFunc(t.remaining, t.expire)
+}
+
+// stop 用于暂停计时。
+func (t *downloadTimer) stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.timer == nil {
+		return
+	}
+	if t.timer.Stop() {
+		t.remaining -= time.Since(t.started)
+	}
+	t.timer = nil
+}
+
+// expire 用于在超时后取消下载用的上下文。
+func (t *downloadTimer) expire() {
+	t.lock.Lock()
+	t.expired = true
+	t.timer = nil
+	t.lock.Unlock()
+	t.cancel()
+}
+
+// release 用于停止计时并释放下载用的上下文。
+func (t *downloadTimer) release() {
+	t.stop()
+	t.lock.Lock()
+	t.released = true
+	t.lock.Unlock()
+	t.cancel()
+}
+
+// check 用于在已超时的情况下把给定的错误值转换为超时错误。
+func (t *downloadTimer) check(err error) error {
+	if err == nil {
+		return nil
+	}
+	t.lock.Lock()
+	expired := t.expired
+	t.lock.Unlock()
+	if !expired {
+		return err
+	}
+	return fmt.Errorf("%w: download timeout %s exceeded (%s)",
+		context.DeadlineExceeded, t.timeout, err)
+}
+
+// 使下载计时器在读取响应体时继续计时，并在响应体被关闭时被释放
+// 响应为 nil 时会立即释放
+func timeBody(resp *module.Response, timer *downloadTimer) *module.Response {
+	if resp == nil || resp.HTTPResp() == nil || resp.HTTPResp().Body == nil {
+		timer.release()
+		return resp
+	}
+	httpResp := resp.HTTPResp()
+	httpResp.Body = &timedBody{httpResp.Body, timer}
+	return resp
+}
+
+// 读取时计时并在关闭时释放下载计时器的响应体
+type timedBody struct {
+	io.ReadCloser
+	timer *downloadTimer
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	b.timer.start()
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.timer.stop()
+	} else if err != nil {
+		err = b.timer.check(err)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.timer.release()
+	return err
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+func TestDownloadTimeout(t *testing.T) {
+	release := make(chan struct{})
+	canceled := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			canceled <- r.URL.Path
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	requestArgs := genRequestArgs([]string{}, 1)
+	requestArgs.Timeout = 100 * time.Millisecond
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.DownloaderWorkerNumber = 2
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, genSimpleModuleArgs(2, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	timeoutHTTPReq, _ := http.NewRequest("GET", server.URL+"/timeout", nil)
+	// 元数据中的超时时间为 0 时不限制
+	unlimitedHTTPReq, _ := http.NewRequest("GET", server.URL+"/unlimited", nil)
+	seeds := []Seed{
+		{HTTPReq: timeoutHTTPReq},
+		{HTTPReq: unlimitedHTTPReq, Meta: module.Metadata{module.META_KEY_TIMEOUT: "0s"}},
+	}
+	if err := sched.StartWith(seeds); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		summary := sched.Summary().Struct().Errors
+		if summary.ByKind[string(errors.ErrTimeout)] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Inconsistent error summary: %#v", summary)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// 停止调度器会立即中止正在进行的下载。
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	timer := time.After(2 * time.Second)
+	for {
+		select {
+		case path := <-canceled:
+			if path == "/unlimited" {
+				return
+			}
+		case <-timer:
+			t.Fatal("The download was not canceled when stopping scheduler!")
+		}
+	}
+}
+
+func TestDownloadTimerExcludesQueueTime(t *testing.T) {
+	sched := &myScheduler{ctx: context.Background(), downloadTimeout: 50 * time.Millisecond}
+	httpReq, _ := http.NewRequest("GET", "http://example.com/", nil)
+	ctx, timer := sched.downloadContext(module.NewRequest(httpReq, 0))
+	timer.stop()
+	// 响应等待分析的时间超过超时时间时不应超时
+	time.Sleep(100 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatalf("The download context was canceled while waiting: %s", ctx.Err())
+	}
+	httpResp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(blockingReader{ctx})}
+	resp := timeBody(module.NewResponse(httpResp, 0), timer)
+	_, err := resp.HTTPResp().Body.Read(make([]byte, 1))
+	if !stderrors.Is(err, context.DeadlineExceeded) ||
+		errors.Classify(err) != errors.ErrTimeout {
+		t.Fatalf("Expected a timeout error when reading the body, but got %v", err)
+	}
+	resp.HTTPResp().Body.Close()
+}
+
+// 在上下文被取消之前一直阻塞的读取器
+type blockingReader struct {
+	ctx context.Context
+}
+
+func (r blockingReader) Read(p []byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
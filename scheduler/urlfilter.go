@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"net/url"
+
+	"github.com/dokidokikoi/webcrawler/log"
+	"github.com/dokidokikoi/webcrawler/toolkit/urlfilter"
+)
+
+// 初始化 URL 过滤器
+func (sched *myScheduler) initURLFilter(rules []urlfilter.Rule) error {
+	sched.urlFilter = nil
+	if len(rules) == 0 {
+		log.L().Sugar().Info("-- URL rules: none")
+		return nil
+	}
+	f, err := urlfilter.New(rules)
+	if err != nil {
+		return genParameterError(err.Error())
+	}
+	sched.urlFilter = f
+	log.L().Sugar().Infof("-- URL rules: %d", len(rules))
+	return nil
+}
+
+// 判断 URL 是否被 URL 过滤规则允许
+// 结果值 index 代表首个匹配的规则的序号，没有规则匹配或未设置规则时为 -1
+func (sched *myScheduler) urlAllowed(u *url.URL) (allowed bool, index int) {
+	if sched.urlFilter == nil {
+		return true, -1
+	}
+	return sched.urlFilter.Allowed(u)
+}
+
+// getURLRuleSummary 用于生成和返回各 URL 过滤规则的命中统计。
+func getURLRuleSummary(f urlfilter.Filter) []urlfilter.RuleStats {
+	if f == nil {
+		return nil
+	}
+	return f.Stats()
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+	"github.com/dokidokikoi/webcrawler/toolkit/urlfilter"
+)
+
+func TestURLRulesArgs(t *testing.T) {
+	requestArgs := genRequestArgs([]string{"a.com"}, 0)
+	requestArgs.URLRules = []urlfilter.Rule{{Action: "unknown"}}
+	if err := requestArgs.Check(); err == nil {
+		t.Fatal("No error when checking request args with illegal URL rule!")
+	}
+	another := requestArgs
+	another.URLRules = []urlfilter.Rule{{Action: urlfilter.ACTION_DENY}}
+	if requestArgs.Same(&another) {
+		t.Fatal("The request args with different URL rules are still same!")
+	}
+}
+
+func TestURLRules(t *testing.T) {
+	requestArgs := genRequestArgs([]string{"a.com"}, 1)
+	requestArgs.URLRules = []urlfilter.Rule{
+		{Name: "keep docs", Action: urlfilter.ACTION_ALLOW, Path: "/docs/*"},
+		{Name: "no pdf", Action: urlfilter.ACTION_DENY, Extensions: []string{".pdf"}},
+		{Name: "no private", Action: urlfilter.ACTION_DENY, PathRegexp: "^/private(/|$)"},
+	}
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, genDataArgs(10, 2, 1), genSimpleModuleArgs(1, 1, 1, t)); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	mySched := sched.(*myScheduler)
+	cases := []struct {
+		url      string
+		accepted bool
+	}{
+		{"http://a.com/docs/a.pdf", true},
+		{"http://a.com/b.pdf", false},
+		{"http://a.com/private", false},
+		{"http://a.com/private/a", false},
+		{"http://a.com/privateer", true},
+		// 重复的 URL 不会再次计入命中次数。
+		{"http://a.com/docs/a.pdf", false},
+	}
+	for _, c := range cases {
+		httpReq, _ := http.NewRequest("GET", c.url, nil)
+		if accepted := mySched.sendReq(module.NewRequest(httpReq, 0)); accepted != c.accepted {
+			t.Fatalf("Inconsistent result: expected: %v, actual: %v (URL: %s)",
+				c.accepted, accepted, c.url)
+		}
+	}
+	expected := []urlfilter.RuleStats{
+		{Name: "keep docs", Action: urlfilter.ACTION_ALLOW, Hits: 1},
+		{Name: "no pdf", Action: urlfilter.ACTION_DENY, Hits: 1},
+		{Name: "no private", Action: urlfilter.ACTION_DENY, Hits: 2},
+	}
+	summary := sched.Summary().Struct()
+	if len(summary.URLRules) != len(expected) {
+		t.Fatalf("Inconsistent URL rule summary: %#v", summary.URLRules)
+	}
+	for i, rs := range summary.URLRules {
+		if rs != expected[i] {
+			t.Fatalf("Inconsistent URL rule summary: expected: %#v, actual: %#v",
+				expected[i], rs)
+		}
+	}
+}
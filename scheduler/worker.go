@@ -0,0 +1,60 @@
+package scheduler
+
+import "sync/atomic"
+
+// 各处理阶段默认的工作协程数量
+const defaultWorkerNumber uint32 = 1
+
+// 代表某个处理阶段的工作协程计数器
+// 该类型的方法都是并发安全的
+type workerCounter struct {
+	// 工作协程的总数
+	number uint32
+	// 正在处理数据的工作协程数
+	active uint32
+	// 已交给该阶段但尚未处理完毕的数据数
+	// 包括缓冲池中的以及正在放入缓冲池的数据
+	pending int64
+}
+
+func (wc *workerCounter) Number() uint32 {
+	return atomic.LoadUint32(&wc.number)
+}
+
+func (wc *workerCounter) Active() uint32 {
+	return atomic.LoadUint32(&wc.active)
+}
+
+func (wc *workerCounter) Pending() int64 {
+	return atomic.LoadInt64(&wc.pending)
+}
+
+func (wc *workerCounter) setNumber(number uint32) {
+	atomic.StoreUint32(&wc.number, number)
+	atomic.StoreInt64(&wc.pending, 0)
+}
+
+func (wc *workerCounter) incrActive() {
+	atomic.AddUint32(&wc.active, 1)
+}
+
+func (wc *workerCounter) decrActive() {
+	atomic.AddUint32(&wc.active, ^uint32(0))
+}
+
+func (wc *workerCounter) incrPending() {
+	atomic.AddInt64(&wc.pending, 1)
+}
+
+func (wc *workerCounter) decrPending() {
+	atomic.AddInt64(&wc.pending, -1)
+}
+
+// 获取实际使用的工作协程数量
+// 参数值为 0 时使用默认值
+func getWorkerNumber(number uint32) uint32 {
+	if number == 0 {
+		return defaultWorkerNumber
+	}
+	return number
+}
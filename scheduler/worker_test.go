@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetWorkerNumber(t *testing.T) {
+	if n := getWorkerNumber(0); n != defaultWorkerNumber {
+		t.Fatalf("Inconsistent worker number: expected: %d, actual: %d",
+			defaultWorkerNumber, n)
+	}
+	if n := getWorkerNumber(5); n != 5 {
+		t.Fatalf("Inconsistent worker number: expected: %d, actual: %d",
+			5, n)
+	}
+}
+
+func TestWorkersInParallel(t *testing.T) {
+	// 记录同时处理请求的最大数量。
+	var handling, maxHandling int32
+	pageNumber := 8
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&handling, 1)
+		defer atomic.AddInt32(&handling, -1)
+		for {
+			max := atomic.LoadInt32(&maxHandling)
+			if current <= max || atomic.CompareAndSwapInt32(&maxHandling, max, current) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, "<html><body></body></html>")
+			return
+		}
+		fmt.Fprint(w, "<html><body>")
+		for i := 0; i < pageNumber; i++ {
+			fmt.Fprintf(w, `<a href="/page%d">page%d</a>`, i, i)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+	defer server.Close()
+
+	requestArgs := genRequestArgs([]string{}, 1)
+	dataArgs := genDataArgs(10, 2, 1)
+	dataArgs.DownloaderWorkerNumber = 4
+	dataArgs.AnalyzerWorkerNumber = 2
+	dataArgs.PipelineWorkerNumber = 3
+	moduleArgs := genSimpleModuleArgs(4, 2, 1, t)
+	sched := NewScheduler()
+	if err := sched.Init(requestArgs, dataArgs, moduleArgs); err != nil {
+		t.Fatalf("An error occurs when initializing scheduler: %s", err)
+	}
+	summary := sched.Summary().Struct()
+	workers := []WorkerSummaryStruct{
+		summary.DownloadWorkers, summary.AnalyzeWorkers, summary.PickWorkers}
+	for i, expected := range []uint32{4, 2, 3} {
+		if workers[i].Number != expected {
+			t.Fatalf("Inconsistent worker number: expected: %d, actual: %d (index: %d)",
+				expected, workers[i].Number, i)
+		}
+	}
+	firstHTTPReq, _ := http.NewRequest("GET", server.URL+"/", nil)
+	if err := sched.Start(firstHTTPReq); err != nil {
+		t.Fatalf("An error occurs when starting scheduler: %s", err)
+	}
+	waitForIdle(sched, 3, t)
+	if err := sched.Stop(); err != nil {
+		t.Fatalf("An error occurs when stopping scheduler: %s", err)
+	}
+	if atomic.LoadInt32(&maxHandling) < 2 {
+		t.Fatalf("The requests were not downloaded in parallel! (max handling: %d)",
+			maxHandling)
+	}
+	var called uint64
+	for _, ds := range sched.Summary().Struct().Downloaders {
+		if ds.Called == 0 {
+			t.Fatalf("Downloader %s was never called!", ds.ID)
+		}
+		called += ds.Called
+	}
+	if called != uint64(pageNumber+1) {
+		t.Fatalf("Inconsistent download count: expected: %d, actual: %d",
+			pageNumber+1, called)
+	}
+}
+
+// waitForIdle 用于等待调度器连续若干次处于空闲状态。
+func waitForIdle(sched Scheduler, max int, t *testing.T) {
+	var count int
+	deadline := time.Now().Add(30 * time.Second)
+	for count < max {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout when waiting for idle scheduler!")
+		}
+		time.Sleep(100 * time.Millisecond)
+		if sched.Idle() {
+			count++
+		} else {
+			count = 0
+		}
+	}
+}
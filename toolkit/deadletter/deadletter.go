@@ -0,0 +1,241 @@
+// Package deadletter 用于把最终失败的请求和条目记录到本地的 JSONL 文件中
+// 记录的请求可以被重新读取，以便在之后的运行中重新放入调度器
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// 记录的种类
+const (
+	// 请求
+	KIND_REQUEST = "request"
+	// 条目
+	KIND_ITEM = "item"
+)
+
+// Record 代表死信文件中的一条记录。
+type Record struct {
+	// 记录的种类，可选值见 KIND_* 常量
+	Kind string `json:"kind"`
+	// 记录的时间
+	Time time.Time `json:"time"`
+	// 最后一次的错误信息
+	Error string `json:"error"`
+	// 失败的请求，仅在种类为请求时有效
+	Request *RequestRecord `json:"request,omitempty"`
+	// 失败的条目，仅在种类为条目时有效
+	Item *ItemRecord `json:"item,omitempty"`
+}
+
+// RequestRecord 代表失败的请求。
+type RequestRecord struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Depth  uint32      `json:"depth"`
+	// 从所属起点出发的最大爬取深度，为 nil 时使用调度器的最大深度
+	MaxDepth *uint32 `json:"max_depth,omitempty"`
+	// 已进行的下载次数
+	Attempt uint32 `json:"attempt"`
+	// 父请求的 URL 和所属起点的 URL
+	ParentURL string `json:"parent_url,omitempty"`
+	RootURL   string `json:"root_url,omitempty"`
+	// 请求的元数据
+	Meta module.Metadata `json:"meta,omitempty"`
+}
+
+// ItemRecord 代表失败的条目。
+type ItemRecord struct {
+	// 条目处理管道收到的条目
+	Item module.Item `json:"item"`
+	// 首个出错的条目处理函数在条目处理管道中的序号，未知时为 -1
+	ProcessorIndex int `json:"processor_index"`
+}
+
+// 死信存储接口
+// 该接口的实现类型必须是并发安全的
+type Store interface {
+	// 记录失败的请求
+	AddRequest(req *module.Request, err error) error
+	// 记录失败的条目
+	AddItem(item module.Item, processorIndex int, err error) error
+	// 获取已记录的数量
+	Count() uint64
+	// 关闭存储
+	Close() error
+}
+
+// 基于 JSONL 文件的死信存储
+type fileStore struct {
+	file    *os.File
+	encoder *json.Encoder
+	count   uint64
+	lock    sync.Mutex
+}
+
+// NewStore 用于创建一个基于 JSONL 文件的死信存储。
+// 新的记录会被追加到文件的末尾。
+// 记录中包含完整的请求头，因此新建的文件只有所有者可以读写。
+func NewStore(path string) (Store, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStore{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// 获取错误信息，错误值为 nil 时返回空字符串
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (s *fileStore) AddRequest(req *module.Request, err error) error {
+	if req == nil || req.HTTPReq() == nil || req.HTTPReq().URL == nil {
+		return fmt.Errorf("invalid request")
+	}
+	httpReq := req.HTTPReq()
+	rr := &RequestRecord{
+		Method:    httpReq.Method,
+		URL:       httpReq.URL.String(),
+		Header:    httpReq.Header,
+		Depth:     req.Depth(),
+		Attempt:   req.Attempt(),
+		ParentURL: req.ParentURL(),
+		RootURL:   req.RootURL(),
+		Meta:      req.Meta(),
+	}
+	if maxDepth, ok := req.MaxDepth(); ok {
+		rr.MaxDepth = &maxDepth
+	}
+	return s.add(Record{
+		Kind:    KIND_REQUEST,
+		Time:    time.Now(),
+		Error:   errorString(err),
+		Request: rr,
+	})
+}
+
+func (s *fileStore) AddItem(item module.Item, processorIndex int, err error) error {
+	if item == nil {
+		return fmt.Errorf("nil item")
+	}
+	return s.add(Record{
+		Kind:  KIND_ITEM,
+		Time:  time.Now(),
+		Error: errorString(err),
+		Item:  &ItemRecord{Item: item, ProcessorIndex: processorIndex},
+	})
+}
+
+// 追加一条记录
+func (s *fileStore) add(record Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("the dead letter store has been closed")
+	}
+	if err := s.encoder.Encode(record); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+func (s *fileStore) Count() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.count
+}
+
+func (s *fileStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// Read 用于从 reader 中读取所有记录。
+func Read(reader io.Reader) ([]Record, error) {
+	var records []Record
+	decoder := json.NewDecoder(reader)
+	for {
+		var record Record
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// ReadFile 用于从死信文件中读取所有记录。
+func ReadFile(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// ToRequest 用于把记录还原为请求，记录不是请求时返回错误值。
+// 还原的请求的下载次数为 0，以便重新进行重试。
+func (record Record) ToRequest() (*module.Request, error) {
+	if record.Kind != KIND_REQUEST || record.Request == nil {
+		return nil, fmt.Errorf("the record is not a request")
+	}
+	rr := record.Request
+	httpReq, err := http.NewRequest(rr.Method, rr.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rr.Header != nil {
+		httpReq.Header = rr.Header
+	}
+	req := module.NewRequest(httpReq, rr.Depth)
+	req.SetLineage(rr.ParentURL, rr.RootURL)
+	req.SetMeta(rr.Meta)
+	if rr.MaxDepth != nil {
+		req.SetMaxDepth(*rr.MaxDepth)
+	}
+	return req, nil
+}
+
+// ReadRequests 用于从死信文件中读取并还原所有请求，条目记录会被忽略。
+func ReadRequests(path string) ([]*module.Request, error) {
+	records, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var reqs []*module.Request
+	for _, record := range records {
+		if record.Kind != KIND_REQUEST {
+			continue
+		}
+		req, err := record.ToRequest()
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
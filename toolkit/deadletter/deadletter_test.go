@@ -0,0 +1,85 @@
+package deadletter
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("An error occurs when creating store: %s", err)
+	}
+	httpReq, _ := http.NewRequest("POST", "http://a.com/x?y=1", nil)
+	httpReq.Header.Set("Cookie", "k=v")
+	req := module.NewRequest(httpReq, 2)
+	req.SetMaxDepth(3)
+	req.SetAttempt(4)
+	if err := store.AddRequest(req, errors.New("connection reset")); err != nil {
+		t.Fatalf("An error occurs when adding request: %s", err)
+	}
+	item := module.Item{"url": "http://a.com/", "number": 1}
+	if err := store.AddItem(item, 1, errors.New("bad item")); err != nil {
+		t.Fatalf("An error occurs when adding item: %s", err)
+	}
+	if err := store.AddRequest(nil, nil); err == nil {
+		t.Fatal("No error when adding nil request!")
+	}
+	if store.Count() != 2 {
+		t.Fatalf("Inconsistent count: expected: %d, actual: %d", 2, store.Count())
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("An error occurs when closing store: %s", err)
+	}
+	// 记录中包含请求头，其他用户不应能读取。
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Inconsistent file mode: expected: %v, actual: %v (error: %v)",
+			os.FileMode(0600), info.Mode().Perm(), err)
+	}
+	if err := store.AddItem(item, 0, nil); err == nil {
+		t.Fatal("No error when adding item to closed store!")
+	}
+	// 再次打开时会追加记录。
+	store, _ = NewStore(path)
+	store.AddRequest(req, nil)
+	store.Close()
+
+	records, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("An error occurs when reading records: %s", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Inconsistent record number: expected: %d, actual: %d", 3, len(records))
+	}
+	ir := records[1]
+	if ir.Kind != KIND_ITEM || ir.Error != "bad item" || ir.Item.ProcessorIndex != 1 ||
+		ir.Item.Item["url"] != "http://a.com/" {
+		t.Fatalf("Inconsistent item record: %#v", ir)
+	}
+	if _, err := ir.ToRequest(); err == nil {
+		t.Fatal("No error when converting item record to request!")
+	}
+	reqs, err := ReadRequests(path)
+	if err != nil {
+		t.Fatalf("An error occurs when reading requests: %s", err)
+	}
+	if len(reqs) != 2 {
+		t.Fatalf("Inconsistent request number: expected: %d, actual: %d", 2, len(reqs))
+	}
+	r := reqs[0]
+	maxDepth, ok := r.MaxDepth()
+	if r.HTTPReq().Method != "POST" || r.HTTPReq().URL.String() != "http://a.com/x?y=1" ||
+		r.HTTPReq().Header.Get("Cookie") != "k=v" || r.Depth() != 2 ||
+		!ok || maxDepth != 3 || r.Attempt() != 0 {
+		t.Fatalf("Inconsistent request: %#v", r)
+	}
+	if records[0].Request.Attempt != 4 || records[0].Error != "connection reset" {
+		t.Fatalf("Inconsistent request record: %#v", records[0].Request)
+	}
+}
@@ -0,0 +1,261 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+)
+
+// Bloom 过滤器默认的初始容量
+const defaultBloomCapacity uint64 = 100000
+
+// Bloom 过滤器默认的误判率上限
+const defaultFalsePositiveRate = 0.001
+
+// 可扩展 Bloom 过滤器中，相邻两个过滤器的误判率之比
+// 各过滤器的误判率之和不会超过设定的上限
+const bloomTighteningRatio = 0.5
+
+// 加载时允许的过滤器的最大个数
+// 误判率按 bloomTighteningRatio 逐个收紧，实际的个数远小于该值
+const maxBloomFilters = 64
+
+// 加载时允许的单个过滤器的最大位数
+const maxBloomBits uint64 = 1 << 40
+
+// 加载位数组时每次读取的字数
+const bloomLoadChunk = 1 << 13
+
+// 单个 Bloom 过滤器
+type bloomFilter struct {
+	// 位数组
+	bits []uint64
+	// 位数
+	m uint64
+	// 哈希函数的个数
+	k uint64
+	// 容量，元素数达到容量后不再放入新元素
+	capacity uint64
+	// 元素数
+	count uint64
+}
+
+func newBloomFilter(capacity uint64, fpRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Ceil(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+// 获取给定哈希值对应的各个位置
+// 使用双重哈希模拟 k 个哈希函数
+func (f *bloomFilter) locations(h uint64, fn func(pos uint64) bool) bool {
+	h2 := (h*0x9E3779B97F4A7C15)>>1 | 1
+	for i := uint64(0); i < f.k; i++ {
+		if !fn((h + i*h2) % f.m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) has(h uint64) bool {
+	return f.locations(h, func(pos uint64) bool {
+		return f.bits[pos/64]&(1<<(pos%64)) != 0
+	})
+}
+
+func (f *bloomFilter) add(h uint64) {
+	f.locations(h, func(pos uint64) bool {
+		f.bits[pos/64] |= 1 << (pos % 64)
+		return true
+	})
+	f.count++
+}
+
+// 可扩展的 Bloom 过滤器
+// 当前过滤器装满后，会追加一个容量加倍、误判率减半的新过滤器
+type bloomDeduper struct {
+	// 初始容量
+	capacity uint64
+	// 误判率上限
+	fpRate  float64
+	filters []*bloomFilter
+	// 已添加的键的数量
+	total  uint64
+	rwlock sync.RWMutex
+}
+
+// NewBloom 用于创建一个可扩展的 Bloom 过滤器。
+// 参数 capacity 代表初始容量，fpRate 代表误判率上限，为 0 时均使用默认值。
+func NewBloom(capacity uint64, fpRate float64) (Deduper, error) {
+	if capacity == 0 {
+		capacity = defaultBloomCapacity
+	}
+	if fpRate == 0 {
+		fpRate = defaultFalsePositiveRate
+	}
+	if fpRate < 0 || fpRate >= 1 {
+		errMsg := fmt.Sprintf("illegal false positive rate: %v", fpRate)
+		return nil, errors.NewIllegalParameterError(errMsg)
+	}
+	d := &bloomDeduper{capacity: capacity, fpRate: fpRate}
+	d.grow()
+	return d, nil
+}
+
+// 追加一个新的过滤器
+func (d *bloomDeduper) grow() {
+	i := len(d.filters)
+	capacity := d.capacity << uint(i)
+	fpRate := d.fpRate * (1 - bloomTighteningRatio) * math.Pow(bloomTighteningRatio, float64(i))
+	d.filters = append(d.filters, newBloomFilter(capacity, fpRate))
+}
+
+func (d *bloomDeduper) has(h uint64) bool {
+	for _, f := range d.filters {
+		if f.has(h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *bloomDeduper) Add(key string) bool {
+	h := hash64(key)
+	d.rwlock.Lock()
+	defer d.rwlock.Unlock()
+	if d.has(h) {
+		return false
+	}
+	last := d.filters[len(d.filters)-1]
+	if last.count >= last.capacity {
+		d.grow()
+		last = d.filters[len(d.filters)-1]
+	}
+	last.add(h)
+	d.total++
+	return true
+}
+
+func (d *bloomDeduper) Has(key string) bool {
+	h := hash64(key)
+	d.rwlock.RLock()
+	defer d.rwlock.RUnlock()
+	return d.has(h)
+}
+
+func (d *bloomDeduper) Len() uint64 {
+	d.rwlock.RLock()
+	defer d.rwlock.RUnlock()
+	return d.total
+}
+
+// Save 会以二进制形式写入各过滤器的参数与位数组。
+// 位数组会先被复制，写入期间不会阻塞 Add。
+func (d *bloomDeduper) Save(w io.Writer) error {
+	d.rwlock.RLock()
+	header := []uint64{
+		d.capacity, math.Float64bits(d.fpRate), d.total, uint64(len(d.filters)),
+	}
+	filters := make([]*bloomFilter, len(d.filters))
+	for i, f := range d.filters {
+		clone := *f
+		clone.bits = make([]uint64, len(f.bits))
+		copy(clone.bits, f.bits)
+		filters[i] = &clone
+	}
+	d.rwlock.RUnlock()
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.BigEndian, header); err != nil {
+		return err
+	}
+	for _, f := range filters {
+		if err := binary.Write(bw, binary.BigEndian, []uint64{f.m, f.k, f.capacity, f.count}); err != nil {
+			return err
+		}
+		if err := binary.Write(bw, binary.BigEndian, f.bits); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (d *bloomDeduper) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+	header := make([]uint64, 4)
+	if err := binary.Read(br, binary.BigEndian, header); err != nil {
+		return err
+	}
+	if header[3] > maxBloomFilters {
+		return fmt.Errorf("too many bloom filters: %d", header[3])
+	}
+	filters := make([]*bloomFilter, header[3])
+	for i := range filters {
+		params := make([]uint64, 4)
+		if err := binary.Read(br, binary.BigEndian, params); err != nil {
+			return err
+		}
+		if params[0] == 0 || params[0] > maxBloomBits || params[1] == 0 {
+			return fmt.Errorf("illegal bloom filter parameters: %v", params)
+		}
+		bits, err := readBits(br, (params[0]+63)/64)
+		if err != nil {
+			return err
+		}
+		filters[i] = &bloomFilter{
+			bits:     bits,
+			m:        params[0],
+			k:        params[1],
+			capacity: params[2],
+			count:    params[3],
+		}
+	}
+	if len(filters) == 0 {
+		return fmt.Errorf("no bloom filter")
+	}
+	d.rwlock.Lock()
+	defer d.rwlock.Unlock()
+	d.capacity = header[0]
+	d.fpRate = math.Float64frombits(header[1])
+	d.total = header[2]
+	d.filters = filters
+	return nil
+}
+
+// 读取给定字数的位数组
+// 按实际读到的内容分批分配空间，以免数据不完整时分配过多的内存
+func readBits(r io.Reader, n uint64) ([]uint64, error) {
+	var bits []uint64
+	for uint64(len(bits)) < n {
+		size := n - uint64(len(bits))
+		if size > bloomLoadChunk {
+			size = bloomLoadChunk
+		}
+		chunk := make([]uint64, size)
+		if err := binary.Read(r, binary.BigEndian, chunk); err != nil {
+			return nil, err
+		}
+		bits = append(bits, chunk...)
+	}
+	return bits, nil
+}
+
+func (d *bloomDeduper) Close() error {
+	return nil
+}
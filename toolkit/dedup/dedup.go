@@ -0,0 +1,72 @@
+// Package dedup 提供用于 URL 去重的存储
+// 包括基于内存的集合、可扩展的 Bloom 过滤器以及基于磁盘的存储
+package dedup
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+)
+
+// 去重存储的类型
+const (
+	// 基于内存的集合，保存完整的键
+	TYPE_MEMORY = "memory"
+	// 可扩展的 Bloom 过滤器，占用内存少，但存在误判
+	TYPE_BLOOM = "bloom"
+	// 基于磁盘的存储，只在内存中占用固定大小的空间
+	TYPE_DISK = "disk"
+)
+
+// 去重存储的配置
+type Config struct {
+	// 存储的类型，为空时使用内存
+	Type string
+	// Bloom 过滤器的初始容量，为 0 时使用默认值
+	Capacity uint64
+	// Bloom 过滤器的误判率上限，为 0 时使用默认值
+	FalsePositiveRate float64
+	// 磁盘存储所在的目录
+	Dir string
+}
+
+// 去重存储接口
+// 该接口的实现类型必须是并发安全的
+type Deduper interface {
+	// 添加键，结果值代表该键是否是首次出现
+	// 对于存在误判的实现，首次出现的键也可能被判为重复
+	Add(key string) bool
+	// 判断键是否已存在
+	Has(key string) bool
+	// 获取已添加的键的数量
+	Len() uint64
+	// 把存储的内容写入 w，以便生成检查点
+	Save(w io.Writer) error
+	// 从 r 读取 Save 写入的内容，并替换当前的内容
+	Load(r io.Reader) error
+	// 关闭存储并释放资源
+	Close() error
+}
+
+// New 用于按配置创建去重存储。
+func New(config Config) (Deduper, error) {
+	switch config.Type {
+	case "", TYPE_MEMORY:
+		return NewMemory(), nil
+	case TYPE_BLOOM:
+		return NewBloom(config.Capacity, config.FalsePositiveRate)
+	case TYPE_DISK:
+		return NewDisk(config.Dir)
+	}
+	errMsg := fmt.Sprintf("unknown deduper type %q", config.Type)
+	return nil, errors.NewIllegalParameterError(errMsg)
+}
+
+// 获取键的 64 位哈希值
+func hash64(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
@@ -0,0 +1,240 @@
+package dedup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// genDedupers 用于生成各类型的去重存储。
+func genDedupers(t *testing.T) map[string]Deduper {
+	dedupers := map[string]Deduper{}
+	configs := []Config{
+		{Type: TYPE_MEMORY},
+		{Type: TYPE_BLOOM, Capacity: 100, FalsePositiveRate: 0.001},
+		{Type: TYPE_DISK, Dir: t.TempDir()},
+	}
+	for _, config := range configs {
+		d, err := New(config)
+		if err != nil {
+			t.Fatalf("An error occurs when creating deduper: %s (type: %s)",
+				err, config.Type)
+		}
+		dedupers[config.Type] = d
+	}
+	return dedupers
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{Type: "unknown"}); err == nil {
+		t.Fatal("No error when creating a deduper with unknown type!")
+	}
+	for _, fpRate := range []float64{-0.1, 1, 2} {
+		if _, err := NewBloom(0, fpRate); err == nil {
+			t.Fatalf("No error when creating a bloom filter with illegal false positive rate %v!",
+				fpRate)
+		}
+	}
+}
+
+func TestAddAndHas(t *testing.T) {
+	// 超出 Bloom 过滤器的初始容量与磁盘存储的初始槽数的一半，以便触发扩容
+	number := 40000
+	// 允许的误判数，为误判率上限的两倍以避免偶然失败
+	maxFalsePositives := float64(number) * 0.001 * 2
+	for typ, d := range genDedupers(t) {
+		for i := 0; i < number; i++ {
+			key := fmt.Sprintf("http://example.com/%d", i)
+			if !d.Add(key) {
+				// Bloom 过滤器允许少量误判
+				if typ == TYPE_BLOOM {
+					continue
+				}
+				t.Fatalf("The new key %q was judged as duplicate! (type: %s)", key, typ)
+			}
+		}
+		for i := 0; i < number; i++ {
+			key := fmt.Sprintf("http://example.com/%d", i)
+			if d.Add(key) {
+				t.Fatalf("The duplicate key %q was added again! (type: %s)", key, typ)
+			}
+			if !d.Has(key) {
+				t.Fatalf("Not found key %q! (type: %s)", key, typ)
+			}
+		}
+		length := d.Len()
+		if typ == TYPE_BLOOM {
+			if length > uint64(number) || float64(uint64(number)-length) > maxFalsePositives {
+				t.Fatalf("Too many false positives: expected length: %d, actual: %d",
+					number, length)
+			}
+		} else if length != uint64(number) {
+			t.Fatalf("Inconsistent length: expected: %d, actual: %d (type: %s)",
+				number, length, typ)
+		}
+		falsePositives := 0
+		for i := 0; i < number; i++ {
+			if d.Has(fmt.Sprintf("http://example.org/%d", i)) {
+				falsePositives++
+			}
+		}
+		if float64(falsePositives) > maxFalsePositives {
+			t.Fatalf("Too many false positives: %d (type: %s)", falsePositives, typ)
+		}
+		if err := d.Close(); err != nil {
+			t.Fatalf("An error occurs when closing deduper: %s (type: %s)", err, typ)
+		}
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	keys := []string{"http://a.com/", "http://b.com/", "http://c.com/x?y=1"}
+	for typ, d := range genDedupers(t) {
+		for _, key := range keys {
+			d.Add(key)
+		}
+		var buf bytes.Buffer
+		if err := d.Save(&buf); err != nil {
+			t.Fatalf("An error occurs when saving deduper: %s (type: %s)", err, typ)
+		}
+		d.Close()
+		another := genDedupers(t)[typ]
+		another.Add("http://d.com/")
+		if err := another.Load(&buf); err != nil {
+			t.Fatalf("An error occurs when loading deduper: %s (type: %s)", err, typ)
+		}
+		if another.Len() != uint64(len(keys)) {
+			t.Fatalf("Inconsistent length: expected: %d, actual: %d (type: %s)",
+				len(keys), another.Len(), typ)
+		}
+		for _, key := range keys {
+			if !another.Has(key) {
+				t.Fatalf("Not found key %q after loading! (type: %s)", key, typ)
+			}
+		}
+		if another.Has("http://d.com/") {
+			t.Fatalf("The content before loading was not replaced! (type: %s)", typ)
+		}
+		another.Close()
+	}
+}
+
+// blockingWriter 代表第一次写入时会阻塞直到被释放的写入器。
+type blockingWriter struct {
+	bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.release
+	})
+	return w.Buffer.Write(p)
+}
+
+func TestSaveNotBlockingAdd(t *testing.T) {
+	for typ, d := range genDedupers(t) {
+		d.Add("http://a.com/")
+		w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+		saved := make(chan error, 1)
+		go func() {
+			saved <- d.Save(w)
+		}()
+		<-w.started
+		// 写入期间添加键不应被阻塞。
+		added := make(chan bool, 1)
+		go func() {
+			added <- d.Add("http://b.com/")
+		}()
+		select {
+		case ok := <-added:
+			if !ok {
+				t.Fatalf("Couldn't add a new key while saving! (type: %s)", typ)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Adding was blocked by saving! (type: %s)", typ)
+		}
+		close(w.release)
+		if err := <-saved; err != nil {
+			t.Fatalf("An error occurs when saving deduper: %s (type: %s)", err, typ)
+		}
+		d.Close()
+		another := genDedupers(t)[typ]
+		if err := another.Load(&w.Buffer); err != nil {
+			t.Fatalf("An error occurs when loading deduper: %s (type: %s)", err, typ)
+		}
+		if !another.Has("http://a.com/") || another.Len() == 0 {
+			t.Fatalf("Not found the key added before saving! (type: %s)", typ)
+		}
+		another.Close()
+	}
+}
+
+func TestLoadIllegal(t *testing.T) {
+	// 声明了巨大的长度但内容不完整的数据不应导致分配过多的内存
+	contents := map[string][][]uint64{
+		TYPE_BLOOM: {
+			{100, 0, 0, 1 << 40},
+			{100, 0, 0, 1, 1 << 62, 7, 100, 0},
+			{100, 0, 0, 1, 1 << 38, 7, 100, 0},
+		},
+		TYPE_DISK: {
+			{1 << 62, 0},
+			{1 << 30, 0},
+		},
+	}
+	dedupers := genDedupers(t)
+	for typ, list := range contents {
+		for _, content := range list {
+			var buf bytes.Buffer
+			binary.Write(&buf, binary.BigEndian, content)
+			if err := dedupers[typ].Load(&buf); err == nil {
+				t.Fatalf("No error when loading illegal content %v! (type: %s)", content, typ)
+			}
+		}
+	}
+	for _, d := range dedupers {
+		d.Close()
+	}
+}
+
+func TestLookupSlotWrap(t *testing.T) {
+	// 槽数多于每次读取的槽数，以便探查跨越多次读取并回到表头
+	slots := diskProbeSlots * 4
+	file, err := createDiskTable(t.TempDir(), slots)
+	if err != nil {
+		t.Fatalf("An error occurs when creating disk table: %s", err)
+	}
+	defer file.Close()
+	// 从表尾开始，把除一个槽外的所有槽都占满，这些指纹的初始槽都相同
+	start := slots - 3
+	fp := start | slots*8
+	for i := uint64(0); i < slots-1; i++ {
+		if err := writeSlot(file, (start+i)&(slots-1), fp+(i+1)*slots); err != nil {
+			t.Fatalf("An error occurs when writing slot: %s", err)
+		}
+	}
+	slot, ok, err := lookupSlot(file, slots, fp)
+	if err != nil || ok || slot != start-1 {
+		t.Fatalf("Inconsistent lookup result: expected: %d, actual: %d (found: %v, error: %v)",
+			start-1, slot, ok, err)
+	}
+	last := fp + (slots-1)*slots
+	slot, ok, err = lookupSlot(file, slots, last)
+	if err != nil || !ok || slot != start-2 {
+		t.Fatalf("Inconsistent lookup result: expected: %d, actual: %d (found: %v, error: %v)",
+			start-2, slot, ok, err)
+	}
+	if err := writeSlot(file, start-1, fp); err != nil {
+		t.Fatalf("An error occurs when writing slot: %s", err)
+	}
+	if _, _, err := lookupSlot(file, slots, fp+slots*slots); err == nil {
+		t.Fatal("No error when looking up in a full disk table!")
+	}
+}
@@ -0,0 +1,304 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// 磁盘存储中哈希表的初始槽数，必须是 2 的幂
+const diskInitialSlots uint64 = 1 << 16
+
+// 每个槽的字节数
+const diskSlotSize = 8
+
+// 加载时允许的最大槽数，对应 32 GiB 的哈希表文件
+const diskMaxSlots uint64 = 1 << 32
+
+// 基于磁盘的去重存储
+// 它在文件中维护一个以开放寻址法组织的哈希表，每个槽保存一个键的 64 位指纹
+// 因此只存在极小的误判概率，且内存占用与键的数量无关
+type diskDeduper struct {
+	// 存储所在的目录
+	dir  string
+	file *os.File
+	// 槽数
+	slots uint64
+	// 已添加的键的数量
+	count uint64
+	// 正在进行的 Save 的数量，不为 0 时不会扩容，以免替换正在被读取的哈希表文件
+	saving int
+	rwlock sync.RWMutex
+}
+
+// NewDisk 用于创建一个基于磁盘的去重存储。
+// 参数 dir 代表存储文件所在的目录，为空时使用系统的临时目录。
+// 存储总是以空的状态创建，存储文件会在关闭时删除。
+func NewDisk(dir string) (Deduper, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := createDiskTable(dir, diskInitialSlots)
+	if err != nil {
+		return nil, err
+	}
+	return &diskDeduper{dir: dir, file: file, slots: diskInitialSlots}, nil
+}
+
+// 创建一个包含给定槽数的空哈希表文件
+func createDiskTable(dir string, slots uint64) (*os.File, error) {
+	file, err := os.CreateTemp(dir, "dedup-*.db")
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(int64(slots * diskSlotSize)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+// 获取键的指纹，0 被用来代表空槽
+func fingerprint(key string) uint64 {
+	h := hash64(key)
+	if h == 0 {
+		h = 1
+	}
+	return h
+}
+
+// 查找指纹时每次读取的槽数
+const diskProbeSlots uint64 = 64
+
+// 在哈希表文件中查找指纹
+// 结果值依次代表指纹所在或应放入的槽，以及指纹是否已存在
+func lookupSlot(file *os.File, slots uint64, fp uint64) (uint64, bool, error) {
+	buf := make([]byte, diskProbeSlots*diskSlotSize)
+	slot := fp & (slots - 1)
+	for probed := uint64(0); probed < slots; {
+		// 每次读取连续的若干个槽，到达表尾时从表头继续
+		n := diskProbeSlots
+		if rest := slots - slot; n > rest {
+			n = rest
+		}
+		if rest := slots - probed; n > rest {
+			n = rest
+		}
+		chunk := buf[:n*diskSlotSize]
+		if _, err := file.ReadAt(chunk, int64(slot*diskSlotSize)); err != nil {
+			return 0, false, err
+		}
+		for i := uint64(0); i < n; i++ {
+			switch binary.BigEndian.Uint64(chunk[i*diskSlotSize:]) {
+			case 0:
+				return slot + i, false, nil
+			case fp:
+				return slot + i, true, nil
+			}
+		}
+		probed += n
+		slot = (slot + n) & (slots - 1)
+	}
+	return 0, false, fmt.Errorf("the disk table is full")
+}
+
+// 把指纹写入给定的槽
+func writeSlot(file *os.File, slot uint64, fp uint64) error {
+	buf := make([]byte, diskSlotSize)
+	binary.BigEndian.PutUint64(buf, fp)
+	_, err := file.WriteAt(buf, int64(slot*diskSlotSize))
+	return err
+}
+
+func (d *diskDeduper) Add(key string) bool {
+	fp := fingerprint(key)
+	d.rwlock.Lock()
+	defer d.rwlock.Unlock()
+	if d.file == nil {
+		return false
+	}
+	slot, ok, err := lookupSlot(d.file, d.slots, fp)
+	if err != nil || ok {
+		return false
+	}
+	if err := writeSlot(d.file, slot, fp); err != nil {
+		return false
+	}
+	d.count++
+	if d.count*2 >= d.slots && d.saving == 0 {
+		// 扩容失败时仍可继续使用原表，直到其装满
+		d.grow()
+	}
+	return true
+}
+
+// 把哈希表的槽数加倍
+func (d *diskDeduper) grow() error {
+	slots := d.slots * 2
+	file, err := createDiskTable(d.dir, slots)
+	if err != nil {
+		return err
+	}
+	if err := rehash(d.file, file, slots); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	d.file.Close()
+	os.Remove(d.file.Name())
+	d.file = file
+	d.slots = slots
+	return nil
+}
+
+// 把 src 中的所有指纹放入 dst
+func rehash(src io.ReaderAt, dst *os.File, slots uint64) error {
+	br := bufio.NewReader(io.NewSectionReader(src, 0, 1<<62))
+	buf := make([]byte, diskSlotSize)
+	for {
+		_, err := io.ReadFull(br, buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fp := binary.BigEndian.Uint64(buf)
+		if fp == 0 {
+			continue
+		}
+		slot, ok, err := lookupSlot(dst, slots, fp)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if err := writeSlot(dst, slot, fp); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *diskDeduper) Has(key string) bool {
+	fp := fingerprint(key)
+	d.rwlock.RLock()
+	defer d.rwlock.RUnlock()
+	if d.file == nil {
+		return false
+	}
+	_, ok, err := lookupSlot(d.file, d.slots, fp)
+	return err == nil && ok
+}
+
+func (d *diskDeduper) Len() uint64 {
+	d.rwlock.RLock()
+	defer d.rwlock.RUnlock()
+	return d.count
+}
+
+// Save 会先写入槽数与键的数量，再写入整个哈希表。
+// 写入期间不会阻塞 Add，因此写入的哈希表中可能包含写入期间添加的键，
+// 加载时会重新统计键的数量。
+func (d *diskDeduper) Save(w io.Writer) error {
+	d.rwlock.Lock()
+	if d.file == nil {
+		d.rwlock.Unlock()
+		return fmt.Errorf("the disk deduper has been closed")
+	}
+	file, slots, count := d.file, d.slots, d.count
+	d.saving++
+	d.rwlock.Unlock()
+	defer func() {
+		d.rwlock.Lock()
+		d.saving--
+		d.rwlock.Unlock()
+	}()
+	if err := binary.Write(w, binary.BigEndian, []uint64{slots, count}); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, io.NewSectionReader(file, 0, int64(slots*diskSlotSize)))
+	return err
+}
+
+// 统计写入的内容中非空槽的数量
+type slotCounter struct {
+	// 尚未凑满一个槽的字节
+	partial []byte
+	count   uint64
+}
+
+func (c *slotCounter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		m := diskSlotSize - len(c.partial)
+		if m > len(p) {
+			m = len(p)
+		}
+		c.partial = append(c.partial, p[:m]...)
+		p = p[m:]
+		if len(c.partial) == diskSlotSize {
+			if binary.BigEndian.Uint64(c.partial) != 0 {
+				c.count++
+			}
+			c.partial = c.partial[:0]
+		}
+	}
+	return n, nil
+}
+
+func (d *diskDeduper) Load(r io.Reader) error {
+	header := make([]uint64, 2)
+	if err := binary.Read(r, binary.BigEndian, header); err != nil {
+		return err
+	}
+	slots, count := header[0], header[1]
+	if slots == 0 || slots > diskMaxSlots || slots&(slots-1) != 0 || count >= slots {
+		return fmt.Errorf("illegal disk table header: slots: %d, count: %d", slots, count)
+	}
+	file, err := createDiskTable(d.dir, slots)
+	if err != nil {
+		return err
+	}
+	counter := &slotCounter{partial: make([]byte, 0, diskSlotSize)}
+	if _, err := io.CopyN(io.MultiWriter(file, counter), r, int64(slots*diskSlotSize)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	// 写入期间添加的键可能使实际的数量多于头部记录的数量
+	count = counter.count
+	if count >= slots {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("the disk table is full: slots: %d, count: %d", slots, count)
+	}
+	d.rwlock.Lock()
+	defer d.rwlock.Unlock()
+	if d.file != nil {
+		d.file.Close()
+		os.Remove(d.file.Name())
+	}
+	d.file = file
+	d.slots = slots
+	d.count = count
+	return nil
+}
+
+func (d *diskDeduper) Close() error {
+	d.rwlock.Lock()
+	defer d.rwlock.Unlock()
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	os.Remove(d.file.Name())
+	d.file = nil
+	return err
+}
@@ -0,0 +1,85 @@
+package dedup
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+)
+
+// 基于内存的去重存储
+type memoryDeduper struct {
+	keys   map[string]struct{}
+	rwlock sync.RWMutex
+}
+
+// NewMemory 用于创建一个基于内存的去重存储。
+func NewMemory() Deduper {
+	return &memoryDeduper{keys: map[string]struct{}{}}
+}
+
+func (d *memoryDeduper) Add(key string) bool {
+	d.rwlock.Lock()
+	defer d.rwlock.Unlock()
+	if _, ok := d.keys[key]; ok {
+		return false
+	}
+	d.keys[key] = struct{}{}
+	return true
+}
+
+func (d *memoryDeduper) Has(key string) bool {
+	d.rwlock.RLock()
+	defer d.rwlock.RUnlock()
+	_, ok := d.keys[key]
+	return ok
+}
+
+func (d *memoryDeduper) Len() uint64 {
+	d.rwlock.RLock()
+	defer d.rwlock.RUnlock()
+	return uint64(len(d.keys))
+}
+
+// Save 会每行写入一个键。
+// 键会先被复制，写入期间不会阻塞 Add。
+func (d *memoryDeduper) Save(w io.Writer) error {
+	d.rwlock.RLock()
+	keys := make([]string, 0, len(d.keys))
+	for key := range d.keys {
+		keys = append(keys, key)
+	}
+	d.rwlock.RUnlock()
+	bw := bufio.NewWriter(w)
+	for _, key := range keys {
+		if _, err := bw.WriteString(key + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (d *memoryDeduper) Load(r io.Reader) error {
+	keys := map[string]struct{}{}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if key := strings.TrimSuffix(line, "\n"); key != "" {
+			keys[key] = struct{}{}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	d.rwlock.Lock()
+	d.keys = keys
+	d.rwlock.Unlock()
+	return nil
+}
+
+func (d *memoryDeduper) Close() error {
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package domain 用于基于公共后缀列表（Public Suffix List）解析主机名的主域名
+// 内嵌了一份公共后缀列表的快照，同时支持加载其他版本的列表
+package domain
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// 内嵌的公共后缀列表快照，来自 https://publicsuffix.org/list/public_suffix_list.dat
+//
+//go:embed public_suffix_list.dat
+var embeddedList string
+
+// 公共后缀列表
+// 它是只读的，因此是并发安全的
+// 各规则集合的值代表规则是否来自私有域名部分，如 github.io
+type List struct {
+	// 普通规则，如 com.cn
+	normal map[string]bool
+	// 通配规则，如 *.ck，只保存 * 之后的部分
+	wildcard map[string]bool
+	// 例外规则，如 !www.ck，只保存 ! 之后的部分
+	exception map[string]bool
+}
+
+// Parse 用于解析公共后缀列表。
+// 位于 ===BEGIN PRIVATE DOMAINS=== 与 ===END PRIVATE DOMAINS=== 之间的规则会被标记为私有。
+func Parse(reader io.Reader) (*List, error) {
+	list := &List{
+		normal:    map[string]bool{},
+		wildcard:  map[string]bool{},
+		exception: map[string]bool{},
+	}
+	private := false
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "//") {
+			switch {
+			case strings.Contains(line, "===BEGIN PRIVATE DOMAINS==="):
+				private = true
+			case strings.Contains(line, "===END PRIVATE DOMAINS==="):
+				private = false
+			}
+			continue
+		}
+		// 每行只有第一个空白之前的部分有效
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			line = line[:i]
+		}
+		if line == "" {
+			continue
+		}
+		line = strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(line, "!"):
+			list.exception[line[1:]] = private
+		case line == "*":
+		case strings.HasPrefix(line, "*."):
+			list.wildcard[line[2:]] = private
+		default:
+			list.normal[line] = private
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+var (
+	defaultList     *List
+	defaultListOnce sync.Once
+)
+
+// Default 用于获取基于内嵌快照的公共后缀列表。
+func Default() *List {
+	defaultListOnce.Do(func() {
+		list, err := Parse(strings.NewReader(embeddedList))
+		if err != nil {
+			panic(err)
+		}
+		defaultList = list
+	})
+	return defaultList
+}
+
+// PublicSuffix 用于获取给定域名的公共后缀。
+// 参数 private 代表是否使用私有域名部分的规则。
+// 结果值 matched 代表是否有显式的规则匹配，否则公共后缀为最后一个标签。
+func (list *List) PublicSuffix(domain string, private bool) (suffix string, matched bool) {
+	labels := strings.Split(domain, ".")
+	accept := func(rules map[string]bool, key string) bool {
+		isPrivate, ok := rules[key]
+		return ok && (private || !isPrivate)
+	}
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		// 例外规则优先，其公共后缀为去掉最左侧标签后的部分
+		if accept(list.exception, candidate) {
+			return strings.Join(labels[i+1:], "."), true
+		}
+		if accept(list.normal, candidate) {
+			return candidate, true
+		}
+		if i+1 < len(labels) && accept(list.wildcard, strings.Join(labels[i+1:], ".")) {
+			return candidate, true
+		}
+	}
+	return labels[len(labels)-1], false
+}
+
+// PrimaryDomain 用于获取给定主机名的主域名，即公共后缀加上它左侧的一个标签。
+// 主机名可以包含端口，IP 地址（包括 IPv6 地址）的主域名即为其本身。
+// 参数 private 代表是否使用私有域名部分的规则。
+func (list *List) PrimaryDomain(host string, private bool) (string, error) {
+	host, err := Hostname(host)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	suffix, matched := list.PublicSuffix(host, private)
+	if !matched {
+		return "", ErrUnrecognizedHost
+	}
+	if len(host) <= len(suffix) {
+		return "", ErrPublicSuffix
+	}
+	rest := host[:len(host)-len(suffix)-1]
+	return rest[strings.LastIndex(rest, ".")+1:] + "." + suffix, nil
+}
+
+// PrimaryDomain 用于基于内嵌的公共后缀列表获取给定主机名的主域名，
+// 私有域名部分的规则也会被使用，如 foo.github.io 的主域名即为其本身。
+func PrimaryDomain(host string) (string, error) {
+	return Default().PrimaryDomain(host, true)
+}
+
+// Hostname 用于把可能包含端口的主机名规范化。
+// 它会去掉端口与 IPv6 地址两侧的方括号，把主机名转为小写，并去掉末尾的点。
+func Hostname(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if strings.HasPrefix(host, "[") {
+		// [::1] 或 [::1]:80
+		end := strings.Index(host, "]")
+		if end < 0 {
+			return "", ErrUnrecognizedHost
+		}
+		host = host[1:end]
+	} else if strings.Count(host, ":") == 1 {
+		host = host[:strings.Index(host, ":")]
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return "", ErrEmptyHost
+	}
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return "", ErrUnrecognizedHost
+	}
+	if strings.Contains(host, "..") || strings.HasPrefix(host, ".") {
+		return "", ErrUnrecognizedHost
+	}
+	return host, nil
+}
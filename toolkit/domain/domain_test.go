@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrimaryDomain(t *testing.T) {
+	cases := []struct {
+		host     string
+		expected string
+		err      error
+	}{
+		{"cn.bing.com", "bing.com", nil},
+		{"WWW.Example.COM.", "example.com", nil},
+		{"www.example.com:8080", "example.com", nil},
+		{"a.b.example.com.cn", "example.com.cn", nil},
+		{"news.bbc.co.uk", "bbc.co.uk", nil},
+		{"foo.github.io", "foo.github.io", nil},
+		{"a.foo.github.io", "foo.github.io", nil},
+		{"go.dev", "go.dev", nil},
+		{"my.site.app", "site.app", nil},
+		{"a.b.c.ck", "b.c.ck", nil},
+		{"www.ck", "www.ck", nil},
+		{"127.0.0.1", "127.0.0.1", nil},
+		{"127.0.0.1:8080", "127.0.0.1", nil},
+		{"::1", "::1", nil},
+		{"[2001:db8::1]:443", "2001:db8::1", nil},
+		{"", "", ErrEmptyHost},
+		{":80", "", ErrEmptyHost},
+		{"123.notatld", "", ErrUnrecognizedHost},
+		{"localhost", "", ErrUnrecognizedHost},
+		{"a..com", "", ErrUnrecognizedHost},
+		{"[::1", "", ErrUnrecognizedHost},
+		{"co.uk", "", ErrPublicSuffix},
+		{"github.io", "", ErrPublicSuffix},
+	}
+	for _, c := range cases {
+		pd, err := PrimaryDomain(c.host)
+		if err != c.err {
+			t.Fatalf("Inconsistent error: expected: %v, actual: %v (host: %q)",
+				c.err, err, c.host)
+		}
+		if pd != c.expected {
+			t.Fatalf("Inconsistent primary domain: expected: %s, actual: %s (host: %q)",
+				c.expected, pd, c.host)
+		}
+	}
+}
+
+func TestPrivateRules(t *testing.T) {
+	list := Default()
+	pd, err := list.PrimaryDomain("a.foo.github.io", false)
+	if err != nil {
+		t.Fatalf("An error occurs when getting primary domain: %s", err)
+	}
+	if pd != "github.io" {
+		t.Fatalf("Inconsistent primary domain: expected: %s, actual: %s",
+			"github.io", pd)
+	}
+	suffix, matched := list.PublicSuffix("foo.github.io", true)
+	if suffix != "github.io" || !matched {
+		t.Fatalf("Inconsistent public suffix: %s (matched: %v)", suffix, matched)
+	}
+}
+
+func TestParse(t *testing.T) {
+	content := `
+// ===BEGIN ICANN DOMAINS===
+test
+*.wild.test
+!keep.wild.test
+// ===END ICANN DOMAINS===
+// ===BEGIN PRIVATE DOMAINS===
+host.test trailing text
+// ===END PRIVATE DOMAINS===
+`
+	list, err := Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing list: %s", err)
+	}
+	cases := []struct {
+		host    string
+		private bool
+		suffix  string
+	}{
+		{"a.b.test", true, "test"},
+		{"a.b.wild.test", true, "b.wild.test"},
+		{"a.keep.wild.test", true, "wild.test"},
+		{"a.host.test", true, "host.test"},
+		{"a.host.test", false, "test"},
+	}
+	for _, c := range cases {
+		suffix, matched := list.PublicSuffix(c.host, c.private)
+		if !matched || suffix != c.suffix {
+			t.Fatalf("Inconsistent public suffix: expected: %s, actual: %s (host: %s, private: %v)",
+				c.suffix, suffix, c.host, c.private)
+		}
+	}
+	if _, matched := list.PublicSuffix("a.com", true); matched {
+		t.Fatal("An unknown suffix was matched!")
+	}
+}
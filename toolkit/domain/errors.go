@@ -0,0 +1,12 @@
+package domain
+
+import "errors"
+
+// ErrEmptyHost 是表示主机名为空的错误的变量。
+var ErrEmptyHost = errors.New("empty host")
+
+// ErrUnrecognizedHost 是表示主机名的顶级域名不在公共后缀列表中的错误的变量。
+var ErrUnrecognizedHost = errors.New("unrecognized host")
+
+// ErrPublicSuffix 是表示主机名本身就是公共后缀的错误的变量。
+var ErrPublicSuffix = errors.New("host is a public suffix")
@@ -0,0 +1,205 @@
+// Package frontier 提供按优先级取出请求的待爬取队列
+package frontier
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// 待爬取队列接口
+// 该接口的实现类型必须是并发安全的
+type Frontier interface {
+	// 获取队列的容量
+	Cap() uint32
+	// 获取队列中请求的总数
+	Total() uint64
+	// 向队列放入请求
+	// 注意：本方法是阻塞的，队列已满时 Put 阻塞
+	// 若队列已关闭，则会返回非 nil 的错误值
+	Put(req *module.Request) error
+	// 从队列取出优先级最高的请求
+	// 注意：本方法是阻塞的，队列为空时 Get 阻塞
+	// 若队列已关闭，则会返回非 nil 的错误值
+	Get() (*module.Request, error)
+	// 关闭队列，队列关闭后调用返回 false
+	Close() bool
+	// 判断队列是否关闭
+	Closed() bool
+}
+
+// 队列中的条目
+type entry struct {
+	req      *module.Request
+	priority float64
+	// 放入顺序，用于优先级相同时保持先进先出
+	seq uint64
+}
+
+// 按优先级排列的条目堆
+type entryHeap []entry
+
+func (h entryHeap) Len() int { return len(h) }
+
+func (h entryHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h entryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *entryHeap) Push(x interface{}) { *h = append(*h, x.(entry)) }
+
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = entry{}
+	*h = old[:n-1]
+	return e
+}
+
+// 待爬取队列的实现类型
+type myFrontier struct {
+	// 容量
+	capacity uint32
+	// 优先级函数
+	priority PriorityFunc
+	// 是否在主机之间轮流取出请求
+	hostFair bool
+	// 各主机的条目堆，不按主机轮流时只有一个键为空的堆
+	heaps map[string]*entryHeap
+	// 存在请求的主机，按轮流顺序排列
+	hosts []string
+	// 下一个取出请求的主机在 hosts 中的索引
+	next int
+	// 请求总数
+	total uint64
+	// 下一个放入顺序
+	seq uint64
+	// 关闭状态，0-未关闭；1-已关闭
+	closed uint32
+	lock   sync.Mutex
+	// 队列非空或已关闭时会被通知
+	notEmpty *sync.Cond
+	// 队列未满或已关闭时会被通知
+	notFull *sync.Cond
+}
+
+// New 用于创建一个待爬取队列。
+// 参数 priority 为 nil 时使用先进先出策略。
+// 参数 hostFair 为 true 时，会在请求所属的主机之间轮流取出请求，
+// 同一主机的请求仍按优先级取出。
+func New(capacity uint32, priority PriorityFunc, hostFair bool) (Frontier, error) {
+	if capacity == 0 {
+		errMsg := fmt.Sprintf("illegal capacity for frontier: %d", capacity)
+		return nil, errors.NewIllegalParameterError(errMsg)
+	}
+	if priority == nil {
+		priority = FIFO
+	}
+	f := &myFrontier{
+		capacity: capacity,
+		priority: priority,
+		hostFair: hostFair,
+		heaps:    map[string]*entryHeap{},
+	}
+	f.notEmpty = sync.NewCond(&f.lock)
+	f.notFull = sync.NewCond(&f.lock)
+	return f, nil
+}
+
+func (f *myFrontier) Cap() uint32 {
+	return f.capacity
+}
+
+func (f *myFrontier) Total() uint64 {
+	return atomic.LoadUint64(&f.total)
+}
+
+// 获取请求所属的主机
+func hostOf(req *module.Request) string {
+	httpReq := req.HTTPReq()
+	if httpReq == nil || httpReq.URL == nil {
+		return ""
+	}
+	return strings.ToLower(httpReq.URL.Host)
+}
+
+func (f *myFrontier) Put(req *module.Request) error {
+	if req == nil {
+		return errors.NewIllegalParameterError("nil request")
+	}
+	priority := f.priority(req)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for !f.Closed() && atomic.LoadUint64(&f.total) >= uint64(f.capacity) {
+		f.notFull.Wait()
+	}
+	if f.Closed() {
+		return ErrClosedFrontier
+	}
+	key := ""
+	if f.hostFair {
+		key = hostOf(req)
+	}
+	h, ok := f.heaps[key]
+	if !ok {
+		h = &entryHeap{}
+		f.heaps[key] = h
+		f.hosts = append(f.hosts, key)
+	}
+	heap.Push(h, entry{req: req, priority: priority, seq: f.seq})
+	f.seq++
+	atomic.AddUint64(&f.total, 1)
+	f.notEmpty.Signal()
+	return nil
+}
+
+func (f *myFrontier) Get() (*module.Request, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for !f.Closed() && atomic.LoadUint64(&f.total) == 0 {
+		f.notEmpty.Wait()
+	}
+	if f.Closed() {
+		return nil, ErrClosedFrontier
+	}
+	if f.next >= len(f.hosts) {
+		f.next = 0
+	}
+	key := f.hosts[f.next]
+	h := f.heaps[key]
+	e := heap.Pop(h).(entry)
+	if h.Len() == 0 {
+		delete(f.heaps, key)
+		f.hosts = append(f.hosts[:f.next], f.hosts[f.next+1:]...)
+	} else {
+		f.next++
+	}
+	atomic.AddUint64(&f.total, ^uint64(0))
+	f.notFull.Signal()
+	return e.req, nil
+}
+
+func (f *myFrontier) Close() bool {
+	if !atomic.CompareAndSwapUint32(&f.closed, 0, 1) {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.notEmpty.Broadcast()
+	f.notFull.Broadcast()
+	return true
+}
+
+func (f *myFrontier) Closed() bool {
+	return atomic.LoadUint32(&f.closed) == 1
+}
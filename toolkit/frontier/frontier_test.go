@@ -0,0 +1,147 @@
+package frontier
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// genRequest 用于生成测试用的请求。
+func genRequest(url string, depth uint32, priority float64) *module.Request {
+	httpReq, _ := http.NewRequest("GET", url, nil)
+	req := module.NewRequest(httpReq, depth)
+	req.SetPriority(priority)
+	return req
+}
+
+// getURLs 用于依次取出给定数量的请求并返回它们的 URL。
+func getURLs(f Frontier, number int, t *testing.T) []string {
+	urls := make([]string, 0, number)
+	for i := 0; i < number; i++ {
+		req, err := f.Get()
+		if err != nil {
+			t.Fatalf("An error occurs when getting request: %s", err)
+		}
+		urls = append(urls, req.HTTPReq().URL.String())
+	}
+	return urls
+}
+
+// checkURLs 用于检查取出的 URL 的顺序。
+func checkURLs(expected []string, actual []string, t *testing.T) {
+	if fmt.Sprint(expected) != fmt.Sprint(actual) {
+		t.Fatalf("Inconsistent order: expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(0, nil, false); err == nil {
+		t.Fatal("No error when creating a frontier with zero capacity!")
+	}
+	f, err := New(10, nil, false)
+	if err != nil {
+		t.Fatalf("An error occurs when creating a frontier: %s", err)
+	}
+	if f.Cap() != 10 || f.Total() != 0 || f.Closed() {
+		t.Fatalf("Inconsistent frontier: cap: %d, total: %d, closed: %v",
+			f.Cap(), f.Total(), f.Closed())
+	}
+	if err := f.Put(nil); err == nil {
+		t.Fatal("It still can put nil request!")
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	reqs := []*module.Request{
+		genRequest("http://a.com/1", 2, 0),
+		genRequest("http://a.com/2", 1, 5),
+		genRequest("http://a.com/3", 0, 1),
+		genRequest("http://a.com/4", 1, 5),
+	}
+	cases := map[string][]string{
+		POLICY_FIFO:          {"http://a.com/1", "http://a.com/2", "http://a.com/3", "http://a.com/4"},
+		POLICY_BREADTH_FIRST: {"http://a.com/3", "http://a.com/2", "http://a.com/4", "http://a.com/1"},
+		POLICY_BEST_FIRST:    {"http://a.com/2", "http://a.com/4", "http://a.com/3", "http://a.com/1"},
+	}
+	for policy, expected := range cases {
+		priority, err := GetPolicy(policy)
+		if err != nil {
+			t.Fatalf("An error occurs when getting policy %q: %s", policy, err)
+		}
+		f, _ := New(10, priority, false)
+		for _, req := range reqs {
+			if err := f.Put(req); err != nil {
+				t.Fatalf("An error occurs when putting request: %s", err)
+			}
+		}
+		if f.Total() != uint64(len(reqs)) {
+			t.Fatalf("Inconsistent total: expected: %d, actual: %d",
+				len(reqs), f.Total())
+		}
+		checkURLs(expected, getURLs(f, len(reqs), t), t)
+	}
+	if _, err := GetPolicy("unknown"); err == nil {
+		t.Fatal("No error when getting an unknown policy!")
+	}
+}
+
+func TestHostFair(t *testing.T) {
+	f, _ := New(10, BreadthFirst, true)
+	reqs := []*module.Request{
+		genRequest("http://a.com/1", 1, 0),
+		genRequest("http://a.com/2", 0, 0),
+		genRequest("http://a.com/3", 2, 0),
+		genRequest("http://b.com/1", 1, 0),
+		genRequest("http://c.com/1", 0, 0),
+	}
+	for _, req := range reqs {
+		f.Put(req)
+	}
+	expected := []string{
+		"http://a.com/2", "http://b.com/1", "http://c.com/1",
+		"http://a.com/1", "http://a.com/3",
+	}
+	checkURLs(expected, getURLs(f, len(reqs), t), t)
+}
+
+func TestBlockingAndClose(t *testing.T) {
+	f, _ := New(1, nil, false)
+	f.Put(genRequest("http://a.com/1", 0, 0))
+	put := make(chan error)
+	go func() {
+		put <- f.Put(genRequest("http://a.com/2", 0, 0))
+	}()
+	select {
+	case <-put:
+		t.Fatal("It still can put request into a full frontier!")
+	case <-time.After(50 * time.Millisecond):
+	}
+	checkURLs([]string{"http://a.com/1"}, getURLs(f, 1, t), t)
+	if err := <-put; err != nil {
+		t.Fatalf("An error occurs when putting request: %s", err)
+	}
+	checkURLs([]string{"http://a.com/2"}, getURLs(f, 1, t), t)
+	got := make(chan error)
+	go func() {
+		_, err := f.Get()
+		got <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if !f.Close() {
+		t.Fatal("Couldn't close frontier!")
+	}
+	if f.Close() {
+		t.Fatal("It still can close a closed frontier!")
+	}
+	if err := <-got; err != ErrClosedFrontier {
+		t.Fatalf("Inconsistent error: expected: %v, actual: %v",
+			ErrClosedFrontier, err)
+	}
+	if err := f.Put(genRequest("http://a.com/3", 0, 0)); err != ErrClosedFrontier {
+		t.Fatalf("Inconsistent error: expected: %v, actual: %v",
+			ErrClosedFrontier, err)
+	}
+}
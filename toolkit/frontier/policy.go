@@ -0,0 +1,50 @@
+package frontier
+
+import (
+	"fmt"
+
+	"github.com/dokidokikoi/webcrawler/module"
+)
+
+// PriorityFunc 代表请求优先级函数的类型。
+// 结果值越大的请求越先被取出，优先级相同时先放入的先取出。
+type PriorityFunc func(req *module.Request) float64
+
+// 内置的优先级策略
+const (
+	// 先进先出
+	POLICY_FIFO = "fifo"
+	// 广度优先，深度越小越先取出
+	POLICY_BREADTH_FIRST = "breadth_first"
+	// 最佳优先，按分析器为请求设置的优先级取出
+	POLICY_BEST_FIRST = "best_first"
+)
+
+// FIFO 代表先进先出的优先级函数。
+func FIFO(req *module.Request) float64 {
+	return 0
+}
+
+// BreadthFirst 代表广度优先的优先级函数。
+func BreadthFirst(req *module.Request) float64 {
+	return -float64(req.Depth())
+}
+
+// BestFirst 代表最佳优先的优先级函数。
+func BestFirst(req *module.Request) float64 {
+	return req.Priority()
+}
+
+// GetPolicy 用于获取给定名称的内置优先级函数。
+// 名称为空时使用先进先出策略。
+func GetPolicy(name string) (PriorityFunc, error) {
+	switch name {
+	case "", POLICY_FIFO:
+		return FIFO, nil
+	case POLICY_BREADTH_FIRST:
+		return BreadthFirst, nil
+	case POLICY_BEST_FIRST:
+		return BestFirst, nil
+	}
+	return nil, fmt.Errorf("unknown frontier policy %q", name)
+}
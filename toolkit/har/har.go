@@ -0,0 +1,237 @@
+// Package har 用于读写 HAR 1.2 格式的文件
+// 只包含本项目录制和回放时用到的字段，以下划线开头的字段是本项目的扩展字段
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"unicode/utf8"
+)
+
+// 版本
+const VERSION = "1.2"
+
+// HAR 代表 HAR 文件的内容。
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log 代表 HAR 文件的根对象。
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator 代表生成 HAR 文件的程序。
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry 代表一对请求和响应。
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+	// 经过重定向之后最终的 URL
+	FinalURL string `json:"_finalURL,omitempty"`
+	// 依次经过的被重定向的 URL，不包含最终的 URL
+	Redirects []string `json:"_redirects,omitempty"`
+}
+
+// Request 代表请求。
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// Response 代表响应。
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int64       `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+// Content 代表响应体。
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	// 为 base64 时代表 Text 经过了 Base64 编码
+	Encoding string `json:"encoding,omitempty"`
+	// 响应体是否被截断，此时 Text 只包含被保存的部分
+	Truncated bool `json:"_truncated,omitempty"`
+}
+
+// Timings 代表各阶段的用时，以毫秒表示，-1 代表不适用。
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// NameValue 代表头部、查询参数等名称和值的对。
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// New 用于创建空的 HAR 文件内容。
+func New(creator string) *HAR {
+	return &HAR{Log{
+		Version: VERSION,
+		Creator: Creator{Name: creator, Version: VERSION},
+		Entries: []Entry{},
+	}}
+}
+
+// Read 用于从 r 读取 HAR 文件的内容。
+func Read(r io.Reader) (*HAR, error) {
+	var har HAR
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("illegal HAR content: %w", err)
+	}
+	return &har, nil
+}
+
+// Write 用于把 HAR 文件的内容写入 w。
+func Write(w io.Writer, har *HAR) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(har)
+}
+
+// Writer 代表逐条写入条目的 HAR 文件写入器。
+// 条目在写入后不会被保存在内存中，关闭时才会写入文件的结尾。
+type Writer struct {
+	w       io.Writer
+	creator string
+	// 已写入的条目数
+	entries int
+	// 是否已写入文件的开头
+	begun bool
+}
+
+// NewWriter 用于创建逐条写入条目的 HAR 文件写入器。
+func NewWriter(w io.Writer, creator string) *Writer {
+	return &Writer{w: w, creator: creator}
+}
+
+// begin 用于写入文件的开头。
+func (w *Writer) begin() error {
+	if w.begun {
+		return nil
+	}
+	head := New(w.creator)
+	version, err := json.Marshal(head.Log.Version)
+	if err != nil {
+		return err
+	}
+	creator, err := json.Marshal(head.Log.Creator)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w.w, "{\"log\": {\n  \"version\": %s,\n  \"creator\": %s,\n  \"entries\": [",
+		version, creator); err != nil {
+		return err
+	}
+	w.begun = true
+	return nil
+}
+
+// WriteEntry 用于写入一个条目。
+func (w *Writer) WriteEntry(entry Entry) error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entry, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := "\n    "
+	if w.entries > 0 {
+		sep = ",\n    "
+	}
+	if _, err := io.WriteString(w.w, sep); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	w.entries++
+	return nil
+}
+
+// Close 用于写入文件的结尾，不会关闭底层的写入器。
+func (w *Writer) Close() error {
+	if err := w.begin(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, "\n  ]\n}}\n")
+	return err
+}
+
+// NewContent 用于以响应体生成 Content，非 UTF-8 的内容会经过 Base64 编码。
+func NewContent(body []byte, mimeType string) Content {
+	content := Content{Size: int64(len(body)), MimeType: mimeType}
+	if utf8.Valid(body) {
+		content.Text = string(body)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+	return content
+}
+
+// Body 用于获取解码后的响应体。
+func (content Content) Body() ([]byte, error) {
+	if content.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(content.Text)
+	}
+	return []byte(content.Text), nil
+}
+
+// Headers 用于把 HTTP 头部转换为名称和值的对，结果按名称排序。
+func Headers(header http.Header) []NameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := []NameValue{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			pairs = append(pairs, NameValue{name, value})
+		}
+	}
+	return pairs
+}
+
+// HTTPHeader 用于把名称和值的对转换为 HTTP 头部。
+func HTTPHeader(pairs []NameValue) http.Header {
+	header := http.Header{}
+	for _, pair := range pairs {
+		header.Add(pair.Name, pair.Value)
+	}
+	return header
+}
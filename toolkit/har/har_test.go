@@ -0,0 +1,87 @@
+package har
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	har := New("tester")
+	header := http.Header{}
+	header.Add("Set-Cookie", "a=1")
+	header.Add("Set-Cookie", "b=2")
+	header.Set("Content-Type", "text/plain")
+	bodies := [][]byte{[]byte("hello, 世界"), {0xff, 0xfe, 0x00}}
+	for _, body := range bodies {
+		har.Log.Entries = append(har.Log.Entries, Entry{
+			Request: Request{Method: http.MethodGet, URL: "http://example.com/"},
+			Response: Response{
+				Status:  http.StatusOK,
+				Headers: Headers(header),
+				Content: NewContent(body, "text/plain"),
+			},
+			Redirects: []string{"http://example.com/old"},
+		})
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, har); err != nil {
+		t.Fatalf("An error occurs when writing HAR: %s", err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("An error occurs when reading HAR: %s", err)
+	}
+	if read.Log.Version != VERSION || len(read.Log.Entries) != len(bodies) {
+		t.Fatalf("Inconsistent HAR log: %#v", read.Log)
+	}
+	for i, entry := range read.Log.Entries {
+		body, err := entry.Response.Content.Body()
+		if err != nil {
+			t.Fatalf("An error occurs when decoding HAR content: %s", err)
+		}
+		if !bytes.Equal(body, bodies[i]) {
+			t.Fatalf("Inconsistent HAR content: expected: %q, actual: %q", bodies[i], body)
+		}
+		readHeader := HTTPHeader(entry.Response.Headers)
+		if len(readHeader["Set-Cookie"]) != 2 || readHeader.Get("Content-Type") != "text/plain" {
+			t.Fatalf("Inconsistent HAR headers: %v", readHeader)
+		}
+		if len(entry.Redirects) != 1 {
+			t.Fatalf("Inconsistent HAR redirects: %v", entry.Redirects)
+		}
+	}
+	if read.Log.Entries[1].Response.Content.Encoding != "base64" {
+		t.Fatal("The binary content should be encoded with Base64!")
+	}
+	if _, err := Read(bytes.NewBufferString("[")); err == nil {
+		t.Fatal("No error when reading illegal HAR content!")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	for _, number := range []int{0, 1, 3} {
+		var buf bytes.Buffer
+		writer := NewWriter(&buf, "tester")
+		for i := 0; i < number; i++ {
+			err := writer.WriteEntry(Entry{
+				Request:  Request{Method: http.MethodGet, URL: "http://example.com/"},
+				Response: Response{Status: http.StatusOK, Content: NewContent([]byte("hello"), "text/plain")},
+			})
+			if err != nil {
+				t.Fatalf("An error occurs when writing HAR entry: %s", err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("An error occurs when closing HAR writer: %s", err)
+		}
+		read, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("An error occurs when reading HAR: %s (entries: %d)", err, number)
+		}
+		if read.Log.Version != VERSION || read.Log.Creator.Name != "tester" ||
+			len(read.Log.Entries) != number {
+			t.Fatalf("Inconsistent HAR log: %#v", read.Log)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dokidokikoi/webcrawler/errors"
+)
+
+// 主机上正在进行的请求数已达上限时，建议的重试等待时间
+const busyRetryInterval = 100 * time.Millisecond
+
+// 主机数至少达到该值时才会清理空闲的主机
+const minSweepHosts = 1024
+
+// 主机访问限制的配置
+type Config struct {
+	// 每秒最多发往同一主机的请求数，为 0 则不限制
+	RequestsPerSecond float64
+	// 两次请求同一主机之间的最小间隔，为 0 则不限制
+	MinDelay time.Duration
+	// 同一主机上同时进行的最大请求数，为 0 则不限制
+	MaxInFlight uint32
+}
+
+// Enabled 用于判断配置中是否存在有效的限制项。
+func (c Config) Enabled() bool {
+	return c.RequestsPerSecond > 0 || c.MinDelay > 0 || c.MaxInFlight > 0
+}
+
+// 主机访问限制的摘要
+type HostSummary struct {
+	// 正在进行的请求数
+	InFlight uint32 `json:"in_flight"`
+	// 正在等待许可的请求数，包括因无法立即获得许可而被推迟的请求
+	Waiting uint32 `json:"waiting"`
+	// 已获得许可的请求总数
+	Acquired uint64 `json:"acquired"`
+	// 当前生效的请求间隔
+	Delay string `json:"delay"`
+}
+
+// 主机访问限制器接口
+// 该接口的实现类型必须是并发安全的
+type HostLimiter interface {
+	// 等待直到允许向给定主机发送请求
+	// 成功时返回释放函数，请求结束后必须调用它
+	// 若上下文在等待期间被取消，则返回非 nil 的错误值
+	Acquire(ctx context.Context, host string) (release func(), err error)
+	// 尝试立即获得向给定主机发送请求的许可，不会等待
+	// 成功时返回释放函数，请求结束后必须调用它
+	// 否则释放函数为 nil，wait 代表建议在重试之前等待的时间
+	TryAcquire(host string) (release func(), wait time.Duration)
+	// 记录一个因无法立即获得许可而被推迟、稍后会再次尝试的请求
+	// 该请求会被计入等待许可的请求数，再次尝试之前必须调用返回的函数
+	Defer(host string) (done func())
+	// 为某个主机额外设置最小请求间隔，例如 robots.txt 中的 Crawl-delay
+	// 实际间隔取配置与该值中的较大者
+	SetDelay(host string, delay time.Duration)
+	// 获取各主机的摘要信息
+	// 空闲的主机可能已被清理，因此不一定包含所有访问过的主机
+	Summary() map[string]HostSummary
+}
+
+// 单个主机的访问状态
+type hostState struct {
+	// 下一次允许发送请求的时间
+	next time.Time
+	// 额外设置的请求间隔
+	extraDelay time.Duration
+	// 正在进行的请求数
+	inFlight uint32
+	// 正在等待许可的请求数，包括被推迟的请求
+	waiting uint32
+	// 已获得许可的请求总数
+	acquired uint64
+	// 有请求结束时会被关闭并替换，用于唤醒等待者
+	released chan struct{}
+}
+
+type myHostLimiter struct {
+	config Config
+	// 主机与访问状态的映射
+	hosts map[string]*hostState
+	// 主机数达到该值时清理空闲的主机
+	sweepAt int
+	lock    sync.Mutex
+}
+
+// 获取给定主机的访问状态，不存在就创建
+// 调用方必须持有锁
+func (l *myHostLimiter) state(host string) *hostState {
+	hs, ok := l.hosts[host]
+	if !ok {
+		if len(l.hosts) >= l.sweepAt {
+			l.sweep(time.Now())
+		}
+		hs = &hostState{released: make(chan struct{})}
+		l.hosts[host] = hs
+	}
+	return hs
+}
+
+// 清理空闲的主机，以免广泛爬取时主机的映射无限增长
+// 空闲是指没有正在进行或等待许可的请求，且已允许发送下一个请求
+// 额外设置了请求间隔的主机不会被清理，以免丢失 robots.txt 中的 Crawl-delay
+// 调用方必须持有锁
+func (l *myHostLimiter) sweep(now time.Time) {
+	for host, hs := range l.hosts {
+		if hs.inFlight == 0 && hs.waiting == 0 && hs.extraDelay == 0 && !now.Before(hs.next) {
+			delete(l.hosts, host)
+		}
+	}
+	// 剩余的主机数翻倍之后再清理，使清理的开销被均摊
+	l.sweepAt = 2 * len(l.hosts)
+	if l.sweepAt < minSweepHosts {
+		l.sweepAt = minSweepHosts
+	}
+}
+
+// 计算某个主机的请求间隔
+func (l *myHostLimiter) delay(hs *hostState) time.Duration {
+	delay := l.config.MinDelay
+	if l.config.RequestsPerSecond > 0 {
+		interval := time.Duration(float64(time.Second) / l.config.RequestsPerSecond)
+		if interval > delay {
+			delay = interval
+		}
+	}
+	if hs.extraDelay > delay {
+		delay = hs.extraDelay
+	}
+	return delay
+}
+
+func (l *myHostLimiter) Acquire(ctx context.Context, host string) (func(), error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	l.lock.Lock()
+	hs := l.state(host)
+	hs.waiting++
+	for {
+		now := time.Now()
+		full := l.full(hs)
+		if !full && !now.Before(hs.next) {
+			hs.waiting--
+			l.grant(hs, now)
+			l.lock.Unlock()
+			return l.releaseFunc(hs), nil
+		}
+		released := hs.released
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !full {
+			timer = time.NewTimer(hs.next.Sub(now))
+			timeout = timer.C
+		}
+		l.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			l.lock.Lock()
+			hs.waiting--
+			l.lock.Unlock()
+			return nil, ctx.Err()
+		case <-released:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		l.lock.Lock()
+	}
+}
+
+func (l *myHostLimiter) TryAcquire(host string) (func(), time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	hs := l.state(host)
+	now := time.Now()
+	if l.full(hs) {
+		wait := hs.next.Sub(now)
+		if wait < busyRetryInterval {
+			wait = busyRetryInterval
+		}
+		return nil, wait
+	}
+	if now.Before(hs.next) {
+		return nil, hs.next.Sub(now)
+	}
+	l.grant(hs, now)
+	return l.releaseFunc(hs), 0
+}
+
+func (l *myHostLimiter) Defer(host string) func() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	hs := l.state(host)
+	hs.waiting++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			hs.waiting--
+		})
+	}
+}
+
+// 判断主机上正在进行的请求数是否已达上限
+// 调用方必须持有锁
+func (l *myHostLimiter) full(hs *hostState) bool {
+	return l.config.MaxInFlight > 0 && hs.inFlight >= l.config.MaxInFlight
+}
+
+// 记录一次许可并计算下一次允许发送请求的时间
+// 调用方必须持有锁
+func (l *myHostLimiter) grant(hs *hostState, now time.Time) {
+	hs.inFlight++
+	hs.acquired++
+	hs.next = now.Add(l.delay(hs))
+}
+
+// 生成只会生效一次的释放函数
+func (l *myHostLimiter) releaseFunc(hs *hostState) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			hs.inFlight--
+			close(hs.released)
+			hs.released = make(chan struct{})
+		})
+	}
+}
+
+func (l *myHostLimiter) SetDelay(host string, delay time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.state(host).extraDelay = delay
+}
+
+func (l *myHostLimiter) Summary() map[string]HostSummary {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	summaries := make(map[string]HostSummary, len(l.hosts))
+	for host, hs := range l.hosts {
+		summaries[host] = HostSummary{
+			InFlight: hs.inFlight,
+			Waiting:  hs.waiting,
+			Acquired: hs.acquired,
+			Delay:    l.delay(hs).String(),
+		}
+	}
+	return summaries
+}
+
+// NewHostLimiter 用于创建一个主机访问限制器。
+func NewHostLimiter(config Config) (HostLimiter, error) {
+	if config.RequestsPerSecond < 0 {
+		errMsg := fmt.Sprintf("illegal requests per second for host limiter: %f",
+			config.RequestsPerSecond)
+		return nil, errors.NewIllegalParameterError(errMsg)
+	}
+	if config.MinDelay < 0 {
+		errMsg := fmt.Sprintf("illegal min delay for host limiter: %s", config.MinDelay)
+		return nil, errors.NewIllegalParameterError(errMsg)
+	}
+	return &myHostLimiter{
+		config:  config,
+		hosts:   map[string]*hostState{},
+		sweepAt: minSweepHosts,
+	}, nil
+}
@@ -0,0 +1,200 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterNew(t *testing.T) {
+	l, err := NewHostLimiter(Config{MinDelay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a host limiter: %s", err)
+	}
+	if l == nil {
+		t.Fatal("Couldn't create host limiter!")
+	}
+	invalidConfigs := []Config{
+		{RequestsPerSecond: -1},
+		{MinDelay: -time.Second},
+	}
+	for _, config := range invalidConfigs {
+		if _, err := NewHostLimiter(config); err == nil {
+			t.Fatalf("No error when create a host limiter with illegal config %#v!",
+				config)
+		}
+	}
+	if (Config{}).Enabled() {
+		t.Fatal("The empty config should not be enabled!")
+	}
+}
+
+func TestLimiterDelay(t *testing.T) {
+	delay := 50 * time.Millisecond
+	l, _ := NewHostLimiter(Config{MinDelay: delay})
+	host := "a.com"
+	start := time.Now()
+	number := 4
+	for i := 0; i < number; i++ {
+		release, err := l.Acquire(context.Background(), host)
+		if err != nil {
+			t.Fatalf("An error occurs when acquiring: %s", err)
+		}
+		release()
+	}
+	expected := delay * time.Duration(number-1)
+	if elapsed := time.Since(start); elapsed < expected {
+		t.Fatalf("The min delay was not respected: expected: >= %s, actual: %s",
+			expected, elapsed)
+	}
+	// 不同的主机之间互不影响。
+	start = time.Now()
+	release, _ := l.Acquire(context.Background(), "b.com")
+	release()
+	if elapsed := time.Since(start); elapsed >= delay {
+		t.Fatalf("Other host was throttled unexpectedly! (elapsed: %s)", elapsed)
+	}
+}
+
+func TestLimiterRequestsPerSecond(t *testing.T) {
+	l, _ := NewHostLimiter(Config{RequestsPerSecond: 20})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, _ := l.Acquire(context.Background(), "a.com")
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("The rate was not respected! (elapsed: %s)", elapsed)
+	}
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	max := uint32(2)
+	l, _ := NewHostLimiter(Config{MaxInFlight: max})
+	var current, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Acquire(context.Background(), "a.com")
+			if err != nil {
+				t.Errorf("An error occurs when acquiring: %s", err)
+				return
+			}
+			n := atomic.AddInt32(&current, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&current, -1)
+			release()
+			release()
+		}()
+	}
+	wg.Wait()
+	if peak > int32(max) {
+		t.Fatalf("Too many requests in flight: expected: <= %d, actual: %d",
+			max, peak)
+	}
+	summary := l.Summary()["a.com"]
+	if summary.Acquired != 10 || summary.InFlight != 0 || summary.Waiting != 0 {
+		t.Fatalf("Inconsistent host summary: %#v", summary)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l, _ := NewHostLimiter(Config{MaxInFlight: 1})
+	release, _ := l.Acquire(context.Background(), "a.com")
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "a.com"); err == nil {
+		t.Fatal("No error when acquire with canceled context!")
+	}
+	if waiting := l.Summary()["a.com"].Waiting; waiting != 0 {
+		t.Fatalf("Inconsistent waiting number: expected: %d, actual: %d",
+			0, waiting)
+	}
+}
+
+func TestLimiterSetDelay(t *testing.T) {
+	l, _ := NewHostLimiter(Config{MinDelay: time.Millisecond})
+	l.SetDelay("a.com", time.Second)
+	if delay := l.Summary()["a.com"].Delay; delay != "1s" {
+		t.Fatalf("Inconsistent delay: expected: %s, actual: %s", "1s", delay)
+	}
+	if delay := l.Summary()["b.com"].Delay; delay != "" {
+		t.Fatalf("Unexpected summary for unknown host: %s", delay)
+	}
+}
+
+func TestLimiterTryAcquire(t *testing.T) {
+	delay := 50 * time.Millisecond
+	l, _ := NewHostLimiter(Config{MinDelay: delay, MaxInFlight: 1})
+	release, wait := l.TryAcquire("a.com")
+	if release == nil || wait != 0 {
+		t.Fatalf("Couldn't acquire immediately! (wait: %s)", wait)
+	}
+	// 正在进行的请求数已达上限。
+	if r, wait := l.TryAcquire("a.com"); r != nil || wait < busyRetryInterval {
+		t.Fatalf("Acquired unexpectedly! (wait: %s)", wait)
+	}
+	release()
+	// 尚未达到最小请求间隔。
+	r, wait := l.TryAcquire("a.com")
+	if r != nil || wait <= 0 || wait > delay {
+		t.Fatalf("Inconsistent wait: expected: (0, %s], actual: %s", delay, wait)
+	}
+	time.Sleep(wait)
+	if r, _ := l.TryAcquire("a.com"); r == nil {
+		t.Fatal("Couldn't acquire after waiting!")
+	} else {
+		r()
+	}
+	summary := l.Summary()["a.com"]
+	if summary.Acquired != 2 || summary.InFlight != 0 || summary.Waiting != 0 {
+		t.Fatalf("Inconsistent host summary: %#v", summary)
+	}
+}
+
+func TestLimiterDefer(t *testing.T) {
+	l, _ := NewHostLimiter(Config{MaxInFlight: 1})
+	release, _ := l.TryAcquire("a.com")
+	done := l.Defer("a.com")
+	if waiting := l.Summary()["a.com"].Waiting; waiting != 1 {
+		t.Fatalf("Inconsistent waiting number: expected: %d, actual: %d", 1, waiting)
+	}
+	release()
+	done()
+	done()
+	if waiting := l.Summary()["a.com"].Waiting; waiting != 0 {
+		t.Fatalf("Inconsistent waiting number: expected: %d, actual: %d", 0, waiting)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, _ := NewHostLimiter(Config{MaxInFlight: 1})
+	l.SetDelay("delay.com", time.Second)
+	busy, _ := l.TryAcquire("busy.com")
+	defer busy()
+	for i := 0; i < 10*minSweepHosts; i++ {
+		release, _ := l.TryAcquire(fmt.Sprintf("%d.com", i))
+		release()
+	}
+	summaries := l.Summary()
+	if len(summaries) > 2*minSweepHosts {
+		t.Fatalf("The idle hosts were not swept: %d", len(summaries))
+	}
+	for _, host := range []string{"delay.com", "busy.com"} {
+		if _, ok := summaries[host]; !ok {
+			t.Fatalf("The host %q should not be swept!", host)
+		}
+	}
+}
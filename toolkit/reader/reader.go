@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
 )
 
 // 多重读取器的接口
@@ -11,6 +12,11 @@ type MultipleReader interface {
 	// 用于获取一个可关闭读取器的实例
 	// 持有该多重读取器中的值
 	Reader() io.ReadCloser
+	// 用于获取底层数据的字节数
+	Size() int64
+	// 用于释放多重读取器占用的资源，如临时文件
+	// 关闭后不能再获取读取器
+	Close() error
 }
 
 type myMultipleReader struct {
@@ -23,16 +29,74 @@ func (reader *myMultipleReader) Reader() io.ReadCloser {
 	return io.NopCloser(bytes.NewBuffer(reader.data))
 }
 
+func (reader *myMultipleReader) Size() int64 {
+	return int64(len(reader.data))
+}
+
+func (reader *myMultipleReader) Close() error {
+	return nil
+}
+
 func NewMultipleReader(reader io.Reader) (MultipleReader, error) {
 	var data []byte
 	var err error
 	if reader != nil {
 		data, err = io.ReadAll(reader)
 		if err != nil {
-			return nil, fmt.Errorf("multiple reader: couldn't create a new one: %s", err)
+			return nil, fmt.Errorf("multiple reader: couldn't create a new one: %w", err)
 		}
 	} else {
 		data = []byte{}
 	}
 	return &myMultipleReader{data}, nil
 }
+
+// fileMultipleReader 代表把底层数据暂存在临时文件中的多重读取器。
+type fileMultipleReader struct {
+	file *os.File
+	size int64
+}
+
+// 每个读取器都从文件开头独立地读取，关闭它不会关闭文件
+func (reader *fileMultipleReader) Reader() io.ReadCloser {
+	return io.NopCloser(io.NewSectionReader(reader.file, 0, reader.size))
+}
+
+func (reader *fileMultipleReader) Size() int64 {
+	return reader.size
+}
+
+func (reader *fileMultipleReader) Close() error {
+	err := reader.file.Close()
+	if rmErr := os.Remove(reader.file.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// NewSpillingMultipleReader 用于创建一个在数据较多时使用临时文件的多重读取器。
+// 数据不超过 threshold 个字节时保存在内存中，否则全部写入 dir 目录下的临时文件。
+// dir 为空时使用系统默认的临时目录，threshold 不大于 0 时等同于 NewMultipleReader。
+func NewSpillingMultipleReader(reader io.Reader, threshold int64, dir string) (MultipleReader, error) {
+	if threshold <= 0 || reader == nil {
+		return NewMultipleReader(reader)
+	}
+	head, err := io.ReadAll(io.LimitReader(reader, threshold+1))
+	if err != nil {
+		return nil, fmt.Errorf("multiple reader: couldn't create a new one: %w", err)
+	}
+	if int64(len(head)) <= threshold {
+		return &myMultipleReader{head}, nil
+	}
+	file, err := os.CreateTemp(dir, "body-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("multiple reader: couldn't create a temporary file: %s", err)
+	}
+	size, err := io.Copy(file, io.MultiReader(bytes.NewReader(head), reader))
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("multiple reader: couldn't create a new one: %w", err)
+	}
+	return &fileMultipleReader{file, size}, nil
+}
@@ -3,6 +3,7 @@ package reader
 import (
 	"bytes"
 	"io"
+	"os"
 	"strings"
 	"testing"
 )
@@ -29,3 +30,44 @@ func TestReaderNew(t *testing.T) {
 			expectedData, content2)
 	}
 }
+
+func TestSpillingReader(t *testing.T) {
+	dir := t.TempDir()
+	small := "0123456789"
+	rr, err := NewSpillingMultipleReader(strings.NewReader(small), 10, dir)
+	if err != nil {
+		t.Fatalf("An error occurs when new multiple reader: %s", err)
+	}
+	if _, ok := rr.(*myMultipleReader); !ok || rr.Size() != 10 {
+		t.Fatalf("Inconsistent multiple reader for small data: %T (size: %d)", rr, rr.Size())
+	}
+	rr.Close()
+
+	large := strings.Repeat("0987dcba", 1024)
+	rr, err = NewSpillingMultipleReader(strings.NewReader(large), 100, dir)
+	if err != nil {
+		t.Fatalf("An error occurs when new multiple reader: %s", err)
+	}
+	fr, ok := rr.(*fileMultipleReader)
+	if !ok || rr.Size() != int64(len(large)) {
+		t.Fatalf("Inconsistent multiple reader for large data: %T (size: %d)", rr, rr.Size())
+	}
+	for i := 0; i < 2; i++ {
+		reader := rr.Reader()
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("An error occurs when reading data: %s", err)
+		}
+		if string(content) != large {
+			t.Fatalf("Inconsistent data: expected length: %d, actual length: %d",
+				len(large), len(content))
+		}
+	}
+	if err := rr.Close(); err != nil {
+		t.Fatalf("An error occurs when closing multiple reader: %s", err)
+	}
+	if _, err := os.Stat(fr.file.Name()); !os.IsNotExist(err) {
+		t.Fatalf("The temporary file was not removed! (error: %v)", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package robots 用于解析 robots.txt 并判断给定路径是否允许被爬取
+// 解析规则参考 RFC 9309，并支持常见的 Crawl-delay 与 Sitemap 扩展
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robots.txt 的路径
+const Path = "/robots.txt"
+
+// 单条访问规则
+type rule struct {
+	// 是否为允许规则
+	allow bool
+	// 路径模式，支持 * 与 $
+	pattern string
+}
+
+// 针对一组用户代理的规则
+type group struct {
+	// 用户代理标识，已转为小写
+	agents []string
+	// 访问规则列表
+	rules []rule
+	// 爬取间隔，小于 0 代表未设置
+	crawlDelay time.Duration
+}
+
+// 解析后的 robots.txt
+type Robots struct {
+	groups   []*group
+	sitemaps []string
+}
+
+// 某个用户代理适用的规则集合
+type Agent struct {
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+// Allowed 用于判断给定的路径（可以包含查询字符串）是否允许被爬取。
+// 匹配规则中最长者生效，长度相同时允许规则优先。
+func (a Agent) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == Path {
+		return true
+	}
+	matchedLen := -1
+	allowed := true
+	for _, r := range a.rules {
+		if !match(r.pattern, path) {
+			continue
+		}
+		l := len(r.pattern)
+		if l > matchedLen || (l == matchedLen && r.allow) {
+			matchedLen = l
+			allowed = r.allow
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay 用于获取爬取间隔，未设置时返回 0。
+func (a Agent) CrawlDelay() time.Duration {
+	if a.crawlDelay < 0 {
+		return 0
+	}
+	return a.crawlDelay
+}
+
+// Agent 用于获取给定用户代理适用的规则。
+// 优先使用标识最长的匹配组，没有匹配时使用 * 组。
+func (r *Robots) Agent(userAgent string) Agent {
+	userAgent = strings.ToLower(userAgent)
+	var matched []*group
+	var matchedAgent string
+	var wildcard []*group
+	for _, g := range r.groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				wildcard = append(wildcard, g)
+				continue
+			}
+			if userAgent == "" || !strings.Contains(userAgent, agent) {
+				continue
+			}
+			if len(agent) > len(matchedAgent) {
+				matched = []*group{g}
+				matchedAgent = agent
+			} else if agent == matchedAgent {
+				matched = append(matched, g)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		matched = wildcard
+	}
+	result := Agent{crawlDelay: -1}
+	for _, g := range matched {
+		result.rules = append(result.rules, g.rules...)
+		if g.crawlDelay >= 0 && result.crawlDelay < 0 {
+			result.crawlDelay = g.crawlDelay
+		}
+	}
+	return result
+}
+
+// Sitemaps 用于获取 robots.txt 中声明的站点地图。
+func (r *Robots) Sitemaps() []string {
+	sitemaps := make([]string, len(r.sitemaps))
+	copy(sitemaps, r.sitemaps)
+	return sitemaps
+}
+
+// AllowAll 用于生成一个允许爬取所有路径的实例。
+func AllowAll() *Robots {
+	return &Robots{}
+}
+
+// DisallowAll 用于生成一个禁止爬取所有路径的实例。
+func DisallowAll() *Robots {
+	return &Robots{
+		groups: []*group{{
+			agents:     []string{"*"},
+			rules:      []rule{{allow: false, pattern: "/"}},
+			crawlDelay: -1,
+		}},
+	}
+}
+
+// Parse 用于解析 robots.txt 的内容。
+// 无法识别的行会被忽略。
+func Parse(reader io.Reader) (*Robots, error) {
+	robots := &Robots{}
+	var current *group
+	// 当前组是否已经出现过规则行
+	var hasRules bool
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if current == nil || hasRules {
+				current = &group{crawlDelay: -1}
+				robots.groups = append(robots.groups, current)
+				hasRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			hasRules = true
+			if value == "" {
+				continue
+			}
+			current.rules = append(current.rules, rule{
+				allow:   key == "allow",
+				pattern: value,
+			})
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			hasRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			current.crawlDelay = time.Duration(seconds * float64(time.Second))
+		case "sitemap":
+			if value != "" {
+				robots.sitemaps = append(robots.sitemaps, value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return robots, nil
+}
+
+// 判断路径是否匹配给定的模式
+// 模式中的 * 可匹配任意字符序列，末尾的 $ 代表路径结束
+func match(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	// 第一段必须是路径的前缀
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if i == len(parts)-1 && anchored {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		index := strings.Index(path[pos:], part)
+		if index < 0 {
+			return false
+		}
+		pos += index + len(part)
+	}
+	if anchored {
+		return pos == len(path)
+	}
+	return true
+}
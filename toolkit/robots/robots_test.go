@@ -0,0 +1,109 @@
+package robots
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testingRobots = `
+# 测试用的 robots.txt
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: FinderBot
+User-agent: OtherBot
+Disallow: /
+Allow: /open
+Crawl-delay: 0.5
+
+User-agent: finderbot-news
+Disallow:
+
+Sitemap: https://example.com/sitemap.xml
+Sitemap: https://example.com/news.xml
+`
+
+func TestParse(t *testing.T) {
+	robots, err := Parse(strings.NewReader(testingRobots))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing robots.txt: %s", err)
+	}
+	sitemaps := robots.Sitemaps()
+	if len(sitemaps) != 2 || sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Fatalf("Inconsistent sitemaps: %v", sitemaps)
+	}
+	cases := []struct {
+		agent   string
+		path    string
+		allowed bool
+	}{
+		{"Mozilla/5.0", "/", true},
+		{"Mozilla/5.0", "/private/a", false},
+		{"Mozilla/5.0", "/private/public/a", true},
+		{"Mozilla/5.0", "/doc/a.pdf", false},
+		{"Mozilla/5.0", "/doc/a.pdf?x=1", true},
+		{"Mozilla/5.0", "/robots.txt", true},
+		{"FinderBot/1.0", "/", false},
+		{"finderbot/1.0", "/open/a", true},
+		{"OtherBot", "/private/public", false},
+		{"FinderBot-News/2.0", "/private/a", true},
+	}
+	for _, c := range cases {
+		allowed := robots.Agent(c.agent).Allowed(c.path)
+		if allowed != c.allowed {
+			t.Fatalf("Inconsistent result: expected: %v, actual: %v (agent: %s, path: %s)",
+				c.allowed, allowed, c.agent, c.path)
+		}
+	}
+	delays := map[string]time.Duration{
+		"Mozilla/5.0":    2 * time.Second,
+		"FinderBot/1.0":  500 * time.Millisecond,
+		"FinderBot-News": 0,
+	}
+	for agent, expected := range delays {
+		if delay := robots.Agent(agent).CrawlDelay(); delay != expected {
+			t.Fatalf("Inconsistent crawl delay: expected: %s, actual: %s (agent: %s)",
+				expected, delay, agent)
+		}
+	}
+}
+
+func TestAllowAndDisallowAll(t *testing.T) {
+	if !AllowAll().Agent("any").Allowed("/a") {
+		t.Fatal("It still disallows path with allow-all robots!")
+	}
+	agent := DisallowAll().Agent("any")
+	if agent.Allowed("/a") {
+		t.Fatal("It still allows path with disallow-all robots!")
+	}
+	if !agent.Allowed(Path) {
+		t.Fatal("The robots.txt itself should always be allowed!")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		matched bool
+	}{
+		{"/a", "/a/b", true},
+		{"/a", "/b", false},
+		{"/a$", "/a", true},
+		{"/a$", "/a/b", false},
+		{"/*/c", "/a/b/c", true},
+		{"/*.php$", "/a/b.php", true},
+		{"/*.php$", "/a/b.php5", false},
+		{"*", "/anything", true},
+	}
+	for _, c := range cases {
+		if matched := match(c.pattern, c.path); matched != c.matched {
+			t.Fatalf("Inconsistent match result: expected: %v, actual: %v (pattern: %s, path: %s)",
+				c.matched, matched, c.pattern, c.path)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package urlfilter 提供按顺序匹配的 URL 允许/拒绝规则
+// 首个匹配的规则决定 URL 是否被允许，没有规则匹配时允许
+package urlfilter
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// 规则的动作
+const (
+	// 允许匹配的 URL
+	ACTION_ALLOW = "allow"
+	// 拒绝匹配的 URL
+	ACTION_DENY = "deny"
+)
+
+// URL 过滤规则
+// 规则中设置的各项条件全部成立时规则才匹配，未设置任何条件的规则匹配所有 URL
+// 通配模式中的 * 可匹配任意字符序列，? 可匹配单个字符
+type Rule struct {
+	// 规则的名称，用于统计命中次数，为空时使用规则的序号
+	Name string `json:"name"`
+	// 动作，可选 allow 与 deny
+	Action string `json:"action"`
+	// 主机名的通配模式，如 *.example.com，不区分大小写
+	Host string `json:"host,omitempty"`
+	// 主机名的正则表达式
+	HostRegexp string `json:"host_regexp,omitempty"`
+	// 路径的通配模式，如 /news/*
+	Path string `json:"path,omitempty"`
+	// 路径的正则表达式
+	PathRegexp string `json:"path_regexp,omitempty"`
+	// 查询字符串（不含 ?）的通配模式
+	Query string `json:"query,omitempty"`
+	// 查询字符串（不含 ?）的正则表达式
+	QueryRegexp string `json:"query_regexp,omitempty"`
+	// 路径的扩展名列表，如 .pdf，不区分大小写
+	Extensions []string `json:"extensions,omitempty"`
+	// 查询参数的数量超过此值时条件成立，为 0 时不检查
+	MaxQueryParams int `json:"max_query_params,omitempty"`
+	// URL 的长度超过此值时条件成立，为 0 时不检查
+	MaxURLLength int `json:"max_url_length,omitempty"`
+}
+
+// Same 用于判断两条规则是否相同。
+func (r *Rule) Same(another *Rule) bool {
+	if r.Name != another.Name ||
+		r.Action != another.Action ||
+		r.Host != another.Host ||
+		r.HostRegexp != another.HostRegexp ||
+		r.Path != another.Path ||
+		r.PathRegexp != another.PathRegexp ||
+		r.Query != another.Query ||
+		r.QueryRegexp != another.QueryRegexp ||
+		r.MaxQueryParams != another.MaxQueryParams ||
+		r.MaxURLLength != another.MaxURLLength ||
+		len(r.Extensions) != len(another.Extensions) {
+		return false
+	}
+	for i, ext := range r.Extensions {
+		if ext != another.Extensions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// RuleStats 代表规则的命中统计。
+type RuleStats struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Hits   uint64 `json:"hits"`
+}
+
+// URL 过滤器接口
+// 该接口的实现类型必须是并发安全的
+type Filter interface {
+	// 判断 URL 是否被允许，并为首个匹配的规则记录一次命中
+	// 结果值 index 代表首个匹配的规则的序号，没有规则匹配时为 -1
+	Allowed(u *url.URL) (allowed bool, index int)
+	// 获取各规则的命中统计，顺序与规则一致
+	Stats() []RuleStats
+}
+
+// 编译后的规则
+type compiledRule struct {
+	name  string
+	allow bool
+	// 各项条件，全部成立时规则才匹配
+	conds []func(u *url.URL) bool
+	// 命中次数
+	hits uint64
+}
+
+// URL 过滤器的实现类型
+type myFilter struct {
+	rules []*compiledRule
+}
+
+// New 用于创建一个 URL 过滤器。
+func New(rules []Rule) (Filter, error) {
+	f := &myFilter{rules: make([]*compiledRule, 0, len(rules))}
+	for i, rule := range rules {
+		cr, err := compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("illegal URL rule #%d: %s", i+1, err)
+		}
+		if cr.name == "" {
+			cr.name = fmt.Sprintf("#%d", i+1)
+		}
+		f.rules = append(f.rules, cr)
+	}
+	return f, nil
+}
+
+// 编译规则
+func compile(rule Rule) (*compiledRule, error) {
+	cr := &compiledRule{name: rule.Name}
+	switch rule.Action {
+	case ACTION_ALLOW:
+		cr.allow = true
+	case ACTION_DENY:
+	default:
+		return nil, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if rule.MaxQueryParams < 0 {
+		return nil, fmt.Errorf("negative max query params")
+	}
+	if rule.MaxURLLength < 0 {
+		return nil, fmt.Errorf("negative max URL length")
+	}
+	host := func(u *url.URL) string { return strings.ToLower(u.Hostname()) }
+	query := func(u *url.URL) string { return u.RawQuery }
+	patterns := []struct {
+		pattern string
+		glob    bool
+		part    func(u *url.URL) string
+	}{
+		{strings.ToLower(rule.Host), true, host},
+		{rule.HostRegexp, false, host},
+		{rule.Path, true, escapedPath},
+		{rule.PathRegexp, false, escapedPath},
+		{rule.Query, true, query},
+		{rule.QueryRegexp, false, query},
+	}
+	for _, p := range patterns {
+		if p.pattern == "" {
+			continue
+		}
+		expr := p.pattern
+		if p.glob {
+			expr = globToRegexp(p.pattern)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		part := p.part
+		cr.conds = append(cr.conds, func(u *url.URL) bool {
+			return re.MatchString(part(u))
+		})
+	}
+	if len(rule.Extensions) > 0 {
+		exts := map[string]struct{}{}
+		for _, ext := range rule.Extensions {
+			ext = strings.ToLower(ext)
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			exts[ext] = struct{}{}
+		}
+		cr.conds = append(cr.conds, func(u *url.URL) bool {
+			_, ok := exts[strings.ToLower(path.Ext(u.Path))]
+			return ok
+		})
+	}
+	if max := rule.MaxQueryParams; max > 0 {
+		cr.conds = append(cr.conds, func(u *url.URL) bool {
+			number := 0
+			for _, values := range u.Query() {
+				number += len(values)
+			}
+			return number > max
+		})
+	}
+	if max := rule.MaxURLLength; max > 0 {
+		cr.conds = append(cr.conds, func(u *url.URL) bool {
+			return len(u.String()) > max
+		})
+	}
+	return cr, nil
+}
+
+// 获取 URL 的路径，空路径视为 /
+func escapedPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+// 把通配模式转换为完整匹配的正则表达式
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+func (f *myFilter) Allowed(u *url.URL) (bool, int) {
+	for i, cr := range f.rules {
+		if !cr.match(u) {
+			continue
+		}
+		atomic.AddUint64(&cr.hits, 1)
+		return cr.allow, i
+	}
+	return true, -1
+}
+
+// 判断规则是否匹配给定的 URL
+func (cr *compiledRule) match(u *url.URL) bool {
+	for _, cond := range cr.conds {
+		if !cond(u) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *myFilter) Stats() []RuleStats {
+	stats := make([]RuleStats, 0, len(f.rules))
+	for _, cr := range f.rules {
+		action := ACTION_DENY
+		if cr.allow {
+			action = ACTION_ALLOW
+		}
+		stats = append(stats, RuleStats{
+			Name:   cr.name,
+			Action: action,
+			Hits:   atomic.LoadUint64(&cr.hits),
+		})
+	}
+	return stats
+}
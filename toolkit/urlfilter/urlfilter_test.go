@@ -0,0 +1,83 @@
+package urlfilter
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	illegalRules := []Rule{
+		{Action: "unknown"},
+		{Action: ACTION_DENY, HostRegexp: "("},
+		{Action: ACTION_DENY, MaxQueryParams: -1},
+		{Action: ACTION_DENY, MaxURLLength: -1},
+	}
+	for _, rule := range illegalRules {
+		if _, err := New([]Rule{rule}); err == nil {
+			t.Fatalf("No error when creating filter with illegal rule %#v!", rule)
+		}
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	rules := []Rule{
+		{Name: "admin", Action: ACTION_DENY, Path: "/admin/*"},
+		{Action: ACTION_ALLOW, Host: "*.Example.com", Path: "/news/*"},
+		{Name: "static", Action: ACTION_DENY, Extensions: []string{"PDF", ".jpg"}},
+		{Name: "session", Action: ACTION_DENY, QueryRegexp: `(^|&)sid=`},
+		{Name: "page", Action: ACTION_DENY, Query: "page=?"},
+		{Name: "params", Action: ACTION_DENY, MaxQueryParams: 2},
+		{Name: "long", Action: ACTION_DENY, MaxURLLength: 60},
+		{Name: "other hosts", Action: ACTION_DENY, HostRegexp: `^(www\.)?other\.com$`},
+	}
+	f, err := New(rules)
+	if err != nil {
+		t.Fatalf("An error occurs when creating filter: %s", err)
+	}
+	cases := []struct {
+		url     string
+		allowed bool
+		index   int
+	}{
+		{"http://example.com/admin/a", false, 0},
+		{"http://www.example.com/news/a.pdf", true, 1},
+		{"http://example.com/news/a.pdf", false, 2},
+		{"http://example.com/a.JPG?x=1", false, 2},
+		{"http://example.com/a?x=1&sid=2", false, 3},
+		{"http://example.com/a?page=2", false, 4},
+		{"http://example.com/a?page=12", true, -1},
+		{"http://example.com/a?x=1&y=2&y=3", false, 5},
+		{"http://example.com/" + strings.Repeat("a", 50), false, 6},
+		{"http://other.com", false, 7},
+		{"http://another.com/", true, -1},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.url)
+		allowed, index := f.Allowed(u)
+		if allowed != c.allowed || index != c.index {
+			t.Fatalf("Inconsistent result: expected: %v (rule %d), actual: %v (rule %d) (URL: %s)",
+				c.allowed, c.index, allowed, index, c.url)
+		}
+	}
+	stats := f.Stats()
+	if len(stats) != len(rules) {
+		t.Fatalf("Inconsistent stats number: expected: %d, actual: %d",
+			len(rules), len(stats))
+	}
+	expected := []RuleStats{
+		{"admin", ACTION_DENY, 1},
+		{"#2", ACTION_ALLOW, 1},
+		{"static", ACTION_DENY, 2},
+		{"session", ACTION_DENY, 1},
+		{"page", ACTION_DENY, 1},
+		{"params", ACTION_DENY, 1},
+		{"long", ACTION_DENY, 1},
+		{"other hosts", ACTION_DENY, 1},
+	}
+	for i, s := range stats {
+		if s != expected[i] {
+			t.Fatalf("Inconsistent stats: expected: %#v, actual: %#v", expected[i], s)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package urlnorm 用于把 URL 规范化，以便识别指向同一页面的不同写法
+package urlnorm
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// 规范化规则
+// 以下处理总会进行：
+// 把协议和主机名转为小写、去掉默认端口、解析路径中的 . 和 ..、把空路径补为 /
+type Rules struct {
+	// 是否保留片段（# 之后的部分）
+	KeepFragment bool
+	// 是否保留查询参数的原有顺序，否则按参数名排序
+	KeepQueryOrder bool
+	// 需要去掉的查询参数名，以 * 结尾时按前缀匹配，如 utm_*
+	StripParams []string
+}
+
+// URL 规范化器接口
+// 该接口的实现类型必须是并发安全的
+type Normalizer interface {
+	// 获取给定 URL 规范化后的副本
+	Normalize(u *url.URL) *url.URL
+	// 获取给定 URL 规范化后的字符串形式
+	NormalizeString(rawURL string) (string, error)
+}
+
+type myNormalizer struct {
+	rules Rules
+	// 需要完整匹配的参数名
+	stripExact map[string]struct{}
+	// 需要按前缀匹配的参数名前缀
+	stripPrefixes []string
+}
+
+// New 用于创建一个 URL 规范化器。
+func New(rules Rules) Normalizer {
+	n := &myNormalizer{
+		rules:      rules,
+		stripExact: map[string]struct{}{},
+	}
+	for _, p := range rules.StripParams {
+		if strings.HasSuffix(p, "*") {
+			n.stripPrefixes = append(n.stripPrefixes, strings.TrimSuffix(p, "*"))
+		} else {
+			n.stripExact[p] = struct{}{}
+		}
+	}
+	return n
+}
+
+// 各协议的默认端口
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+func (n *myNormalizer) Normalize(u *url.URL) *url.URL {
+	if u == nil {
+		return nil
+	}
+	result := *u
+	if u.User != nil {
+		user := *u.User
+		result.User = &user
+	}
+	result.Scheme = strings.ToLower(result.Scheme)
+	host := strings.ToLower(result.Host)
+	if port, ok := defaultPorts[result.Scheme]; ok {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+	result.Host = host
+	if result.Opaque == "" {
+		path := RemoveDotSegments(result.EscapedPath())
+		if path == "" && result.Host != "" {
+			path = "/"
+		}
+		if p, err := url.PathUnescape(path); err == nil {
+			result.Path = p
+			result.RawPath = ""
+			if result.EscapedPath() != path {
+				result.RawPath = path
+			}
+		}
+	}
+	result.RawQuery = n.normalizeQuery(result.RawQuery)
+	result.ForceQuery = false
+	if !n.rules.KeepFragment {
+		result.Fragment = ""
+		result.RawFragment = ""
+	}
+	return &result
+}
+
+func (n *myNormalizer) NormalizeString(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return n.Normalize(u).String(), nil
+}
+
+// 去掉需要去掉的参数，并在必要时按参数名排序
+// 参数的原有编码会被保留
+func (n *myNormalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	type param struct {
+		name string
+		raw  string
+	}
+	var params []param
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		name := raw
+		if i := strings.Index(raw, "="); i >= 0 {
+			name = raw[:i]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if n.stripped(name) {
+			continue
+		}
+		params = append(params, param{name: name, raw: raw})
+	}
+	if !n.rules.KeepQueryOrder {
+		// 同名参数保持原有顺序
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].name < params[j].name
+		})
+	}
+	raws := make([]string, len(params))
+	for i, p := range params {
+		raws[i] = p.raw
+	}
+	return strings.Join(raws, "&")
+}
+
+// 判断给定的参数是否需要去掉
+func (n *myNormalizer) stripped(name string) bool {
+	if _, ok := n.stripExact[name]; ok {
+		return true
+	}
+	for _, prefix := range n.stripPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveDotSegments 用于按 RFC 3986 5.2.4 节解析路径中的 . 和 ..。
+func RemoveDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	var output []string
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			// 不能越过根路径
+			if len(output) > 1 || (len(output) == 1 && output[0] != "") {
+				output = output[:len(output)-1]
+			}
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, seg)
+		}
+	}
+	result := strings.Join(output, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package urlnorm
+
+import "testing"
+
+func TestNormalizeString(t *testing.T) {
+	n := New(Rules{StripParams: []string{"utm_*", "sessionid"}})
+	cases := map[string]string{
+		"http://a.com/x?b=1&a=2":                        "http://a.com/x?a=2&b=1",
+		"HTTP://A.com:80/x?a=2&b=1":                     "http://a.com/x?a=2&b=1",
+		"http://a.com/x?a=2&b=1#frag":                   "http://a.com/x?a=2&b=1",
+		"https://a.com:443":                             "https://a.com/",
+		"https://a.com:8443/":                           "https://a.com:8443/",
+		"http://a.com/a/./b/../c/":                      "http://a.com/a/c/",
+		"http://a.com/../../x":                          "http://a.com/x",
+		"http://a.com/x?utm_source=s&id=1&utm_medium=m": "http://a.com/x?id=1",
+		"http://a.com/x?sessionid=1":                    "http://a.com/x",
+		"http://a.com/x?b=2&a=1&b=1":                    "http://a.com/x?a=1&b=2&b=1",
+		"http://a.com/a%2Fb?q=%E4%B8%AD":                "http://a.com/a%2Fb?q=%E4%B8%AD",
+		"http://user@A.com/":                            "http://user@a.com/",
+	}
+	for raw, expected := range cases {
+		actual, err := n.NormalizeString(raw)
+		if err != nil {
+			t.Fatalf("An error occurs when normalizing URL %q: %s", raw, err)
+		}
+		if actual != expected {
+			t.Fatalf("Inconsistent normalized URL for %q: expected: %s, actual: %s",
+				raw, expected, actual)
+		}
+	}
+	if _, err := n.NormalizeString("http://a b.com/%zz"); err == nil {
+		t.Fatal("No error when normalizing an invalid URL!")
+	}
+}
+
+func TestKeepRules(t *testing.T) {
+	n := New(Rules{KeepFragment: true, KeepQueryOrder: true})
+	raw := "http://a.com/x?b=1&a=2#frag"
+	actual, _ := n.NormalizeString(raw)
+	if actual != raw {
+		t.Fatalf("Inconsistent normalized URL: expected: %s, actual: %s",
+			raw, actual)
+	}
+}
+
+func TestRemoveDotSegments(t *testing.T) {
+	cases := map[string]string{
+		"":                   "",
+		"/":                  "/",
+		"/a/b/c/./../../g":   "/a/g",
+		"mid/content=5/../6": "mid/6",
+		"/a/..":              "/",
+		"/a/.":               "/a/",
+		"/a.b/c.d":           "/a.b/c.d",
+	}
+	for path, expected := range cases {
+		if actual := RemoveDotSegments(path); actual != expected {
+			t.Fatalf("Inconsistent path for %q: expected: %q, actual: %q",
+				path, expected, actual)
+		}
+	}
+}
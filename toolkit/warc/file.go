@@ -0,0 +1,211 @@
+package warc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 单个 WARC 文件的默认大小上限
+const DEFAULT_MAX_SIZE = 1 << 30
+
+// 正在写入的 WARC 文件的后缀，写完之后会被去掉
+const OPEN_SUFFIX = ".open"
+
+// FileConfig 代表 WARC 文件写入器的配置。
+type FileConfig struct {
+	// 文件所在的目录
+	Dir string
+	// 文件名的前缀，为空时使用 crawl
+	Prefix string
+	// 单个文件的大小上限，为 0 时使用默认值
+	// 一组记录写入后文件超过上限时，这组记录会被移到新的文件中，
+	// 所以只有一组记录本身超过上限时文件才会超过上限
+	MaxSize int64
+	// 写入每个文件开头的 warcinfo 记录的内容，为空时使用默认内容
+	Info string
+}
+
+// FileWriter 代表会按大小轮换的 WARC 文件写入器。
+// 每条记录会被压缩为单独的 gzip 成员，文件的扩展名为 .warc.gz。
+// 正在写入的文件带有 OPEN_SUFFIX 后缀，轮换或关闭时会被去掉。
+// 该类型的值是并发安全的。
+type FileWriter struct {
+	config FileConfig
+	// 当前的文件
+	file *os.File
+	// 当前文件已写入的字节数
+	size int64
+	// 当前文件中已写入的记录组数，不包括 warcinfo 记录
+	groups int
+	// 文件的序号
+	seq int
+	// 已写完的文件的路径
+	files []string
+	// 是否已关闭
+	closed bool
+	lock   sync.Mutex
+}
+
+// NewFileWriter 用于创建会按大小轮换的 WARC 文件写入器。
+// 文件会在第一次写入时被创建。
+func NewFileWriter(config FileConfig) (*FileWriter, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("empty WARC directory")
+	}
+	if config.MaxSize < 0 {
+		return nil, fmt.Errorf("illegal WARC file size limit: %d", config.MaxSize)
+	}
+	if config.MaxSize == 0 {
+		config.MaxSize = DEFAULT_MAX_SIZE
+	}
+	if config.Prefix == "" {
+		config.Prefix = "crawl"
+	}
+	if config.Info == "" {
+		config.Info = "software: webcrawler\r\nformat: WARC File Format 1.1\r\n"
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileWriter{config: config}, nil
+}
+
+// WriteRecords 用于写入一组记录，同一组记录总是位于同一个文件中。
+// 记录会被流式写入文件，写入失败时这组记录中已写入的部分会被去掉。
+func (w *FileWriter) WriteRecords(records ...*Record) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return fmt.Errorf("the WARC file writer has been closed")
+	}
+	if w.file == nil {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	start := w.size
+	if err := w.write(records); err != nil {
+		w.discard(start)
+		return err
+	}
+	if w.size <= w.config.MaxSize || w.groups == 1 {
+		return nil
+	}
+	// 压缩后的大小只有写入后才能知道，超过上限时把这组记录移到新的文件中
+	w.groups--
+	w.discard(start)
+	if err := w.finish(); err != nil {
+		return err
+	}
+	if err := w.rotate(); err != nil {
+		return err
+	}
+	start = w.size
+	if err := w.write(records); err != nil {
+		w.discard(start)
+		return err
+	}
+	return nil
+}
+
+// write 用于把一组记录写入当前的文件。
+func (w *FileWriter) write(records []*Record) error {
+	writer := NewWriter(w.file, true)
+	for _, record := range records {
+		n, err := writer.WriteRecord(record)
+		w.size += n
+		if err != nil {
+			return err
+		}
+	}
+	w.groups++
+	return nil
+}
+
+// discard 用于把当前的文件截断到给定的大小，以去掉最后写入的一组记录。
+// 无法截断时当前的文件会被关闭且保留后缀，之后的记录会被写入新的文件。
+func (w *FileWriter) discard(size int64) {
+	err := w.file.Truncate(size)
+	if err == nil {
+		_, err = w.file.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		w.file.Close()
+		w.file = nil
+		w.size = 0
+		w.groups = 0
+		return
+	}
+	w.size = size
+}
+
+// rotate 用于创建新的文件并写入 warcinfo 记录。
+func (w *FileWriter) rotate() error {
+	w.seq++
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz",
+		w.config.Prefix, now.UTC().Format("20060102150405"), w.seq)
+	file, err := os.OpenFile(filepath.Join(w.config.Dir, name+OPEN_SUFFIX),
+		os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	info := NewRecord(TYPE_WARCINFO, now)
+	info.Set(FIELD_FILENAME, name)
+	info.Set(FIELD_CONTENT_TYPE, CONTENT_TYPE_WARC_FIELDS)
+	info.Block = []byte(w.config.Info)
+	n, err := NewWriter(file, true).WriteRecord(info)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	w.file = file
+	w.size = n
+	w.groups = 0
+	return nil
+}
+
+// finish 用于关闭当前的文件并去掉它的后缀。
+func (w *FileWriter) finish() error {
+	if w.file == nil {
+		return nil
+	}
+	file := w.file
+	w.file = nil
+	w.size = 0
+	w.groups = 0
+	if err := file.Close(); err != nil {
+		return err
+	}
+	path := file.Name()[:len(file.Name())-len(OPEN_SUFFIX)]
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+	w.files = append(w.files, path)
+	return nil
+}
+
+// Files 用于获取已写完的文件的路径。
+func (w *FileWriter) Files() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	files := make([]string, len(w.files))
+	copy(files, w.files)
+	return files
+}
+
+// Close 用于关闭写入器，当前的文件会被写完。
+func (w *FileWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.finish()
+}
@@ -0,0 +1,135 @@
+package warc
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Exchange 代表一对 HTTP 请求和响应。
+type Exchange struct {
+	// 发出请求的时间
+	Date time.Time `json:"date"`
+	// 请求的方法，为空时等同于 GET
+	Method string `json:"method"`
+	// 请求的 URL
+	URL string `json:"url"`
+	// 请求的头部
+	RequestHeader http.Header `json:"request_header,omitempty"`
+	// 经过重定向之后最终的 URL，为空时等同于请求的 URL
+	FinalURL string `json:"final_url,omitempty"`
+	// 响应的协议，为空时等同于 HTTP/1.1
+	Proto string `json:"proto,omitempty"`
+	// 响应的状态，例如 200 OK
+	Status string `json:"status"`
+	// 响应的头部
+	Header http.Header `json:"header,omitempty"`
+	// 响应体
+	Body []byte `json:"body"`
+	// 响应体所在的文件，不为空时代替 Body，以免较大的响应体占用内存
+	BodyFile string `json:"body_file,omitempty"`
+	// 响应体被截断的原因，例如 TRUNCATED_LENGTH，为空时代表未被截断
+	Truncated string `json:"truncated,omitempty"`
+}
+
+// Records 用于把一对请求和响应转换为 request 记录和 response 记录。
+// request 记录的目标 URI 是请求的 URL，response 记录的是最终的 URL，
+// 两者都带有内容块的摘要，response 记录还带有响应体的摘要。
+// 响应体被截断时，response 记录会带有 WARC-Truncated 字段。
+// 响应体在文件中时，response 记录的内容块也会在写入时从该文件中读取。
+func (ex *Exchange) Records() ([]*Record, error) {
+	method := ex.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	requestURI := ex.URL
+	host := ""
+	if u, err := url.Parse(ex.URL); err == nil {
+		requestURI = u.RequestURI()
+		host = u.Host
+	}
+	var reqBlock bytes.Buffer
+	fmt.Fprintf(&reqBlock, "%s %s HTTP/1.1\r\nHost: %s\r\n", method, requestURI, host)
+	ex.RequestHeader.Write(&reqBlock)
+	reqBlock.WriteString("\r\n")
+	reqRecord := NewRecord(TYPE_REQUEST, ex.Date)
+	reqRecord.Set(FIELD_TARGET_URI, ex.URL)
+	reqRecord.Set(FIELD_CONTENT_TYPE, CONTENT_TYPE_HTTP_REQUEST)
+	reqRecord.Block = reqBlock.Bytes()
+	reqRecord.Set(FIELD_BLOCK_DIGEST, Digest(reqRecord.Block))
+
+	proto := ex.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	header := ex.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	var body io.Reader = bytes.NewReader(ex.Body)
+	bodySize := int64(len(ex.Body))
+	if ex.BodyFile != "" {
+		file, err := os.Open(ex.BodyFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		body = file
+		bodySize = info.Size()
+	}
+	// 响应体已被解码，所以要去掉传输相关的头部
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", fmt.Sprint(bodySize))
+	var respBlock bytes.Buffer
+	fmt.Fprintf(&respBlock, "%s %s\r\n", proto, ex.Status)
+	header.Write(&respBlock)
+	respBlock.WriteString("\r\n")
+	blockHash := sha1.New()
+	blockHash.Write(respBlock.Bytes())
+	payloadHash := sha1.New()
+	if _, err := io.CopyN(io.MultiWriter(blockHash, payloadHash), body, bodySize); err != nil {
+		return nil, unexpected(err)
+	}
+	if ex.BodyFile == "" {
+		respBlock.Write(ex.Body)
+	}
+	targetURI := ex.FinalURL
+	if targetURI == "" {
+		targetURI = ex.URL
+	}
+	respRecord := NewRecord(TYPE_RESPONSE, ex.Date)
+	respRecord.Set(FIELD_TARGET_URI, targetURI)
+	respRecord.Set(FIELD_CONCURRENT_TO, reqRecord.ID())
+	respRecord.Set(FIELD_CONTENT_TYPE, CONTENT_TYPE_HTTP_RESPONSE)
+	respRecord.Block = respBlock.Bytes()
+	respRecord.BlockFile = ex.BodyFile
+	respRecord.Set(FIELD_BLOCK_DIGEST, formatDigest(blockHash))
+	respRecord.Set(FIELD_PAYLOAD_DIGEST, formatDigest(payloadHash))
+	if ex.Truncated != "" {
+		respRecord.Set(FIELD_TRUNCATED, ex.Truncated)
+	}
+	return []*Record{reqRecord, respRecord}, nil
+}
+
+// Digest 用于计算 WARC 摘要字段使用的 SHA-1 摘要，形如 sha1:<Base32>。
+func Digest(data []byte) string {
+	h := sha1.New()
+	h.Write(data)
+	return formatDigest(h)
+}
+
+// formatDigest 用于把已写入内容的 SHA-1 摘要转换为摘要字段使用的格式。
+func formatDigest(h hash.Hash) string {
+	return "sha1:" + base32.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,323 @@
+// Package warc 用于读写 WARC/1.1 格式的记录
+// 写入时可以把每条记录压缩为单独的 gzip 成员，读取时会自动识别压缩
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 版本行
+const VERSION = "WARC/1.1"
+
+// 读取时允许的内容块的最大字节数
+const MAX_BLOCK_SIZE = 256 << 20
+
+// 常用的字段名称
+const (
+	FIELD_TYPE           = "WARC-Type"
+	FIELD_RECORD_ID      = "WARC-Record-ID"
+	FIELD_DATE           = "WARC-Date"
+	FIELD_TARGET_URI     = "WARC-Target-URI"
+	FIELD_CONCURRENT_TO  = "WARC-Concurrent-To"
+	FIELD_BLOCK_DIGEST   = "WARC-Block-Digest"
+	FIELD_PAYLOAD_DIGEST = "WARC-Payload-Digest"
+	FIELD_FILENAME       = "WARC-Filename"
+	FIELD_TRUNCATED      = "WARC-Truncated"
+	FIELD_CONTENT_TYPE   = "Content-Type"
+	FIELD_CONTENT_LENGTH = "Content-Length"
+)
+
+// 记录的类型
+const (
+	TYPE_WARCINFO = "warcinfo"
+	TYPE_REQUEST  = "request"
+	TYPE_RESPONSE = "response"
+	TYPE_METADATA = "metadata"
+)
+
+// 内容块被截断的原因
+const (
+	// 超过了长度限制
+	TRUNCATED_LENGTH = "length"
+	// 其他原因，例如读取方没有读完内容
+	TRUNCATED_UNSPECIFIED = "unspecified"
+)
+
+// 保存 HTTP 消息的记录的内容类型
+const (
+	CONTENT_TYPE_HTTP_REQUEST  = "application/http;msgtype=request"
+	CONTENT_TYPE_HTTP_RESPONSE = "application/http;msgtype=response"
+	CONTENT_TYPE_WARC_FIELDS   = "application/warc-fields"
+)
+
+// Field 代表记录头部中的命名字段。
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Record 代表一条 WARC 记录。
+type Record struct {
+	// 命名字段，按写入的顺序保存
+	// Content-Length 会在写入时按内容块的长度生成
+	Fields []Field
+	// 内容块
+	Block []byte
+	// 内容块中接在 Block 之后的内容所在的文件，为空时内容块只有 Block
+	// 写入时会从文件中流式读取，以免较大的内容块占用内存
+	BlockFile string
+}
+
+// NewRecord 用于创建给定类型的记录，会生成记录 ID 和日期。
+func NewRecord(recordType string, date time.Time) *Record {
+	record := &Record{}
+	record.Set(FIELD_TYPE, recordType)
+	record.Set(FIELD_RECORD_ID, NewRecordID())
+	record.Set(FIELD_DATE, FormatDate(date))
+	return record
+}
+
+// Get 用于获取字段的值，字段名称不区分大小写。
+func (record *Record) Get(name string) string {
+	for _, field := range record.Fields {
+		if strings.EqualFold(field.Name, name) {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// Set 用于设置字段的值，已存在的同名字段会被替换。
+func (record *Record) Set(name, value string) {
+	for i, field := range record.Fields {
+		if strings.EqualFold(field.Name, name) {
+			record.Fields[i].Value = value
+			return
+		}
+	}
+	record.Fields = append(record.Fields, Field{name, value})
+}
+
+// Type 用于获取记录的类型。
+func (record *Record) Type() string {
+	return record.Get(FIELD_TYPE)
+}
+
+// ID 用于获取记录的 ID。
+func (record *Record) ID() string {
+	return record.Get(FIELD_RECORD_ID)
+}
+
+// blockSize 用于获取内容块的字节数。
+func (record *Record) blockSize() (int64, error) {
+	size := int64(len(record.Block))
+	if record.BlockFile != "" {
+		info, err := os.Stat(record.BlockFile)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// NewRecordID 用于生成基于随机 UUID 的记录 ID。
+func NewRecordID() string {
+	var uuid [16]byte
+	rand.Read(uuid[:])
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>",
+		uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
+
+// FormatDate 用于把时间转换为 WARC-Date 字段使用的格式。
+func FormatDate(date time.Time) string {
+	return date.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// ParseDate 用于解析 WARC-Date 字段的值。
+func ParseDate(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// Writer 代表 WARC 记录的写入器。
+// 该类型的值不是并发安全的。
+type Writer struct {
+	w io.Writer
+	// 是否把每条记录压缩为单独的 gzip 成员
+	compress bool
+}
+
+// NewWriter 用于创建 WARC 记录的写入器。
+func NewWriter(w io.Writer, compress bool) *Writer {
+	return &Writer{w: w, compress: compress}
+}
+
+// WriteRecord 用于写入一条记录，结果值中的整数代表实际写入的字节数。
+// 出错时记录可能只被写入了一部分。
+func (writer *Writer) WriteRecord(record *Record) (int64, error) {
+	counter := &countingWriter{w: writer.w}
+	if !writer.compress {
+		err := writeRecord(counter, record)
+		return counter.n, err
+	}
+	zw := gzip.NewWriter(counter)
+	err := writeRecord(zw, record)
+	if err == nil {
+		err = zw.Close()
+	}
+	return counter.n, err
+}
+
+// writeRecord 用于把未压缩的记录写入给定的写入器。
+func writeRecord(w io.Writer, record *Record) error {
+	size, err := record.blockSize()
+	if err != nil {
+		return err
+	}
+	var head bytes.Buffer
+	head.WriteString(VERSION + "\r\n")
+	for _, field := range record.Fields {
+		if strings.EqualFold(field.Name, FIELD_CONTENT_LENGTH) {
+			continue
+		}
+		fmt.Fprintf(&head, "%s: %s\r\n", field.Name, field.Value)
+	}
+	fmt.Fprintf(&head, "%s: %d\r\n\r\n", FIELD_CONTENT_LENGTH, size)
+	if _, err := w.Write(head.Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.Write(record.Block); err != nil {
+		return err
+	}
+	if record.BlockFile != "" {
+		file, err := os.Open(record.BlockFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := io.CopyN(w, file, size-int64(len(record.Block))); err != nil {
+			return unexpected(err)
+		}
+	}
+	_, err = io.WriteString(w, "\r\n\r\n")
+	return err
+}
+
+// countingWriter 代表会统计写入的字节数的写入器。
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Reader 代表 WARC 记录的读取器。
+// 该类型的值不是并发安全的。
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader 用于创建 WARC 记录的读取器，会自动识别 gzip 压缩。
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(zr)
+	}
+	return &Reader{br}, nil
+}
+
+// ReadRecord 用于读取下一条记录，没有更多记录时返回 io.EOF。
+func (reader *Reader) ReadRecord() (*Record, error) {
+	line, err := reader.readLine()
+	for err == nil && line == "" {
+		line, err = reader.readLine()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("illegal WARC version line: %q", line)
+	}
+	record := &Record{}
+	for {
+		line, err = reader.readLine()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("illegal WARC field: %q", line)
+		}
+		record.Fields = append(record.Fields,
+			Field{strings.TrimSpace(name), strings.TrimSpace(value)})
+	}
+	length, err := strconv.ParseInt(record.Get(FIELD_CONTENT_LENGTH), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("illegal WARC content length: %q", record.Get(FIELD_CONTENT_LENGTH))
+	}
+	if length > MAX_BLOCK_SIZE {
+		return nil, fmt.Errorf("too large WARC content length: %d (limit: %d)", length, MAX_BLOCK_SIZE)
+	}
+	// 按实际读到的内容分配空间，以免声明的长度与内容不符时分配过多的内存
+	var block bytes.Buffer
+	if _, err := io.CopyN(&block, reader.r, length); err != nil {
+		return nil, unexpected(err)
+	}
+	record.Block = block.Bytes()
+	for i := 0; i < 2; i++ {
+		line, err := reader.readLine()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if line != "" {
+			return nil, fmt.Errorf("missing WARC record end: %q", line)
+		}
+	}
+	return record, nil
+}
+
+// readLine 用于读取一行并去掉行尾的换行符。
+func (reader *Reader) readLine() (string, error) {
+	line, err := reader.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// unexpected 用于把记录中途的 io.EOF 转换为 io.ErrUnexpectedEOF。
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
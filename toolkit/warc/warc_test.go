@@ -0,0 +1,320 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		writer := NewWriter(&buf, compress)
+		date := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+		records := []*Record{
+			NewRecord(TYPE_REQUEST, date),
+			NewRecord(TYPE_RESPONSE, date),
+		}
+		records[0].Set(FIELD_TARGET_URI, "http://example.com/")
+		records[0].Set(FIELD_CONTENT_TYPE, CONTENT_TYPE_HTTP_REQUEST)
+		records[0].Block = []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		records[1].Set(FIELD_TARGET_URI, "http://example.com/")
+		records[1].Set(FIELD_CONCURRENT_TO, records[0].ID())
+		records[1].Block = []byte("HTTP/1.1 200 OK\r\n\r\n\r\nbody\r\n\r\n")
+		var total int64
+		for _, record := range records {
+			n, err := writer.WriteRecord(record)
+			if err != nil {
+				t.Fatalf("An error occurs when writing WARC record: %s", err)
+			}
+			total += n
+		}
+		if total != int64(buf.Len()) {
+			t.Fatalf("Inconsistent written bytes: expected: %d, actual: %d", buf.Len(), total)
+		}
+		if compress != bytes.HasPrefix(buf.Bytes(), []byte{0x1f, 0x8b}) {
+			t.Fatalf("Inconsistent compression: expected: %v", compress)
+		}
+		reader, err := NewReader(&buf)
+		if err != nil {
+			t.Fatalf("An error occurs when creating WARC reader: %s", err)
+		}
+		for _, expected := range records {
+			record, err := reader.ReadRecord()
+			if err != nil {
+				t.Fatalf("An error occurs when reading WARC record: %s (compress: %v)", err, compress)
+			}
+			if record.Type() != expected.Type() || record.ID() != expected.ID() ||
+				record.Get("warc-target-uri") != expected.Get(FIELD_TARGET_URI) {
+				t.Fatalf("Inconsistent WARC record: expected: %#v, actual: %#v", expected, record)
+			}
+			if !bytes.Equal(record.Block, expected.Block) {
+				t.Fatalf("Inconsistent WARC block: expected: %q, actual: %q", expected.Block, record.Block)
+			}
+		}
+		if _, err := reader.ReadRecord(); err != io.EOF {
+			t.Fatalf("Expected io.EOF after the last record, but got %v", err)
+		}
+	}
+}
+
+func TestReadIllegal(t *testing.T) {
+	for _, content := range []string{
+		"HTTP/1.1 200 OK\r\n\r\n",
+		"WARC/1.1\r\nWARC-Type: response\r\n\r\n",
+		"WARC/1.1\r\nContent-Length: 10\r\n\r\nshort",
+		"WARC/1.1\r\nContent-Length: 1\r\n\r\nxy\r\n\r\n",
+		fmt.Sprintf("WARC/1.1\r\nContent-Length: %d\r\n\r\nshort", int64(MAX_BLOCK_SIZE)+1),
+		"WARC/1.1\r\nContent-Length: 9223372036854775807\r\n\r\nshort",
+	} {
+		reader, err := NewReader(bytes.NewBufferString(content))
+		if err != nil {
+			t.Fatalf("An error occurs when creating WARC reader: %s", err)
+		}
+		if _, err := reader.ReadRecord(); err == nil || err == io.EOF {
+			t.Fatalf("No error when reading illegal WARC content %q!", content)
+		}
+	}
+}
+
+func TestRecordID(t *testing.T) {
+	pattern := regexp.MustCompile(`^<urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}>$`)
+	id := NewRecordID()
+	if !pattern.MatchString(id) {
+		t.Fatalf("Illegal record ID: %s", id)
+	}
+	if id == NewRecordID() {
+		t.Fatalf("Duplicate record ID: %s", id)
+	}
+	date := time.Date(2026, 1, 2, 3, 4, 5, 6, time.FixedZone("CST", 8*3600))
+	parsed, err := ParseDate(FormatDate(date))
+	if err != nil {
+		t.Fatalf("An error occurs when parsing WARC date: %s", err)
+	}
+	if !parsed.Equal(date) {
+		t.Fatalf("Inconsistent WARC date: expected: %s, actual: %s", date, parsed)
+	}
+}
+
+func TestExchangeRecords(t *testing.T) {
+	ex := &Exchange{
+		Date:     time.Now(),
+		URL:      "http://example.com/old?q=1",
+		FinalURL: "http://example.com/new",
+		Status:   "200 OK",
+		Header:   http.Header{"Content-Type": {"text/plain"}, "Transfer-Encoding": {"chunked"}},
+		Body:     []byte("hello"),
+	}
+	records, err := ex.Records()
+	if err != nil {
+		t.Fatalf("An error occurs when generating WARC records: %s", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Inconsistent record number: expected: %d, actual: %d", 2, len(records))
+	}
+	req, resp := records[0], records[1]
+	if req.Type() != TYPE_REQUEST || req.Get(FIELD_TARGET_URI) != ex.URL {
+		t.Fatalf("Inconsistent request record: %#v", req.Fields)
+	}
+	if !bytes.HasPrefix(req.Block, []byte("GET /old?q=1 HTTP/1.1\r\nHost: example.com\r\n")) {
+		t.Fatalf("Inconsistent request block: %q", req.Block)
+	}
+	if resp.Type() != TYPE_RESPONSE || resp.Get(FIELD_TARGET_URI) != ex.FinalURL ||
+		resp.Get(FIELD_CONCURRENT_TO) != req.ID() {
+		t.Fatalf("Inconsistent response record: %#v", resp.Fields)
+	}
+	if resp.Get(FIELD_PAYLOAD_DIGEST) != "sha1:VL2MMHO4YXUKFWV63YHTWSBM3GXKSQ2N" {
+		t.Fatalf("Inconsistent payload digest: %s", resp.Get(FIELD_PAYLOAD_DIGEST))
+	}
+	if resp.Get(FIELD_BLOCK_DIGEST) != Digest(resp.Block) {
+		t.Fatalf("Inconsistent block digest: %s", resp.Get(FIELD_BLOCK_DIGEST))
+	}
+	httpResp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(resp.Block)), nil)
+	if err != nil {
+		t.Fatalf("An error occurs when parsing the response block: %s", err)
+	}
+	body, _ := io.ReadAll(httpResp.Body)
+	if string(body) != "hello" || httpResp.Header.Get("Transfer-Encoding") != "" {
+		t.Fatalf("Inconsistent response block: %q", resp.Block)
+	}
+
+	// 响应体在文件中时，写入的内容和摘要都应与在内存中时一致
+	ex.BodyFile = filepath.Join(t.TempDir(), "body")
+	os.WriteFile(ex.BodyFile, ex.Body, 0644)
+	ex.Body = nil
+	fileRecords, err := ex.Records()
+	if err != nil {
+		t.Fatalf("An error occurs when generating WARC records: %s", err)
+	}
+	fileResp := fileRecords[1]
+	if fileResp.Get(FIELD_PAYLOAD_DIGEST) != resp.Get(FIELD_PAYLOAD_DIGEST) ||
+		fileResp.Get(FIELD_BLOCK_DIGEST) != resp.Get(FIELD_BLOCK_DIGEST) {
+		t.Fatalf("Inconsistent digests: expected: %#v, actual: %#v", resp.Fields, fileResp.Fields)
+	}
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, false).WriteRecord(fileResp); err != nil {
+		t.Fatalf("An error occurs when writing WARC record: %s", err)
+	}
+	reader, _ := NewReader(&buf)
+	record, err := reader.ReadRecord()
+	if err != nil {
+		t.Fatalf("An error occurs when reading WARC record: %s", err)
+	}
+	if !bytes.Equal(record.Block, resp.Block) {
+		t.Fatalf("Inconsistent response block: expected: %q, actual: %q", resp.Block, record.Block)
+	}
+}
+
+func TestFileWriter(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewFileWriter(FileConfig{}); err == nil {
+		t.Fatal("No error when creating a WARC file writer with empty directory!")
+	}
+	if _, err := NewFileWriter(FileConfig{Dir: dir, MaxSize: -1}); err == nil {
+		t.Fatal("No error when creating a WARC file writer with negative size limit!")
+	}
+	maxSize := int64(2048)
+	writer, err := NewFileWriter(FileConfig{Dir: dir, Prefix: "test", MaxSize: maxSize})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a WARC file writer: %s", err)
+	}
+	exchangeNumber := 20
+	for i := 0; i < exchangeNumber; i++ {
+		ex := &Exchange{
+			Date:   time.Now(),
+			URL:    fmt.Sprintf("http://example.com/%d", i),
+			Status: "200 OK",
+			Body:   []byte(fmt.Sprintf("page %d", i)),
+		}
+		records, _ := ex.Records()
+		if err := writer.WriteRecords(records...); err != nil {
+			t.Fatalf("An error occurs when writing WARC records: %s", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the WARC file writer: %s", err)
+	}
+	if err := writer.WriteRecords(NewRecord(TYPE_METADATA, time.Now())); err == nil {
+		t.Fatal("No error when writing to a closed WARC file writer!")
+	}
+	files := writer.Files()
+	if len(files) < 2 {
+		t.Fatalf("The WARC files should be rotated: %v", files)
+	}
+	if open, _ := filepath.Glob(filepath.Join(dir, "*"+OPEN_SUFFIX)); len(open) != 0 {
+		t.Fatalf("Unfinished WARC files: %v", open)
+	}
+	responses := 0
+	for _, path := range files {
+		if !strings.HasSuffix(path, ".warc.gz") {
+			t.Fatalf("Illegal WARC file name: %s", path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("An error occurs when getting WARC file info: %s", err)
+		}
+		if info.Size() > maxSize {
+			t.Fatalf("The WARC file %s exceeds the size limit: %d > %d", path, info.Size(), maxSize)
+		}
+		file, _ := os.Open(path)
+		reader, err := NewReader(file)
+		if err != nil {
+			t.Fatalf("An error occurs when creating WARC reader: %s", err)
+		}
+		for i := 0; ; i++ {
+			record, err := reader.ReadRecord()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("An error occurs when reading WARC record: %s (file: %s)", err, path)
+			}
+			if i == 0 && (record.Type() != TYPE_WARCINFO ||
+				record.Get(FIELD_FILENAME) != filepath.Base(path)) {
+				t.Fatalf("The WARC file %s should begin with a warcinfo record: %#v", path, record.Fields)
+			}
+			if record.Type() == TYPE_RESPONSE {
+				responses++
+			}
+		}
+		file.Close()
+	}
+	if responses != exchangeNumber {
+		t.Fatalf("Inconsistent response record number: expected: %d, actual: %d",
+			exchangeNumber, responses)
+	}
+}
+
+func TestFileWriterDiscard(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewFileWriter(FileConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("An error occurs when creating a WARC file writer: %s", err)
+	}
+	genRecords := func(i int) []*Record {
+		ex := &Exchange{
+			Date:   time.Now(),
+			URL:    fmt.Sprintf("http://example.com/%d", i),
+			Status: "200 OK",
+			Body:   []byte(fmt.Sprintf("page %d", i)),
+		}
+		records, _ := ex.Records()
+		return records
+	}
+	if err := writer.WriteRecords(genRecords(0)...); err != nil {
+		t.Fatalf("An error occurs when writing WARC records: %s", err)
+	}
+	// 模拟只写入了一部分的记录
+	size := writer.size
+	writer.file.Write([]byte{0x1f, 0x8b, 0x08})
+	writer.discard(size)
+	if err := writer.WriteRecords(genRecords(1)...); err != nil {
+		t.Fatalf("An error occurs when writing WARC records: %s", err)
+	}
+	// 无法写入时不应留下损坏的记录，之后的记录会被写入新的文件
+	writer.file.Close()
+	if err := writer.WriteRecords(genRecords(2)...); err == nil {
+		t.Fatal("No error when writing to a closed WARC file!")
+	}
+	if err := writer.WriteRecords(genRecords(3)...); err != nil {
+		t.Fatalf("An error occurs when writing WARC records: %s", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("An error occurs when closing the WARC file writer: %s", err)
+	}
+	// 无法截断的文件会保留后缀
+	open, _ := filepath.Glob(filepath.Join(dir, "*"+OPEN_SUFFIX))
+	if len(open) != 1 || len(writer.Files()) != 1 {
+		t.Fatalf("Inconsistent WARC files: open: %v, finished: %v", open, writer.Files())
+	}
+	var urls []string
+	for _, path := range append(open, writer.Files()...) {
+		file, _ := os.Open(path)
+		reader, _ := NewReader(file)
+		for {
+			record, err := reader.ReadRecord()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("An error occurs when reading WARC record: %s (file: %s)", err, path)
+			}
+			if record.Type() == TYPE_RESPONSE {
+				urls = append(urls, record.Get(FIELD_TARGET_URI))
+			}
+		}
+		file.Close()
+	}
+	expected := []string{"http://example.com/0", "http://example.com/1", "http://example.com/3"}
+	if strings.Join(urls, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Inconsistent archived URLs: expected: %v, actual: %v", expected, urls)
+	}
+}